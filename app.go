@@ -1,25 +1,29 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"lumos-stock/backend/backtest"
+	"lumos-stock/backend/calendar"
 	"lumos-stock/backend/data"
 	"lumos-stock/backend/db"
+	"lumos-stock/backend/events"
+	"lumos-stock/backend/indicators"
 	"lumos-stock/backend/logger"
 	"lumos-stock/backend/models"
+	"lumos-stock/backend/scheduler"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/duke-git/lancet/v2/cryptor"
 	"github.com/inconshreveable/go-update"
-	"golang.org/x/exp/slices"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/coocood/freecache"
@@ -28,33 +32,36 @@ import (
 	"github.com/duke-git/lancet/v2/slice"
 	"github.com/duke-git/lancet/v2/strutil"
 	"github.com/go-resty/resty/v2"
-	"github.com/robfig/cron/v3"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // App struct
 type App struct {
 	ctx         context.Context
+	bus         *events.Bus
 	cache       *freecache.Cache
-	cron        *cron.Cron
-	cronEntrys  map[string]cron.EntryID
+	scheduler   *scheduler.Scheduler
 	AiTools     []data.Tool
 	SponsorInfo map[string]any
+	//有效的 VIP 等级,每次校验赞助码时更新,非 VIP 为 0
+	vipLevel atomic.Int64
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	cacheSize := 512 * 1024
 	cache := freecache.NewCache(cacheSize)
-	c := cron.New(cron.WithSeconds())
-	c.Start()
+	jobs := scheduler.New(scheduler.NewDBStore())
+	jobs.Start()
 	var tools []data.Tool
 	tools = AddTools(tools)
+	bus := events.NewBus()
+	bus.Subscribe(events.NewLogSubscriber(200), data.TopicAlert.Name(), data.TopicWarnMsg.Name(), data.TopicUpdateVersion.Name())
 	return &App{
-		cache:      cache,
-		cron:       c,
-		cronEntrys: make(map[string]cron.EntryID),
-		AiTools:    tools,
+		bus:       bus,
+		cache:     cache,
+		scheduler: jobs,
+		AiTools:   tools,
 	}
 }
 
@@ -281,7 +288,7 @@ func (a *App) CheckUpdate(flag int) {
 	if _, vipLevel, ok := a.isVip(sponsorCode, "", releaseVersion); ok {
 		level, _ := convertor.ToInt(vipLevel)
 		if level >= 2 {
-			go a.syncNews()
+			go a.fetchNewsSource(data.NewsSourceGoStock)
 		}
 	}
 
@@ -303,7 +310,7 @@ func (a *App) CheckUpdate(flag int) {
 		}
 
 		if !(IsWindows() || IsMacOS()) {
-			go runtime.EventsEmit(a.ctx, "updateVersion", releaseVersion)
+			data.TopicUpdateVersion.Publish(a.bus, releaseVersion)
 			return
 		}
 		downloadUrl := fmt.Sprintf("https://github.com/ArvinLovegood/go-stock/releases/download/%s/go-stock-windows-amd64.exe", releaseVersion.TagName)
@@ -314,30 +321,30 @@ func (a *App) CheckUpdate(flag int) {
 		if !done {
 			return
 		}
-		go runtime.EventsEmit(a.ctx, "newsPush", map[string]any{
-			"time":    "发现新版本：" + releaseVersion.TagName,
-			"isRed":   true,
-			"source":  "lumos-stock",
-			"content": fmt.Sprintf("%s", commit.Message),
+		data.TopicNewsPush.Publish(a.bus, models.Telegraph{
+			Time:    "发现新版本：" + releaseVersion.TagName,
+			IsRed:   true,
+			Source:  "lumos-stock",
+			Content: fmt.Sprintf("%s", commit.Message),
 		})
 		resp, err := resty.New().R().Get(downloadUrl)
 		if err != nil {
-			go runtime.EventsEmit(a.ctx, "newsPush", map[string]any{
-				"time":    "新版本：" + releaseVersion.TagName,
-				"isRed":   true,
-				"source":  "lumos-stock",
-				"content": commit.Message + "\n新版本下载失败,请稍后重试或请前往 https://github.com/ArvinLovegood/go-stock/releases 手动下载替换文件。",
+			data.TopicNewsPush.Publish(a.bus, models.Telegraph{
+				Time:    "新版本：" + releaseVersion.TagName,
+				IsRed:   true,
+				Source:  "lumos-stock",
+				Content: commit.Message + "\n新版本下载失败,请稍后重试或请前往 https://github.com/ArvinLovegood/go-stock/releases 手动下载替换文件。",
 			})
 			return
 		}
 		body := resp.Body()
 
 		if len(body) < 1024*500 {
-			go runtime.EventsEmit(a.ctx, "newsPush", map[string]any{
-				"time":    "新版本：" + releaseVersion.TagName,
-				"isRed":   true,
-				"source":  "lumos-stock",
-				"content": commit.Message + "\n新版本下载失败,请稍后重试或请前往 https://github.com/ArvinLovegood/go-stock/releases 手动下载替换文件。",
+			data.TopicNewsPush.Publish(a.bus, models.Telegraph{
+				Time:    "新版本：" + releaseVersion.TagName,
+				IsRed:   true,
+				Source:  "lumos-stock",
+				Content: commit.Message + "\n新版本下载失败,请稍后重试或请前往 https://github.com/ArvinLovegood/go-stock/releases 手动下载替换文件。",
 			})
 			return
 		}
@@ -345,23 +352,23 @@ func (a *App) CheckUpdate(flag int) {
 		err = update.Apply(bytes.NewReader(body), update.Options{})
 		if err != nil {
 			logger.SugaredLogger.Error("更新失败: ", err.Error())
-			go runtime.EventsEmit(a.ctx, "updateVersion", releaseVersion)
+			data.TopicUpdateVersion.Publish(a.bus, releaseVersion)
 			return
 		} else {
-			go runtime.EventsEmit(a.ctx, "newsPush", map[string]any{
-				"time":    "新版本：" + releaseVersion.TagName,
-				"isRed":   true,
-				"source":  "lumos-stock",
-				"content": "版本更新完成,下次重启软件生效.",
+			data.TopicNewsPush.Publish(a.bus, models.Telegraph{
+				Time:    "新版本：" + releaseVersion.TagName,
+				IsRed:   true,
+				Source:  "lumos-stock",
+				Content: "版本更新完成,下次重启软件生效.",
 			})
 		}
 	} else {
 		if flag == 1 {
-			go runtime.EventsEmit(a.ctx, "newsPush", map[string]any{
-				"time":    "当前版本：" + Version,
-				"isRed":   true,
-				"source":  "lumos-stock",
-				"content": "当前版本无更新",
+			data.TopicNewsPush.Publish(a.bus, models.Telegraph{
+				Time:    "当前版本：" + Version,
+				IsRed:   true,
+				Source:  "lumos-stock",
+				Content: "当前版本无更新",
 			})
 		}
 
@@ -376,17 +383,20 @@ func (a *App) isVip(sponsorCode string, downloadUrl string, releaseVersion *mode
 		encrypted, err := hex.DecodeString(sponsorCode)
 		if err != nil {
 			logger.SugaredLogger.Error(err.Error())
+			a.vipLevel.Store(0)
 			return "", "0", false
 		}
 		key, err := hex.DecodeString(BuildKey)
 		if err != nil {
 			logger.SugaredLogger.Error(err.Error())
+			a.vipLevel.Store(0)
 			return "", "0", false
 		}
 		decrypt := string(cryptor.AesEcbDecrypt(encrypted, key))
 		err = json.Unmarshal([]byte(decrypt), &a.SponsorInfo)
 		if err != nil {
 			logger.SugaredLogger.Error(err.Error())
+			a.vipLevel.Store(0)
 			return "", "0", false
 		}
 		vipLevel = a.SponsorInfo["vipLevel"].(string)
@@ -395,6 +405,7 @@ func (a *App) isVip(sponsorCode string, downloadUrl string, releaseVersion *mode
 		vipAuthTime, err := time.ParseInLocation("2006-01-02 15:04:05", a.SponsorInfo["vipAuthTime"].(string), time.Local)
 		if err != nil {
 			logger.SugaredLogger.Error(err.Error())
+			a.vipLevel.Store(0)
 			return "", vipLevel, false
 		}
 
@@ -426,87 +437,12 @@ func (a *App) isVip(sponsorCode string, downloadUrl string, releaseVersion *mode
 		}
 
 	}
-	return downloadUrl, vipLevel, isVip
-}
-
-func (a *App) syncNews() {
-	defer PanicHandler()
-	client := resty.New()
-	url := fmt.Sprintf("http://go-stock.sparkmemory.top:16666/FinancialNews/json?since=%d", time.Now().Add(-24*time.Hour).Unix())
-	logger.SugaredLogger.Infof("syncNews:%s", url)
-	resp, err := client.R().SetDoNotParseResponse(true).Get(url)
-	body := resp.RawBody()
-	defer body.Close()
-	if err != nil {
-		logger.SugaredLogger.Errorf("syncNews error:%s", err.Error())
-	}
-	scanner := bufio.NewScanner(body)
-	for scanner.Scan() {
-		line := scanner.Text()
-		logger.SugaredLogger.Infof("Received data: %s", line)
-		news := &models.NtfyNews{}
-		err := json.Unmarshal(scanner.Bytes(), news)
-		if err != nil {
-			return
-		}
-		dataTime := time.UnixMilli(int64(news.Time * 1000))
-
-		if slice.ContainAny(news.Tags, []string{"外媒资讯", "财联社电报", "新浪财经", "外媒简讯", "外媒"}) {
-			isRed := false
-			if slice.Contain(news.Tags, "rotating_light") {
-				isRed = true
-			}
-			telegraph := &models.Telegraph{
-				Title:           news.Title,
-				Content:         news.Message,
-				DataTime:        &dataTime,
-				IsRed:           isRed,
-				Time:            dataTime.Format("15:04:05"),
-				Source:          GetSource(news.Tags),
-				SentimentResult: data.AnalyzeSentiment(news.Message).Description,
-			}
-			cnt := int64(0)
-			if telegraph.Title == "" {
-				db.Dao.Model(telegraph).Where("content=?", telegraph.Content).Count(&cnt)
-			} else {
-				db.Dao.Model(telegraph).Where("title=?", telegraph.Title).Count(&cnt)
-			}
-			if cnt == 0 {
-				db.Dao.Model(telegraph).Create(&telegraph)
-				//计算时间差如果<5分钟则推送
-				if time.Now().Sub(dataTime) < 5*time.Minute {
-					a.NewsPush(&[]models.Telegraph{*telegraph})
-				}
-				tags := slice.Filter(news.Tags, func(index int, item string) bool {
-					return !(item == "rotating_light" || item == "loudspeaker")
-				})
-				for _, subject := range tags {
-					tag := &models.Tags{
-						Name: subject,
-						Type: "subject",
-					}
-					db.Dao.Model(tag).Where("name=? and type=?", subject, "subject").FirstOrCreate(&tag)
-					db.Dao.Model(models.TelegraphTags{}).Where("telegraph_id=? and tag_id=?", telegraph.ID, tag.ID).FirstOrCreate(&models.TelegraphTags{
-						TelegraphId: telegraph.ID,
-						TagId:       tag.ID,
-					})
-				}
-			}
-		}
-	}
-}
-
-func GetSource(tags []string) string {
-	if slice.ContainAny(tags, []string{"外媒简讯", "外媒资讯", "外媒"}) {
-		return "外媒"
-	}
-	if slices.Contains(tags, "财联社电报") {
-		return "财联社电报"
-	}
-	if slices.Contains(tags, "新浪财经") {
-		return "新浪财经"
+	level, _ := convertor.ToInt(vipLevel)
+	if !isVip {
+		level = 0
 	}
-	return ""
+	a.vipLevel.Store(level)
+	return downloadUrl, vipLevel, isVip
 }
 
 // domReady is called after front-end resources have been loaded
@@ -516,7 +452,7 @@ func (a *App) domReady(ctx context.Context) {
 		// 增加延迟确保前端已准备好接收事件
 		go func() {
 			time.Sleep(2 * time.Second)
-			runtime.EventsEmit(a.ctx, "loadingMsg", "done")
+			data.TopicLoadingMsg.Publish(a.bus, "done")
 		}()
 	}()
 
@@ -539,17 +475,17 @@ func (a *App) domReady(ctx context.Context) {
 	// Add your action here
 	//定时更新数据
 	config := data.GetSettingConfig()
+	a.refreshVipLevel()
 	go func() {
-		go data.NewMarketNewsApi().TelegraphList(30)
-		go data.NewMarketNewsApi().GetSinaNews(30)
-		go data.NewMarketNewsApi().TradingViewNews()
+		a.fetchDueNewsSources()
 
 		interval := config.RefreshInterval
 		if interval <= 0 {
 			interval = 1
 		}
-		a.cron.AddFunc(fmt.Sprintf("@every %ds", interval+60), func() {
+		a.addJob("NewsAnalyze", fmt.Sprintf("@every %ds", interval+60), "资讯情绪分析", func() error {
 			data.NewsAnalyze("", true)
+			return nil
 		})
 
 		//ticker := time.NewTicker(time.Second * time.Duration(interval))
@@ -557,53 +493,11 @@ func (a *App) domReady(ctx context.Context) {
 		//for range ticker.C {
 		//	MonitorStockPrices(a)
 		//}
-		id, err := a.cron.AddFunc(fmt.Sprintf("@every %ds", interval), func() {
+		a.addJob("MonitorStockPrices", fmt.Sprintf("@every %ds", interval), "刷新关注股票行情", func() error {
 			MonitorStockPrices(a)
+			return nil
 		})
-		if err != nil {
-			logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
-		} else {
-			a.cronEntrys["MonitorStockPrices"] = id
-		}
-		entryID, err := a.cron.AddFunc(fmt.Sprintf("@every %ds", interval+10), func() {
-			//news := data.NewMarketNewsApi().GetNewTelegraph(30)
-			news := data.NewMarketNewsApi().TelegraphList(30)
-			if config.EnablePushNews {
-				go a.NewsPush(news)
-			}
-			go runtime.EventsEmit(a.ctx, "newTelegraph", news)
-		})
-		if err != nil {
-			logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
-		} else {
-			a.cronEntrys["GetNewTelegraph"] = entryID
-		}
-
-		entryIDSina, err := a.cron.AddFunc(fmt.Sprintf("@every %ds", interval+10), func() {
-			news := data.NewMarketNewsApi().GetSinaNews(30)
-			if config.EnablePushNews {
-				go a.NewsPush(news)
-			}
-			go runtime.EventsEmit(a.ctx, "newSinaNews", news)
-		})
-		if err != nil {
-			logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
-		} else {
-			a.cronEntrys["newSinaNews"] = entryIDSina
-		}
-
-		entryIDTradingViewNews, err := a.cron.AddFunc(fmt.Sprintf("@every %ds", interval+10), func() {
-			news := data.NewMarketNewsApi().TradingViewNews()
-			if config.EnablePushNews {
-				go a.NewsPush(news)
-			}
-			go runtime.EventsEmit(a.ctx, "tradingViewNews", news)
-		})
-		if err != nil {
-			logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
-		} else {
-			a.cronEntrys["tradingViewNews"] = entryIDTradingViewNews
-		}
+		a.scheduleNewsSources()
 	}()
 
 	//刷新基金净值信息
@@ -614,14 +508,10 @@ func (a *App) domReady(ctx context.Context) {
 		//	MonitorFundPrices(a)
 		//}
 		if config.EnableFund {
-			id, err := a.cron.AddFunc(fmt.Sprintf("@every %ds", 60), func() {
+			a.addJob("MonitorFundPrices", fmt.Sprintf("@every %ds", 60), "刷新关注基金净值", func() error {
 				MonitorFundPrices(a)
+				return nil
 			})
-			if err != nil {
-				logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
-			} else {
-				a.cronEntrys["MonitorFundPrices"] = id
-			}
 		}
 
 	}()
@@ -639,19 +529,15 @@ func (a *App) domReady(ctx context.Context) {
 		//
 		//}()
 
-		id, err := a.cron.AddFunc(fmt.Sprintf("@every %ds", 60), func() {
+		a.addJob("refreshTelegraphList", fmt.Sprintf("@every %ds", 60), "刷新财联社电报标题", func() error {
 			telegraph := refreshTelegraphList()
 			if telegraph != nil {
-				go runtime.EventsEmit(a.ctx, "telegraph", telegraph)
+				data.TopicClsTelegraph.Publish(a.bus, telegraph)
 			}
+			return nil
 		})
-		if err != nil {
-			logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
-		} else {
-			a.cronEntrys["refreshTelegraphList"] = id
-		}
 
-		go runtime.EventsEmit(a.ctx, "telegraph", refreshTelegraphList())
+		go data.TopicClsTelegraph.Publish(a.bus, refreshTelegraphList())
 	}
 	go MonitorStockPrices(a)
 	if config.EnableFund {
@@ -663,16 +549,78 @@ func (a *App) domReady(ctx context.Context) {
 		a.CheckUpdate(0)
 		go a.CheckStockBaseInfo(a.ctx)
 
-		a.cron.AddFunc("0 0 2 * * *", func() {
+		a.addJob("CheckStockBaseInfo", "0 0 2 * * *", "更新股票基础信息", func() error {
 			logger.SugaredLogger.Errorf("Checking for updates...")
 			a.CheckStockBaseInfo(a.ctx)
+			return nil
 		})
-		a.cron.AddFunc("30 05 8,12,20 * * *", func() {
+		a.addJob("CheckUpdate", "30 05 8,12,20 * * *", "检查新版本", func() error {
 			logger.SugaredLogger.Errorf("Checking for updates...")
 			a.CheckUpdate(0)
+			return nil
+		})
+	}()
+
+	//同步关注股票K线数据
+	go func() {
+		if err := data.NewKLineStoreApi().SyncFollowedKLine(); err != nil {
+			logger.SugaredLogger.Errorf("SyncFollowedKLine error:%s", err.Error())
+		}
+		a.addJob("SyncFollowedKLine", "0 30 16 * * 1-5", "同步关注股票日K线", func() error {
+			if !calendar.IsAnyTradingDay(time.Now()) {
+				return nil
+			}
+			return data.NewKLineStoreApi().SyncFollowedKLine()
+		})
+	}()
+
+	//同步关注股票资金流向
+	go func() {
+		if err := data.NewMoneyFlowApi().SyncFollowedMoneyFlow(); err != nil {
+			logger.SugaredLogger.Errorf("SyncFollowedMoneyFlow error:%s", err.Error())
+		}
+		a.addJob("SyncFollowedMoneyFlow", "0 35 16 * * 1-5", "同步关注股票资金流向", func() error {
+			if !calendar.IsAnyTradingDay(time.Now()) {
+				return nil
+			}
+			return data.NewMoneyFlowApi().SyncFollowedMoneyFlow()
 		})
 	}()
 
+	//汇总个股和板块资讯情绪,启动时回补最近30天
+	go func() {
+		n, err := data.NewNewsSentimentApi().Aggregate(time.Now().AddDate(0, 0, -30), time.Now())
+		logger.SugaredLogger.Infof("AggregateNewsSentiment buckets:%d err:%v", n, err)
+		a.addJob("AggregateNewsSentiment", "0 5 * * * *", "汇总资讯情绪", func() error {
+			_, err := data.NewNewsSentimentApi().Aggregate(time.Now().Add(-data.SentimentAggregateWindow), time.Now())
+			return err
+		})
+	}()
+
+	//配置了情绪分类模型时,重新分类最近的资讯
+	a.addJob("ClassifyNewsSentiment", "0 */5 * * * *", "模型分类资讯情绪", func() error {
+		data.NewNewsClassifierApi().ClassifyRecent(time.Now().Add(-time.Hour))
+		return nil
+	})
+
+	//收盘后记录持仓快照
+	a.addJob("PortfolioSnapshot", "0 40 16 * * 1-5", "记录持仓快照", func() error {
+		if !calendar.IsAnyTradingDay(time.Now()) {
+			return nil
+		}
+		_, err := data.NewPortfolioApi().TakeSnapshot()
+		return err
+	})
+	//美股收盘后按美东日期更新美股持仓快照
+	a.addJob("PortfolioSnapshotUS", "0 0 6 * * 2-6", "记录美股持仓快照", func() error {
+		day := time.Now().In(calendar.NYSE.Location)
+		if !calendar.NYSE.IsTradingDay(day) {
+			return nil
+		}
+		_, err := data.NewPortfolioApi().TakeUSSnapshot(day.Format(time.DateOnly))
+		return err
+	})
+
 	//检查谷歌浏览器
 	//go func() {
 	//	f := checkChromeOnWindows()
@@ -695,20 +643,15 @@ func (a *App) domReady(ctx context.Context) {
 		if follow.Cron == nil || *follow.Cron == "" {
 			continue
 		}
-		entryID, err := a.cron.AddFunc(*follow.Cron, a.AddCronTask(follow))
-		if err != nil {
-			logger.SugaredLogger.Errorf("添加自动分析任务失败:%s cron=%s entryID:%v", follow.Name, *follow.Cron, entryID)
-			continue
-		}
-		a.cronEntrys[follow.StockCode] = entryID
+		a.addJob(stockAICronJob(follow.StockCode), *follow.Cron, "自动分析:"+follow.Name, a.AddCronTask(follow))
 	}
-	logger.SugaredLogger.Infof("domReady-cronEntrys:%+v", a.cronEntrys)
+	logger.SugaredLogger.Infof("domReady-jobs:%d", len(a.scheduler.List()))
 
 }
 func (a *App) CheckStockBaseInfo(ctx context.Context) {
 	defer PanicHandler()
 	defer func() {
-		go runtime.EventsEmit(ctx, "loadingMsg", "done")
+		data.TopicLoadingMsg.Publish(events.FromContext(ctx), "done")
 	}()
 	stockBasics := &[]data.StockBasic{}
 	resty.New().R().
@@ -803,22 +746,140 @@ func (a *App) NewsPush(news *[]models.Telegraph) {
 		return item.Name
 	})
 
-	for _, telegraph := range *news {
-		if a.GetConfig().EnableOnlyPushRedNews {
-			if telegraph.IsRed || strutil.ContainsAny(telegraph.Content, stockNames) {
-				go runtime.EventsEmit(a.ctx, "newsPush", telegraph)
-			}
-		} else {
-			go runtime.EventsEmit(a.ctx, "newsPush", telegraph)
-		}
+	for _, telegraph := range filterPushNews(*news, stockNames, a.GetConfig().EnableOnlyPushRedNews) {
+		data.TopicNewsPush.Publish(a.bus, telegraph)
+		a.notifyNews(telegraph)
 		//go data.NewAlertWindowsApi("go-stock", telegraph.Source+" "+telegraph.Time, telegraph.Content, string(icon)).SendNotification()
-		//}
 	}
 }
 
-func (a *App) AddCronTask(follow data.FollowedStock) func() {
-	return func() {
-		go runtime.EventsEmit(a.ctx, "warnMsg", "开始自动分析"+follow.Name+"_"+follow.StockCode)
+// filterPushNews 跳过其他来源已报道过的快讯;只推送重要快讯时,保留红色快讯和提及关注股票的快讯
+func filterPushNews(news []models.Telegraph, stockNames []string, onlyRed bool) []models.Telegraph {
+	return slice.Filter(news, func(index int, telegraph models.Telegraph) bool {
+		if telegraph.ClusterId != 0 {
+			return false
+		}
+		return !onlyRed || telegraph.IsRed || strutil.ContainsAny(telegraph.Content, stockNames)
+	})
+}
+
+// notifyNews 快讯推送到订阅了快讯的渠道,同一条快讯一天内只推送一次
+func (a *App) notifyNews(telegraph models.Telegraph) {
+	key := []byte("news:" + cryptor.Md5String(telegraph.Title+telegraph.Content))
+	if ttl, _ := a.cache.TTL(key); ttl > 0 {
+		return
+	}
+	if err := a.cache.Set(key, []byte("1"), 60*60*24); err != nil {
+		logger.SugaredLogger.Errorf("set cache error:%s", err.Error())
+		return
+	}
+	go data.NewNotifyApi().Dispatch(data.NewsNotification(telegraph.Title, telegraph.Content, telegraph.Source, telegraph.Url))
+}
+
+// scheduleNewsSources 为启用的资讯来源注册刷新任务,停用的来源移除任务
+func (a *App) scheduleNewsSources() {
+	refresh := data.GetSettingConfig().RefreshInterval
+	for _, source := range data.NewNewsSourceApi().GetNewsSources() {
+		job := newsSourceJob(source.Name)
+		if !source.Enabled || !a.newsSourceAllowed(source.Name) {
+			a.scheduler.Remove(job)
+			continue
+		}
+		name := source.Name
+		a.addJob(job, fmt.Sprintf("@every %ds", newsSourceInterval(source, refresh)), "刷新资讯:"+source.Title, func() error {
+			a.fetchNewsSource(name)
+			return nil
+		})
+	}
+}
+
+// fetchDueNewsSources 拉取启用且距上次拉取已超过刷新间隔的资讯来源
+func (a *App) fetchDueNewsSources() {
+	refresh := data.GetSettingConfig().RefreshInterval
+	for _, source := range data.NewNewsSourceApi().GetNewsSources() {
+		if !source.Enabled || !a.newsSourceAllowed(source.Name) {
+			continue
+		}
+		interval := time.Duration(newsSourceInterval(source, refresh)) * time.Second
+		if source.FetchedAt != nil && time.Since(*source.FetchedAt) < interval {
+			continue
+		}
+		go a.fetchNewsSource(source.Name)
+	}
+}
+
+// newsSourceInterval 资讯来源的刷新间隔秒,未设置时跟随行情刷新间隔
+func newsSourceInterval(source data.NewsSourceConfig, refresh int64) int64 {
+	if source.Interval > 0 {
+		return int64(source.Interval)
+	}
+	return max(refresh, 1) + 10
+}
+
+func newsSourceJob(name string) string {
+	return "NewsSource:" + name
+}
+
+// newsSourceAllowed go-stock 资讯仅对 VIP2 及以上的赞助用户开放,读取最近一次校验赞助码缓存的等级
+func (a *App) newsSourceAllowed(name string) bool {
+	return name != data.NewsSourceGoStock || a.vipLevel.Load() >= 2
+}
+
+// refreshVipLevel 重新校验设置中的赞助码,更新缓存的 VIP 等级
+func (a *App) refreshVipLevel() {
+	a.isVip("", "", &models.GitHubReleaseVersion{})
+}
+
+// fetchNewsSource 拉取资讯来源的新资讯,推送并通知前端
+func (a *App) fetchNewsSource(name string) *[]models.Telegraph {
+	defer PanicHandler()
+	if !a.newsSourceAllowed(name) {
+		return &[]models.Telegraph{}
+	}
+	//首次拉取(游标为空)得到的是来源的历史资讯,只入库不推送
+	config, _ := data.NewNewsSourceApi().GetNewsSource(name)
+	first := config.Cursor == nil
+	news := data.NewNewsSourceApi().Fetch(name)
+	if a.GetConfig().EnablePushNews && !first {
+		go a.NewsPush(news)
+	}
+	newsSourceTopic(name).Publish(a.bus, news)
+	return news
+}
+
+// newsSourceTopic 内置来源沿用原有事件,其他来源使用统一事件
+func newsSourceTopic(name string) events.Topic[*[]models.Telegraph] {
+	switch name {
+	case data.NewsSourceCls:
+		return data.TopicTelegraph
+	case data.NewsSourceSina:
+		return data.TopicSinaNews
+	case data.NewsSourceTradingView:
+		return data.TopicTradingViewNews
+	}
+	return data.TopicSourceNews
+}
+
+// addJob 注册命名定时任务,同名任务会被替换;任务返回的错误记录在任务状态中
+func (a *App) addJob(name, spec, description string, fn func() error) {
+	err := a.scheduler.Register(name, spec, description, fn)
+	if err != nil {
+		logger.SugaredLogger.Errorf("AddFunc error:%s", err.Error())
+	}
+}
+
+// stockAICronJob 股票自动分析任务名称
+func stockAICronJob(stockCode string) string {
+	return "StockAICron:" + stockCode
+}
+
+func (a *App) AddCronTask(follow data.FollowedStock) func() error {
+	return func() error {
+		if !calendar.IsStockTradingDay(follow.StockCode, time.Now()) {
+			logger.SugaredLogger.Infof("非交易日,跳过自动分析:%s %s", follow.Name, follow.StockCode)
+			return nil
+		}
+		data.TopicWarnMsg.Publish(a.bus, "开始自动分析"+follow.Name+"_"+follow.StockCode)
 		ai := data.NewDeepSeekOpenAi(a.ctx, follow.AiConfigId)
 		msgs := ai.NewChatStream(follow.Name, follow.StockCode, "", nil, a.AiTools, true)
 		var res strings.Builder
@@ -840,9 +901,12 @@ func (a *App) AddCronTask(follow data.FollowedStock) func() {
 			}
 		}
 
+		if strutil.Trim(res.String()) == "" {
+			return fmt.Errorf("自动分析%s没有返回结果", follow.Name)
+		}
 		data.NewDeepSeekOpenAi(a.ctx, follow.AiConfigId).SaveAIResponseResult(follow.StockCode, follow.Name, res.String(), chatId, question)
-		go runtime.EventsEmit(a.ctx, "warnMsg", "AI分析完成："+follow.Name+"_"+follow.StockCode)
-
+		data.TopicWarnMsg.Publish(a.bus, "AI分析完成："+follow.Name+"_"+follow.StockCode)
+		return nil
 	}
 }
 
@@ -868,104 +932,30 @@ func refreshTelegraphList() *[]string {
 	return &telegraph
 }
 
-// isTradingDay 判断是否是交易日
+// isTradingDay 判断是否是A股交易日
 func isTradingDay(date time.Time) bool {
-	weekday := date.Weekday()
-	// 判断是否是周末
-	if weekday == time.Saturday || weekday == time.Sunday {
-		return false
-	}
-	// 这里可以添加具体的节假日判断逻辑
-	// 例如：判断是否是春节、国庆节等
-	return true
+	return calendar.SSE.IsTradingDay(date)
 }
 
-// isTradingTime 判断是否是交易时间
+// isTradingTime 判断是否是A股交易时间
 func isTradingTime(date time.Time) bool {
-	if !isTradingDay(date) {
-		return false
-	}
-
-	hour, minute, _ := date.Clock()
-
-	// 判断是否在9:15到11:30之间
-	if (hour == 9 && minute >= 15) || (hour == 10) || (hour == 11 && minute <= 30) {
-		return true
-	}
-
-	// 判断是否在13:00到15:00之间
-	if (hour == 13) || (hour == 14) || (hour == 15 && minute <= 0) {
-		return true
-	}
-
-	return false
+	return calendar.SSE.IsTradingTime(date)
 }
 
 // IsHKTradingTime 判断当前时间是否在港股交易时间内
 func IsHKTradingTime(date time.Time) bool {
-	hour, minute, _ := date.Clock()
-
-	// 开市前竞价时段：09:00 - 09:30
-	if (hour == 9 && minute >= 0) || (hour == 9 && minute <= 30) {
-		return true
-	}
-
-	// 上午持续交易时段：09:30 - 12:00
-	if (hour == 9 && minute > 30) || (hour >= 10 && hour < 12) || (hour == 12 && minute == 0) {
-		return true
-	}
-
-	// 下午持续交易时段：13:00 - 16:00
-	if (hour == 13 && minute >= 0) || (hour >= 14 && hour < 16) || (hour == 16 && minute == 0) {
-		return true
-	}
-
-	// 收市竞价交易时段：16:00 - 16:10
-	if (hour == 16 && minute >= 0) || (hour == 16 && minute <= 10) {
-		return true
-	}
-	return false
+	return calendar.HKEX.IsTradingTime(date)
 }
 
-// IsUSTradingTime 判断当前时间是否在美股交易时间内
+// IsUSTradingTime 判断当前时间是否在美股交易时间内(包含盘前盘后)
 func IsUSTradingTime(date time.Time) bool {
-	// 获取美国东部时区
-	est, err := time.LoadLocation("America/New_York")
-	var estTime time.Time
-	if err != nil {
-		estTime = date.Add(time.Hour * -12)
-	} else {
-		// 将当前时间转换为美国东部时间
-		estTime = date.In(est)
-	}
-
-	// 判断是否是周末
-	weekday := estTime.Weekday()
-	if weekday == time.Saturday || weekday == time.Sunday {
-		return false
-	}
-
-	// 获取小时和分钟
-	hour, minute, _ := estTime.Clock()
-
-	// 判断是否在4:00 AM到9:30 AM之间（盘前）
-	if (hour == 4) || (hour == 5) || (hour == 6) || (hour == 7) || (hour == 8) || (hour == 9 && minute < 30) {
-		return true
-	}
-
-	// 判断是否在9:30 AM到4:00 PM之间（盘中）
-	if (hour == 9 && minute >= 30) || (hour >= 10 && hour < 16) || (hour == 16 && minute == 0) {
-		return true
-	}
-
-	// 判断是否在4:00 PM到8:00 PM之间（盘后）
-	if (hour == 16 && minute > 0) || (hour >= 17 && hour < 20) || (hour == 20 && minute == 0) {
-		return true
-	}
-
-	return false
+	return calendar.NYSE.IsExtendedTradingTime(date)
 }
+
 func MonitorFundPrices(a *App) {
+	if !isTradingDay(time.Now()) {
+		return
+	}
 	dest := &[]data.FollowedFund{}
 	db.Dao.Model(&data.FollowedFund{}).Find(dest)
 	for _, follow := range *dest {
@@ -979,17 +969,40 @@ func MonitorFundPrices(a *App) {
 	}
 }
 
+// refreshStockPrices 刷新关注股票行情并检查报警,返回按基础货币汇总的当日盈亏及其展示文本
+func refreshStockPrices(a *App) (float64, string) {
+	dest := &[]data.FollowedStock{}
+	db.Dao.Model(&data.FollowedStock{}).Find(dest)
+	total := float64(0)
+	baseCurrency := data.GetBaseCurrency()
+	fx := data.NewFxRateApi()
+
+	stockInfos := GetStockInfos(*dest...)
+	for _, stockInfo := range *stockInfos {
+		if !calendar.IsStockTradingTime(stockInfo.Code, time.Now()) {
+			continue
+		}
+
+		//港股、美股盈亏按汇率换算为基础货币后汇总
+		total += fx.Convert(stockInfo.ProfitAmountToday, stockInfo.Currency, baseCurrency)
+		price, _ := convertor.ToFloat(stockInfo.Price)
+
+		if stockInfo.PrePrice != price {
+			data.TopicPriceTick.Publish(a.bus, stockInfo)
+		}
+		checkStockAlerts(a, &stockInfo)
+	}
+
+	profit := data.RealtimeProfit{Amount: total, Currency: baseCurrency}
+	data.TopicRealtimeProfit.Publish(a.bus, profit)
+	return total, profit.Text()
+}
+
 func GetStockInfos(follows ...data.FollowedStock) *[]data.StockInfo {
 	stockInfos := make([]data.StockInfo, 0)
 	stockCodes := make([]string, 0)
 	for _, follow := range follows {
-		if strutil.HasPrefixAny(follow.StockCode, []string{"SZ", "SH", "sh", "sz"}) && (!isTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(follow.StockCode, []string{"hk", "HK"}) && (!IsHKTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(follow.StockCode, []string{"us", "US", "gb_"}) && (!IsUSTradingTime(time.Now())) {
+		if !calendar.IsStockTradingTime(follow.StockCode, time.Now()) {
 			continue
 		}
 		stockCodes = append(stockCodes, follow.StockCode)
@@ -1010,6 +1023,16 @@ func GetStockInfos(follows ...data.FollowedStock) *[]data.StockInfo {
 	}
 	return &stockInfos
 }
+
+// checkStockAlerts 按报警规则检查行情,触发的报警通过钉钉和本地通知发送
+func checkStockAlerts(a *App, stockInfo *data.StockInfo) {
+	for _, history := range data.NewStockAlertApi().Evaluate(stockInfo) {
+		logger.SugaredLogger.Infof("stock alert:%s", history.Message)
+		data.TopicAlert.Publish(a.bus, history)
+		a.sendStockAlert(data.GenAlertDingDingMessage(history, stockInfo), stockInfo.Code, history.MsgType)
+	}
+}
+
 func getStockInfo(follow data.FollowedStock) *data.StockInfo {
 	stockCode := follow.StockCode
 	stockDatas, err := data.NewStockDataApi().GetStockCodeRealTimeData(stockCode)
@@ -1024,8 +1047,14 @@ func getStockInfo(follow data.FollowedStock) *data.StockInfo {
 func addStockFollowData(follow data.FollowedStock, stockData *data.StockInfo) {
 	stockData.PrePrice = follow.Price //上次当前价格
 	stockData.Sort = follow.Sort
-	stockData.CostPrice = follow.CostPrice //成本价
-	stockData.CostVolume = follow.Volume   //成本量
+	//成本价、持仓量和已实现盈亏由交易流水计算
+	position := data.NewTradeLedgerApi().GetPosition(follow.StockCode, data.CostMethodAverage)
+	stockData.CostPrice = position.CostPrice
+	stockData.CostVolume = position.Volume
+	stockData.RealizedProfit = position.RealizedProfit
+	if stockData.Currency == "" {
+		stockData.Currency = data.CurrencyOf(stockData.Code)
+	}
 	stockData.AlarmChangePercent = follow.AlarmChangePercent
 	stockData.AlarmPrice = follow.AlarmPrice
 	stockData.Groups = follow.Groups
@@ -1073,15 +1102,15 @@ func addStockFollowData(follow data.FollowedStock, stockData *data.StockInfo) {
 	if lowPrice > 0 && preClosePrice > 0 {
 		stockData.LowRate = mathutil.RoundToFloat(mathutil.Div(lowPrice-preClosePrice, preClosePrice)*100, 3)
 	}
-	if follow.CostPrice > 0 && follow.Volume > 0 {
+	if position.CostPrice > 0 && position.Volume > 0 {
 		if price > 0 {
-			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(price-follow.CostPrice, follow.CostPrice)*100, 3)
-			stockData.ProfitAmount = mathutil.RoundToFloat((price-follow.CostPrice)*float64(follow.Volume), 2)
-			stockData.ProfitAmountToday = mathutil.RoundToFloat((price-preClosePrice)*float64(follow.Volume), 2)
+			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(price-position.CostPrice, position.CostPrice)*100, 3)
+			stockData.ProfitAmount = mathutil.RoundToFloat((price-position.CostPrice)*float64(position.Volume), 2)
+			stockData.ProfitAmountToday = mathutil.RoundToFloat((price-preClosePrice)*float64(position.Volume), 2)
 		} else {
 			//未开盘时当前价格为昨日收盘价
-			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(preClosePrice-follow.CostPrice, follow.CostPrice)*100, 3)
-			stockData.ProfitAmount = mathutil.RoundToFloat((preClosePrice-follow.CostPrice)*float64(follow.Volume), 2)
+			stockData.Profit = mathutil.RoundToFloat(mathutil.Div(preClosePrice-position.CostPrice, position.CostPrice)*100, 3)
+			stockData.ProfitAmount = mathutil.RoundToFloat((preClosePrice-position.CostPrice)*float64(position.Volume), 2)
 			// 未开盘时，今日盈亏为 0
 			stockData.ProfitAmountToday = 0
 		}
@@ -1136,9 +1165,87 @@ func (a *App) SetCostPriceAndVolume(stockCode string, price float64, volume int6
 	return data.NewStockDataApi().SetCostPriceAndVolume(price, volume, stockCode)
 }
 
+func (a *App) AddStockTrade(trade data.StockTrade) string {
+	return data.NewTradeLedgerApi().AddTrade(trade)
+}
+func (a *App) DeleteStockTrade(id uint) string {
+	return data.NewTradeLedgerApi().DeleteTrade(id)
+}
+func (a *App) GetStockTrades(stockCode string) []data.StockTrade {
+	return data.NewTradeLedgerApi().GetTrades(stockCode)
+}
+func (a *App) GetStockPosition(stockCode, method string) data.StockPosition {
+	return data.NewTradeLedgerApi().GetPosition(stockCode, method)
+}
+
+func (a *App) SaveNotifyChannel(channel data.NotifyChannel) string {
+	return data.NewNotifyApi().SaveChannel(channel)
+}
+func (a *App) DeleteNotifyChannel(id uint) string {
+	return data.NewNotifyApi().DeleteChannel(id)
+}
+func (a *App) GetScheduledJobs() []scheduler.JobStatus {
+	return a.scheduler.List()
+}
+
+func (a *App) PauseScheduledJob(name string) string {
+	if err := a.scheduler.Pause(name); err != nil {
+		return err.Error()
+	}
+	return "暂停成功"
+}
+
+func (a *App) ResumeScheduledJob(name string) string {
+	if err := a.scheduler.Resume(name); err != nil {
+		return err.Error()
+	}
+	return "恢复成功"
+}
+
+func (a *App) RunScheduledJobNow(name string) string {
+	if err := a.scheduler.RunNow(name); err != nil {
+		return err.Error()
+	}
+	return "已开始执行"
+}
+
+func (a *App) GetNotifyChannels() []data.NotifyChannel {
+	return data.NewNotifyApi().GetChannels()
+}
+func (a *App) TestNotifyChannel(id uint) string {
+	return data.NewNotifyApi().TestChannel(id)
+}
+
+func (a *App) TakePortfolioSnapshot() (int, error) {
+	return data.NewPortfolioApi().TakeSnapshot()
+}
+func (a *App) GetPortfolioSnapshots(startDay, endDay string) []data.PortfolioSnapshot {
+	return data.NewPortfolioApi().GetSnapshots(startDay, endDay)
+}
+func (a *App) GetPortfolioPerformance(days int, benchmark string) *data.PortfolioPerformance {
+	return data.NewPortfolioApi().GetPerformance(days, benchmark)
+}
+
+// GetFxRates 各货币兑人民币汇率
+func (a *App) GetFxRates() map[string]float64 {
+	return data.NewFxRateApi().GetRates()
+}
+
 func (a *App) SetAlarmChangePercent(val, alarmPrice float64, stockCode string) string {
 	return data.NewStockDataApi().SetAlarmChangePercent(val, alarmPrice, stockCode)
 }
+func (a *App) AddAlertRule(rule data.AlertRule) string {
+	return data.NewStockAlertApi().AddAlertRule(rule)
+}
+func (a *App) DeleteAlertRule(id uint) string {
+	return data.NewStockAlertApi().DeleteAlertRule(id)
+}
+func (a *App) GetAlertRules(stockCode string) []data.AlertRule {
+	return data.NewStockAlertApi().GetAlertRules(stockCode)
+}
+func (a *App) GetAlertHistory(stockCode string, limit int) []data.AlertHistory {
+	return data.NewStockAlertApi().GetAlertHistory(stockCode, limit)
+}
 func (a *App) SetStockSort(sort int64, stockCode string) {
 	data.NewStockDataApi().SetStockSort(sort, stockCode)
 }
@@ -1156,7 +1263,7 @@ func (a *App) SendDingDingMessage(message string, stockCode string) string {
 	return data.NewDingDingAPI().SendDingDingMessage(message)
 }
 
-// SendDingDingMessageByType msgType 报警类型: 1 涨跌报警;2 股价报警 3 成本价报警
+// SendDingDingMessageByType msgType 报警类型: 1 涨跌报警;2 股价报警 3 成本价报警 4 新高新低报警
 func (a *App) SendDingDingMessageByType(message string, stockCode string, msgType int) string {
 
 	if strutil.HasPrefixAny(stockCode, []string{"SZ", "SH", "sh", "sz"}) && (!isTradingTime(time.Now())) {
@@ -1179,23 +1286,105 @@ func (a *App) SendDingDingMessageByType(message string, stockCode string, msgTyp
 		logger.SugaredLogger.Errorf("set cache error:%s", err.Error())
 		return ""
 	}
+	return a.sendStockAlert(message, stockCode, msgType)
+}
+
+// sendStockAlert 发送报警通知;报警规则触发的通知由规则的冷却时间和每日一次控制,不再按股票限流
+func (a *App) sendStockAlert(message string, stockCode string, msgType int) string {
 	stockInfo := &data.StockInfo{}
 	db.Dao.Model(stockInfo).Where("code = ?", stockCode).First(stockInfo)
 	go data.NewAlertWindowsApi("lumos-stock消息通知", getMsgTypeName(msgType), GenNotificationMsg(stockInfo), "").SendNotification()
-	return data.NewDingDingAPI().SendDingDingMessage(message)
+	notification := data.ParseDingDingMessage(message, stockCode, msgType)
+	if notification.Title == "" {
+		notification.Title = getMsgTypeName(msgType)
+	}
+	return data.NotifyResultMessage(data.NewNotifyApi().Dispatch(notification))
 }
 
 func (a *App) NewChatStream(stock, stockCode, question string, aiConfigId int, sysPromptId *int, enableTools bool, think bool) {
-	var msgs <-chan map[string]any
+	session := data.NewChatSessionApi().CreateSession(data.ChatKindStock, question, stockCode, stock, aiConfigId, sysPromptId)
+	data.TopicChatSession.Publish(a.bus, session)
+	a.chatStream(session, question, enableTools, think)
+}
+
+// ContinueChatStream 在已有的股票分析会话中继续提问,携带会话历史
+func (a *App) ContinueChatStream(sessionId uint, question string, enableTools bool, think bool) {
+	session := data.NewChatSessionApi().GetSession(sessionId)
+	if session == nil {
+		data.TopicWarnMsg.Publish(a.bus, "会话不存在")
+		return
+	}
+	a.chatStream(session, question, enableTools, think)
+}
+
+func (a *App) chatStream(session *data.ChatSession, question string, enableTools bool, think bool) {
+	tools := []data.Tool{}
 	if enableTools {
-		msgs = data.NewDeepSeekOpenAi(a.ctx, aiConfigId).NewChatStream(stock, stockCode, question, sysPromptId, a.AiTools, think)
-	} else {
-		msgs = data.NewDeepSeekOpenAi(a.ctx, aiConfigId).NewChatStream(stock, stockCode, question, sysPromptId, []data.Tool{}, think)
+		tools = a.AiTools
 	}
+	msgs := data.NewDeepSeekOpenAi(a.ctx, session.AiConfigId).WithSession(session).
+		NewChatStream(session.StockName, session.StockCode, question, session.SysPrompt(), tools, think)
 	for msg := range msgs {
-		runtime.EventsEmit(a.ctx, "newChatStream", msg)
+		msg["sessionId"] = session.ID
+		data.TopicChatStream.Publish(a.bus, msg)
+	}
+	data.TopicChatStream.Done(a.bus)
+}
+
+// GetChatContextSources AI分析可选的上下文来源,按合并到对话中的顺序
+func (a *App) GetChatContextSources() []data.ChatContextSource {
+	return data.ChatContextSources()
+}
+
+// GetChatSessions 对话会话列表,kind 为 stock(股票分析)、agent,为空时返回全部
+func (a *App) GetChatSessions(kind string) *[]data.ChatSession {
+	return data.NewChatSessionApi().GetSessions(kind)
+}
+
+// GetChatMessages 会话的全部消息
+func (a *App) GetChatMessages(sessionId uint) *[]data.ChatMessage {
+	return data.NewChatSessionApi().GetMessages(sessionId)
+}
+
+// RenameChatSession 修改会话标题
+func (a *App) RenameChatSession(sessionId uint, title string) string {
+	return data.NewChatSessionApi().RenameSession(sessionId, title)
+}
+
+// DeleteChatSession 删除会话及其消息
+func (a *App) DeleteChatSession(sessionId uint) string {
+	return data.NewChatSessionApi().DeleteSession(sessionId)
+}
+
+// GetChatSessionMarkdown 会话的 Markdown 内容,无界面模式下用于导出
+func (a *App) GetChatSessionMarkdown(sessionId uint) (string, error) {
+	return data.NewChatSessionApi().Export(sessionId)
+}
+
+// ExportChatSession 导出会话为 Markdown 文件
+func (a *App) ExportChatSession(sessionId uint) string {
+	content, err := data.NewChatSessionApi().Export(sessionId)
+	if err != nil {
+		return err.Error()
+	}
+	file, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:                "导出会话",
+		CanCreateDirectories: true,
+		DefaultFilename:      fmt.Sprintf("chat_%d.md", sessionId),
+	})
+	if err != nil {
+		logger.SugaredLogger.Errorf("导出会话失败:%s", err.Error())
+		return err.Error()
+	}
+	if file == "" {
+		return "已取消"
+	}
+	err = os.WriteFile(file, []byte(content), 0644)
+	if err != nil {
+		logger.SugaredLogger.Errorf("导出会话失败:%s", err.Error())
+		return err.Error()
 	}
-	runtime.EventsEmit(a.ctx, "newChatStream", "DONE")
+	return "导出成功:" + file
 }
 
 func (a *App) SaveAIResponseResult(stockCode, stockName, result, chatId, question string, aiConfigId int) {
@@ -1273,7 +1462,7 @@ func GenNotificationMsg(stockInfo *data.StockInfo) string {
 	return "[" + stockInfo.Name + "] " + stockInfo.Price + " " + convertor.ToString(RF) + "% " + stockInfo.Date + " " + stockInfo.Time
 }
 
-// msgType : 1 涨跌报警(5分钟);2 股价报警(30分钟) 3 成本价报警(30分钟)
+// msgType : 1 涨跌报警(5分钟);2 股价报警(30分钟) 3 成本价报警(30分钟) 4 新高新低报警(5分钟)
 func getMsgTypeTTL(msgType int) int {
 	switch msgType {
 	case 1:
@@ -1282,6 +1471,8 @@ func getMsgTypeTTL(msgType int) int {
 		return 60 * 30
 	case 3:
 		return 60 * 30
+	case 4:
+		return 60 * 5
 	default:
 		return 60 * 5
 	}
@@ -1295,6 +1486,8 @@ func getMsgTypeName(msgType int) string {
 		return "股价报警"
 	case 3:
 		return "成本价报警"
+	case 4:
+		return "新高新低报警"
 	default:
 		return "未知类型"
 	}
@@ -1311,17 +1504,18 @@ func (a *App) UpdateConfig(settingConfig *data.SettingConfig) string {
 	s1, _ := json.Marshal(settingConfig)
 	logger.SugaredLogger.Infof("UpdateConfig:%s", s1)
 	if settingConfig.RefreshInterval > 0 {
-		if entryID, exists := a.cronEntrys["MonitorStockPrices"]; exists {
-			a.cron.Remove(entryID)
-		}
-		id, _ := a.cron.AddFunc(fmt.Sprintf("@every %ds", settingConfig.RefreshInterval), func() {
+		a.addJob("MonitorStockPrices", fmt.Sprintf("@every %ds", settingConfig.RefreshInterval), "刷新关注股票行情", func() error {
 			//logger.SugaredLogger.Infof("MonitorStockPrices:%s", time.Now())
 			MonitorStockPrices(a)
+			return nil
 		})
-		a.cronEntrys["MonitorStockPrices"] = id
 	}
 
-	return data.UpdateConfig(settingConfig)
+	msg := data.UpdateConfig(settingConfig)
+	//赞助码可能已修改
+	a.refreshVipLevel()
+	a.scheduleNewsSources()
+	return msg
 }
 
 func (a *App) GetConfig() *data.SettingConfig {
@@ -1347,6 +1541,78 @@ func (a *App) ExportConfig() string {
 	return "导出成功:" + file
 }
 
+// GetSentimentWords 情感词表,kind 为空时返回全部
+func (a *App) GetSentimentWords(kind string) *[]data.SentimentWord {
+	return data.NewSentimentLexiconApi().GetWords(kind)
+}
+
+// SaveSentimentWord 新增或修改情感词
+func (a *App) SaveSentimentWord(kind, word string, weight float64) string {
+	return data.NewSentimentLexiconApi().SaveWord(kind, word, weight)
+}
+
+// DeleteSentimentWord 删除情感词
+func (a *App) DeleteSentimentWord(kind, word string) string {
+	return data.NewSentimentLexiconApi().DeleteWord(kind, word)
+}
+
+// ReloadSentimentLexicon 重新加载情感词表
+func (a *App) ReloadSentimentLexicon() string {
+	return fmt.Sprintf("已加载情感词%d个", data.NewSentimentLexiconApi().Reload())
+}
+
+// ExportSentimentLexicon 导出情感词表
+func (a *App) ExportSentimentLexicon() string {
+	file, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:                "导出情感词表",
+		CanCreateDirectories: true,
+		DefaultFilename:      "sentiment_lexicon.txt",
+	})
+	if err != nil {
+		logger.SugaredLogger.Errorf("导出情感词表失败:%s", err.Error())
+		return err.Error()
+	}
+	if file == "" {
+		return "已取消"
+	}
+	err = os.WriteFile(file, []byte(data.NewSentimentLexiconApi().Export()), 0644)
+	if err != nil {
+		logger.SugaredLogger.Errorf("导出情感词表失败:%s", err.Error())
+		return err.Error()
+	}
+	return "导出成功:" + file
+}
+
+// ImportSentimentLexicon 导入情感词表,已存在的词更新权重
+func (a *App) ImportSentimentLexicon() string {
+	file, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "导入情感词表",
+		Filters: []runtime.FileFilter{
+			{
+				DisplayName: "文本文件",
+				Pattern:     "*.txt",
+			},
+		},
+	})
+	if err != nil {
+		logger.SugaredLogger.Errorf("导入情感词表失败:%s", err.Error())
+		return err.Error()
+	}
+	if file == "" {
+		return "已取消"
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		logger.SugaredLogger.Errorf("导入情感词表失败:%s", err.Error())
+		return err.Error()
+	}
+	n, err := data.NewSentimentLexiconApi().Import(string(content))
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("导入成功:%d个", n)
+}
+
 func (a *App) ShareAnalysis(stockCode, stockName string) string {
 	//http://go-stock.sparkmemory.top:16688/upload
 	res := data.NewDeepSeekOpenAi(a.ctx, 0).GetAIResponseResult(stockCode)
@@ -1408,10 +1674,11 @@ func (a *App) GetPromptTemplates(name, promptType string) *[]models.PromptTempla
 }
 func (a *App) AddPrompt(prompt models.Prompt) string {
 	promptTemplate := models.PromptTemplate{
-		ID:      prompt.ID,
-		Content: prompt.Content,
-		Name:    prompt.Name,
-		Type:    prompt.Type,
+		ID:             prompt.ID,
+		Content:        prompt.Content,
+		Name:           prompt.Name,
+		Type:           prompt.Type,
+		ContextSources: prompt.ContextSources,
 	}
 	return data.NewPromptTemplateApi().AddPrompt(promptTemplate)
 }
@@ -1425,12 +1692,12 @@ func (a *App) SetStockAICron(cronText, stockCode string) {
 		stockCode = strings.Replace(stockCode, "gb_", "us", 1)
 		stockCode = strings.Replace(stockCode, "GB_", "us", 1)
 	}
-	if entryID, exists := a.cronEntrys[stockCode]; exists {
-		a.cron.Remove(entryID)
+	if strutil.Trim(cronText) == "" {
+		a.scheduler.Remove(stockAICronJob(stockCode))
+		return
 	}
 	follow := data.NewStockDataApi().GetFollowedStockByStockCode(stockCode)
-	id, _ := a.cron.AddFunc(cronText, a.AddCronTask(follow))
-	a.cronEntrys[stockCode] = id
+	a.addJob(stockAICronJob(stockCode), cronText, "自动分析:"+follow.Name, a.AddCronTask(follow))
 
 }
 func (a *App) AddGroup(group data.Group) string {
@@ -1485,7 +1752,53 @@ func (a *App) RemoveGroup(groupId int) string {
 }
 
 func (a *App) GetStockKLine(stockCode, stockName string, days int64) *[]data.KLineData {
-	return data.NewStockDataApi().GetHK_KLineData(stockCode, "day", days)
+	return data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, days)
+}
+
+// GetStockIndicators
+//
+//	@Description: 获取股票日线技术指标
+//	@receiver a
+func (a *App) GetStockIndicators(stockCode string, days int64) *indicators.Result {
+	return indicators.GetStockIndicators(stockCode, days)
+}
+
+// RunBacktest
+//
+//	@Description: 执行策略回测,失败时返回错误原因
+//	@receiver a
+func (a *App) RunBacktest(stockCode, strategyName string, days int64, initialCash float64) (*backtest.Result, error) {
+	res, err := backtest.RunStockBacktest(stockCode, strategyName, days, initialCash)
+	if err != nil {
+		logger.SugaredLogger.Errorf("RunBacktest error:%s", err.Error())
+		return nil, err
+	}
+	return res, nil
+}
+
+func (a *App) SaveBacktestAsMarkdown(stockCode, stockName, strategyName string, days int64, initialCash float64) string {
+	res, err := backtest.RunStockBacktest(stockCode, strategyName, days, initialCash)
+	if err != nil {
+		return err.Error()
+	}
+	file, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "保存为Markdown",
+		DefaultFilename: fmt.Sprintf("%s[%s]%s回测报告_%s.md", stockName, stockCode, res.Strategy, time.Now().Format("2006-01-02_15_04_05")),
+		Filters: []runtime.FileFilter{
+			{
+				DisplayName: "Markdown",
+				Pattern:     "*.md;*.markdown",
+			},
+		},
+	})
+	if err != nil {
+		return err.Error()
+	}
+	err = os.WriteFile(file, []byte(res.Markdown()), 0644)
+	if err != nil {
+		return err.Error()
+	}
+	return "已保存至：" + file
 }
 
 func (a *App) GetStockMinutePriceLineData(stockCode, stockName string) map[string]any {
@@ -1499,7 +1812,7 @@ func (a *App) GetStockMinutePriceLineData(stockCode, stockName string) map[strin
 }
 
 func (a *App) GetStockCommonKLine(stockCode, stockName string, days int64) *[]data.KLineData {
-	return data.NewStockDataApi().GetCommonKLineData(stockCode, "day", days)
+	return data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, days)
 }
 
 func (a *App) GetTelegraphList(source string) *[]*models.Telegraph {
@@ -1507,15 +1820,88 @@ func (a *App) GetTelegraphList(source string) *[]*models.Telegraph {
 	return telegraphs
 }
 
+// GetStockNews 提及该股票的资讯
+func (a *App) GetStockNews(stockCode string, limit int) *[]*models.Telegraph {
+	return data.NewNewsEntityApi().GetNewsByStockCodes([]string{stockCode}, limit)
+}
+
+// GetStockSentimentSeries 股票或板块的资讯情绪序列(period: hour/day)及同期日K线
+func (a *App) GetStockSentimentSeries(stockCode, period string, days int) *data.SentimentSeries {
+	return data.NewNewsSentimentApi().GetSentimentSeries(stockCode, period, days)
+}
+
+// GetFollowedSentimentSeries 关注股票的每日资讯情绪序列
+func (a *App) GetFollowedSentimentSeries(days int) []*data.SentimentSeries {
+	return slice.Map(*a.GetFollowList(0), func(_ int, follow data.FollowedStock) *data.SentimentSeries {
+		return data.NewNewsSentimentApi().GetSentimentSeries(follow.StockCode, data.SentimentPeriodDay, days)
+	})
+}
+
+// ClassifyNewsSentiment 分类资讯情绪,配置了情绪分类模型时使用模型,否则使用情感词典
+func (a *App) ClassifyNewsSentiment(ids []uint) []data.NewsClassification {
+	var telegraphs []models.Telegraph
+	if len(ids) > 0 {
+		db.Dao.Model(&models.Telegraph{}).Where("id in ?", ids).Find(&telegraphs)
+	}
+	return data.NewNewsClassifierApi().Classify(telegraphs)
+}
+
+// GetFollowedStockNews 提及任一关注股票的资讯
+func (a *App) GetFollowedStockNews(limit int) *[]*models.Telegraph {
+	codes := slice.Map(*a.GetFollowList(0), func(_ int, follow data.FollowedStock) string {
+		return follow.StockCode
+	})
+	return data.NewNewsEntityApi().GetNewsByStockCodes(codes, limit)
+}
+
+// SearchNews 全文检索电报资讯,from/to 格式 yyyy-MM-dd,可为空
+func (a *App) SearchNews(query, source, from, to string, page int) *data.NewsSearchResult {
+	return data.NewNewsSearchApi().SearchNews(query, source, from, to, page)
+}
+
 func (a *App) ReFleshTelegraphList(source string) *[]*models.Telegraph {
 	//data.NewMarketNewsApi().GetNewTelegraph(30)
-	go data.NewMarketNewsApi().TelegraphList(30)
-	go data.NewMarketNewsApi().GetSinaNews(30)
-	go data.NewMarketNewsApi().TradingViewNews()
+	a.fetchDueNewsSources()
 	telegraphs := data.NewMarketNewsApi().GetTelegraphList(source)
 	return telegraphs
 }
 
+// GetNewsSources 资讯来源及其设置
+func (a *App) GetNewsSources() []data.NewsSourceConfig {
+	return data.NewNewsSourceApi().GetNewsSources()
+}
+
+// UpdateNewsSource 启用或停用资讯来源并设置刷新间隔(秒,0 跟随行情刷新间隔)
+func (a *App) UpdateNewsSource(name string, enabled bool, interval int) string {
+	if enabled && !a.newsSourceAllowed(name) {
+		return "go-stock资讯仅对VIP2及以上赞助用户开放"
+	}
+	msg := data.NewNewsSourceApi().UpdateNewsSource(name, enabled, interval)
+	a.scheduleNewsSources()
+	return msg
+}
+
+// AddRssNewsSource 添加 RSS/Atom 订阅
+func (a *App) AddRssNewsSource(title, url string, interval int) string {
+	_, msg := data.NewNewsSourceApi().AddRssSource(title, url, interval)
+	a.scheduleNewsSources()
+	return msg
+}
+
+// DeleteNewsSource 删除 RSS 订阅
+func (a *App) DeleteNewsSource(name string) string {
+	msg := data.NewNewsSourceApi().DeleteNewsSource(name)
+	if _, ok := data.NewNewsSourceApi().GetNewsSource(name); !ok {
+		a.scheduler.Remove(newsSourceJob(name))
+	}
+	return msg
+}
+
+// RefreshNewsSource 立即拉取资讯来源
+func (a *App) RefreshNewsSource(name string) *[]models.Telegraph {
+	return a.fetchNewsSource(name)
+}
+
 func (a *App) GlobalStockIndexes() map[string]any {
 	return data.NewMarketNewsApi().GlobalStockIndexes(30)
 }
@@ -1529,9 +1915,9 @@ func (a *App) SummaryStockNews(question string, aiConfigId int, sysPromptId *int
 	}
 
 	for msg := range msgs {
-		runtime.EventsEmit(a.ctx, "summaryStockNews", msg)
+		data.TopicSummaryStream.Publish(a.bus, msg)
 	}
-	runtime.EventsEmit(a.ctx, "summaryStockNews", "DONE")
+	data.TopicSummaryStream.Done(a.bus)
 }
 func (a *App) GetIndustryRank(sort string, cnt int) []any {
 	res := data.NewMarketNewsApi().GetIndustryRank(sort, cnt)
@@ -1552,6 +1938,11 @@ func (a *App) GetStockMoneyTrendByDay(stockCode string, days int) []map[string]a
 	return res
 }
 
+// GetStockMoneyFlowHistory 本地保存的每日资金流向,按日期升序
+func (a *App) GetStockMoneyFlowHistory(stockCode string, days int) *[]data.MoneyFlowDaily {
+	return data.NewMoneyFlowApi().GetMoneyFlow(stockCode, days)
+}
+
 // OpenURL
 //
 //	@Description:  跨平台打开默认浏览器
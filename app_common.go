@@ -4,8 +4,6 @@ import (
 	"lumos-stock/backend/agent"
 	"lumos-stock/backend/data"
 	"lumos-stock/backend/models"
-
-	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // @Author spark
@@ -67,9 +65,25 @@ func (a *App) GetHotStrategy() map[string]any {
 }
 
 func (a *App) ChatWithAgent(question string, aiConfigId int, sysPromptId *int) {
-	ch := agent.NewStockAiAgentApi().Chat(question, aiConfigId, sysPromptId)
+	session := data.NewChatSessionApi().CreateSession(data.ChatKindAgent, question, "", "", aiConfigId, sysPromptId)
+	data.TopicChatSession.Publish(a.bus, session)
+	a.chatWithAgent(session, question)
+}
+
+// ContinueChatWithAgent 在已有的 agent 会话中继续提问
+func (a *App) ContinueChatWithAgent(sessionId uint, question string) {
+	session := data.NewChatSessionApi().GetSession(sessionId)
+	if session == nil {
+		data.TopicWarnMsg.Publish(a.bus, "会话不存在")
+		return
+	}
+	a.chatWithAgent(session, question)
+}
+
+func (a *App) chatWithAgent(session *data.ChatSession, question string) {
+	ch := agent.NewStockAiAgentApi().Chat(question, session.AiConfigId, session.SysPrompt(), session.ID)
 	for msg := range ch {
-		runtime.EventsEmit(a.ctx, "agent-message", msg)
+		data.TopicAgentMessage.Publish(a.bus, msg)
 	}
 }
 
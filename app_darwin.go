@@ -5,15 +5,12 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"lumos-stock/backend/data"
-	"lumos-stock/backend/db"
+	"lumos-stock/backend/events"
 	"lumos-stock/backend/logger"
 	"time"
 
-	"github.com/duke-git/lancet/v2/convertor"
-	"github.com/duke-git/lancet/v2/strutil"
 	"github.com/gen2brain/beeep"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -27,7 +24,9 @@ func (a *App) startup(ctx context.Context) {
 	})
 	logger.SugaredLogger.Infof("Version:%s", Version)
 	// Perform your setup here
-	a.ctx = ctx
+	a.ctx = events.WithBus(ctx, a.bus)
+	//事件转发到前端
+	a.bus.Subscribe(events.Async(events.NewWailsSubscriber(ctx), 1024))
 
 	// 监听设置更新事件
 	runtime.EventsOn(ctx, "updateSettings", func(optionalData ...interface{}) {
@@ -101,35 +100,13 @@ func OnSecondInstanceLaunch(secondInstanceData options.SecondInstanceData) {
 }
 
 func MonitorStockPrices(a *App) {
-	dest := &[]data.FollowedStock{}
-	db.Dao.Model(&data.FollowedStock{}).Find(dest)
-	total := float64(0)
-
-	// 股票信息处理逻辑
-	stockInfos := GetStockInfos(*dest...)
-	for _, stockInfo := range *stockInfos {
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"SZ", "SH", "sh", "sz"}) && (!isTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"hk", "HK"}) && (!IsHKTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"us", "US", "gb_"}) && (!IsUSTradingTime(time.Now())) {
-			continue
-		}
-
-		total += stockInfo.ProfitAmountToday
-		price, _ := convertor.ToFloat(stockInfo.Price)
-
-		if stockInfo.PrePrice != price {
-			go runtime.EventsEmit(a.ctx, "stock_price", stockInfo)
-		}
-	}
+	// 刷新行情、检查报警并触发实时利润事件
+	total, profit := refreshStockPrices(a)
 
 	// 计算总收益并更新状态
 	if total != 0 {
 		// 使用通知替代 systray 更新 Tooltip
-		title := "lumos-stock " + time.Now().Format(time.DateTime) + fmt.Sprintf("  %.2f¥", total)
+		title := "lumos-stock " + time.Now().Format(time.DateTime) + profit
 
 		// 发送通知显示实时数据
 		err := beeep.Notify("lumos-stock", title, "")
@@ -137,9 +114,6 @@ func MonitorStockPrices(a *App) {
 			logger.SugaredLogger.Errorf("发送通知失败: %v", err)
 		}
 	}
-
-	// 触发实时利润事件
-	go runtime.EventsEmit(a.ctx, "realtime_profit", fmt.Sprintf("  %.2f", total))
 }
 
 // onReady 在应用程序准备好时调用
@@ -183,8 +157,8 @@ func (a *App) beforeClose(ctx context.Context) (prevent bool) {
 		return true // 如果选择了取消，不关闭应用
 	} else {
 		// 在 macOS 上应用退出时执行清理工作
-		a.cron.Stop() // 停止定时任务
-		return false  // 如果选择了确定，继续关闭应用
+		a.scheduler.Stop() // 停止定时任务
+		return false       // 如果选择了确定，继续关闭应用
 	}
 }
 
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"lumos-stock/backend/data"
 	"lumos-stock/backend/db"
 	"lumos-stock/backend/logger"
 	"lumos-stock/backend/models"
@@ -62,3 +63,55 @@ func TestUpdateCheck(t *testing.T) {
 	}
 	logger.SugaredLogger.Infof("releaseVersion:%+v", releaseVersion)
 }
+
+func TestFilterPushNews(t *testing.T) {
+	// RSS 等来源按刷新间隔拉取,拉取到的资讯可能已发布一个间隔
+	interval := time.Now().Add(-300 * time.Second)
+	news := []models.Telegraph{
+		{Content: "普通快讯", IsRed: false},
+		{Content: "重要快讯", IsRed: true},
+		{Content: "浦发银行公告", IsRed: false},
+		{Content: "重要快讯(其他来源)", IsRed: true, ClusterId: 2},
+		{Content: "重要快讯(一个刷新间隔前)", IsRed: true, DataTime: &interval},
+	}
+	if got := filterPushNews(news, []string{"浦发银行"}, false); len(got) != 4 {
+		t.Errorf("all news except duplicates should be pushed, got %d", len(got))
+	}
+	got := filterPushNews(news, []string{"浦发银行"}, true)
+	if len(got) != 3 || got[0].Content != "重要快讯" || got[1].Content != "浦发银行公告" || got[2].Content != "重要快讯(一个刷新间隔前)" {
+		t.Errorf("unexpected filtered news %+v", got)
+	}
+
+	// 推送通过事件总线发布,无需 webview
+	app := NewApp()
+	var pushed []string
+	data.TopicNewsPush.Subscribe(app.bus, func(telegraph models.Telegraph) {
+		pushed = append(pushed, telegraph.Content)
+	})
+	for _, telegraph := range got {
+		data.TopicNewsPush.Publish(app.bus, telegraph)
+	}
+	if len(pushed) != 3 {
+		t.Errorf("unexpected pushed news %v", pushed)
+	}
+}
+
+func TestNewsSourceInterval(t *testing.T) {
+	if got := newsSourceInterval(data.NewsSourceConfig{Interval: 300}, 3); got != 300 {
+		t.Errorf("configured interval should be used, got %d", got)
+	}
+	if got := newsSourceInterval(data.NewsSourceConfig{}, 0); got != 11 {
+		t.Errorf("interval should follow the refresh interval, got %d", got)
+	}
+}
+
+func TestNewsSourceAllowed(t *testing.T) {
+	app := NewApp()
+	if !app.newsSourceAllowed(data.NewsSourceCls) || app.newsSourceAllowed(data.NewsSourceGoStock) {
+		t.Error("go-stock news should require VIP2")
+	}
+	app.vipLevel.Store(2)
+	if !app.newsSourceAllowed(data.NewsSourceGoStock) {
+		t.Error("VIP2 should be allowed")
+	}
+}
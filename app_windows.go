@@ -5,14 +5,11 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"lumos-stock/backend/data"
-	"lumos-stock/backend/db"
+	"lumos-stock/backend/events"
 	"lumos-stock/backend/logger"
 	"time"
 
-	"github.com/duke-git/lancet/v2/convertor"
-	"github.com/duke-git/lancet/v2/strutil"
 	"github.com/energye/systray"
 	"github.com/go-toast/toast"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -27,7 +24,9 @@ func (a *App) startup(ctx context.Context) {
 	})
 	logger.SugaredLogger.Infof("Version:%s", Version)
 	// Perform your setup here
-	a.ctx = ctx
+	a.ctx = events.WithBus(ctx, a.bus)
+	//事件转发到前端
+	a.bus.Subscribe(events.Async(events.NewWailsSubscriber(ctx), 1024))
 
 	// 创建系统托盘
 	//systray.RunWithExternalLoop(func() {
@@ -90,45 +89,11 @@ func OnSecondInstanceLaunch(secondInstanceData options.SecondInstanceData) {
 }
 
 func MonitorStockPrices(a *App) {
-	dest := &[]data.FollowedStock{}
-	db.Dao.Model(&data.FollowedStock{}).Find(dest)
-	total := float64(0)
-	//for _, follow := range *dest {
-	//	stockData := getStockInfo(follow)
-	//	total += stockData.ProfitAmountToday
-	//	price, _ := convertor.ToFloat(stockData.Price)
-	//	if stockData.PrePrice != price {
-	//		go runtime.EventsEmit(a.ctx, "stock_price", stockData)
-	//	}
-	//}
-
-	stockInfos := GetStockInfos(*dest...)
-	for _, stockInfo := range *stockInfos {
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"SZ", "SH", "sh", "sz"}) && (!isTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"hk", "HK"}) && (!IsHKTradingTime(time.Now())) {
-			continue
-		}
-		if strutil.HasPrefixAny(stockInfo.Code, []string{"us", "US", "gb_"}) && (!IsUSTradingTime(time.Now())) {
-			continue
-		}
-
-		total += stockInfo.ProfitAmountToday
-		price, _ := convertor.ToFloat(stockInfo.Price)
-
-		if stockInfo.PrePrice != price {
-			//logger.SugaredLogger.Infof("-----------sz------------股票代码: %s, 股票名称: %s, 股票价格: %s,盘前盘后:%s", stockInfo.Code, stockInfo.Name, stockInfo.Price, stockInfo.BA)
-			go runtime.EventsEmit(a.ctx, "stock_price", stockInfo)
-		}
-
-	}
+	total, profit := refreshStockPrices(a)
 	if total != 0 {
-		title := "lumos-stock " + time.Now().Format(time.DateTime) + fmt.Sprintf("  %.2f¥", total)
+		title := "lumos-stock " + time.Now().Format(time.DateTime) + profit
 		systray.SetTooltip(title)
 	}
-
-	go runtime.EventsEmit(a.ctx, "realtime_profit", fmt.Sprintf("  %.2f", total))
 	//runtime.WindowSetTitle(a.ctx, title)
 
 }
@@ -195,7 +160,7 @@ func (a *App) beforeClose(ctx context.Context) (prevent bool) {
 		return true
 	} else {
 		systray.Quit()
-		a.cron.Stop()
+		a.scheduler.Stop()
 		return false
 	}
 }
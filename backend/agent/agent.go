@@ -69,6 +69,8 @@ func GetStockAiAgent(ctx *context.Context, aiConfig data.AIConfig) *react.Agent
 			tools.GetQueryMarketNewsTool(),
 			tools.GetChoiceStockByIndicatorsTool(),
 			tools.GetStockKLineTool(),
+			tools.GetStockMoneyFlowTool(),
+			tools.GetStockIndicatorsTool(),
 			tools.GetInteractiveAnswerDataTool(),
 			tools.GetFinancialReportTool(),
 			tools.GetQueryStockNewsTool(),
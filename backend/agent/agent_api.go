@@ -2,6 +2,7 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/flow/agent"
@@ -12,6 +13,8 @@ import (
 	"lumos-stock/backend/data"
 	"lumos-stock/backend/logger"
 	"io"
+	"strings"
+	"sync"
 )
 
 // @Author spark
@@ -39,7 +42,8 @@ func (receiver StockAiAgent) newStockAiAgent(ctx *context.Context, aiConfigId in
 	}
 }
 
-func (receiver StockAiAgent) Chat(question string, aiConfigId int, sysPromptId *int) chan *schema.Message {
+// Chat agent 对话,sessionId 大于0时回放会话历史并在结束后保存本轮消息
+func (receiver StockAiAgent) Chat(question string, aiConfigId int, sysPromptId *int, sessionId uint) chan *schema.Message {
 	ch := make(chan *schema.Message, 512)
 	ctx := context.Background()
 	stockAiAgent := receiver.newStockAiAgent(&ctx, aiConfigId)
@@ -50,23 +54,50 @@ func (receiver StockAiAgent) Chat(question string, aiConfigId int, sysPromptId *
 	} else {
 		sysPrompt = data.NewPromptTemplateApi().GetPromptTemplateByID(*sysPromptId)
 	}
+	messages := []*schema.Message{
+		{
+			Role:    schema.System,
+			Content: sysPrompt,
+		},
+	}
+	if sessionId > 0 {
+		messages = append(messages, historyMessages(data.NewChatSessionApi().History(sessionId, data.ChatHistoryTokenBudget))...)
+	}
+	messages = append(messages, &schema.Message{
+		Role:    schema.User,
+		Content: question,
+	})
+
+	// 最后一次调用模型的输入,其中本轮新增的部分为工具调用和结果
+	var lastInput []*schema.Message
+	var inputLock sync.Mutex
 	agentOption := []agent.AgentOption{
-		agent.WithComposeOptions(compose.WithCallbacks(&tool_logger.LoggerCallback{MessageChanel: ch})),
+		agent.WithComposeOptions(compose.WithCallbacks(&tool_logger.LoggerCallback{
+			MessageChanel: ch,
+			OnModelInput: func(input []*schema.Message) {
+				inputLock.Lock()
+				defer inputLock.Unlock()
+				lastInput = input
+			},
+		})),
 		//react.WithChatModelOptions(ark.WithCache(cacheOption)),
 	}
 
 	go func() {
 		defer close(ch)
-		sr, err := stockAiAgent.Stream(ctx, []*schema.Message{
-			{
-				Role:    schema.System,
-				Content: sysPrompt,
-			},
-			{
-				Role:    schema.User,
-				Content: question,
-			},
-		}, agentOption...)
+		var content, reasoning strings.Builder
+		defer func() {
+			if sessionId == 0 || strings.TrimSpace(content.String()) == "" {
+				return
+			}
+			inputLock.Lock()
+			toolMessages := lo.Slice(lastInput, len(messages), len(lastInput))
+			inputLock.Unlock()
+			saves := append([]data.ChatMessage{{Role: data.ChatRoleUser, Content: question}}, sessionMessages(toolMessages)...)
+			saves = append(saves, data.ChatMessage{Role: data.ChatRoleAssistant, Content: content.String(), ReasoningContent: reasoning.String()})
+			_ = data.NewChatSessionApi().AppendMessages(sessionId, saves...)
+		}()
+		sr, err := stockAiAgent.Stream(ctx, messages, agentOption...)
 		if err != nil {
 			logger.SugaredLogger.Errorf("stream error: %v", err)
 			return
@@ -84,8 +115,43 @@ func (receiver StockAiAgent) Chat(question string, aiConfigId int, sysPromptId *
 				break
 			}
 			logger.SugaredLogger.Infof("stream: %s", msg.String())
+			content.WriteString(msg.Content)
+			reasoning.WriteString(msg.ReasoningContent)
 			ch <- msg
 		}
 	}()
 	return ch
 }
+
+// historyMessages 会话历史转换为 agent 消息
+func historyMessages(history []data.ChatMessage) []*schema.Message {
+	return lo.Map(history, func(item data.ChatMessage, _ int) *schema.Message {
+		message := &schema.Message{
+			Role:             schema.RoleType(item.Role),
+			Content:          item.Content,
+			ReasoningContent: item.ReasoningContent,
+			ToolCallID:       item.ToolCallId,
+		}
+		if item.ToolCalls != "" {
+			_ = json.Unmarshal([]byte(item.ToolCalls), &message.ToolCalls)
+		}
+		return message
+	})
+}
+
+// sessionMessages agent 的工具调用和结果转换为会话消息
+func sessionMessages(messages []*schema.Message) []data.ChatMessage {
+	return lo.Map(messages, func(item *schema.Message, _ int) data.ChatMessage {
+		message := data.ChatMessage{
+			Role:             string(item.Role),
+			Content:          item.Content,
+			ReasoningContent: item.ReasoningContent,
+			ToolCallId:       item.ToolCallID,
+		}
+		if len(item.ToolCalls) > 0 {
+			bytes, _ := json.Marshal(item.ToolCalls)
+			message.ToolCalls = string(bytes)
+		}
+		return message
+	})
+}
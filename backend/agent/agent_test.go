@@ -76,7 +76,7 @@ func TestGetStockAiAgent(t *testing.T) {
 func TestAgent(t *testing.T) {
 	db.Init("../../data/stock.db")
 
-	ch := NewStockAiAgentApi().Chat("分析一下海立股份，使用工具", 1, nil)
+	ch := NewStockAiAgentApi().Chat("分析一下海立股份，使用工具", 1, nil, 0)
 	for message := range ch {
 		logger.SugaredLogger.Infof("res:%s", message.String())
 	}
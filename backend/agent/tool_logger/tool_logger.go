@@ -20,7 +20,8 @@ import (
 
 type LoggerCallback struct {
 	MessageChanel            chan *schema.Message
-	callbacks.HandlerBuilder // 可以用 callbacks.HandlerBuilder 来辅助实现 callback
+	OnModelInput             func(messages []*schema.Message) // 每次调用模型前的完整输入,用于记录工具调用和结果
+	callbacks.HandlerBuilder                                  // 可以用 callbacks.HandlerBuilder 来辅助实现 callback
 }
 
 func (cb *LoggerCallback) OnStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
@@ -33,6 +34,9 @@ func (cb *LoggerCallback) OnStart(ctx context.Context, info *callbacks.RunInfo,
 		for _, message := range modelCallbackInput.Messages {
 			cb.MessageChanel <- message
 		}
+		if cb.OnModelInput != nil {
+			cb.OnModelInput(modelCallbackInput.Messages)
+		}
 	}
 	return ctx
 }
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"lumos-stock/backend/indicators"
+	"lumos-stock/backend/util"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/tidwall/gjson"
+)
+
+// @Author spark
+// @Date 2025/9/12 15:10
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func GetStockIndicatorsTool() tool.InvokableTool {
+	return &QueryStockIndicators{}
+}
+
+type QueryStockIndicators struct {
+}
+
+func (q QueryStockIndicators) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: "QueryStockIndicators",
+		Desc: "获取股票日线技术指标(MA/EMA、MACD、KDJ、RSI、BOLL、ATR、OBV、VWAP)及最新技术信号。输入股票代码和天数，返回已计算好的指标数据。",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"days": {
+				Type:     "string",
+				Desc:     "返回最近多少个交易日的指标数据。",
+				Required: true,
+			},
+			"stockCode": {
+				Type:     "string",
+				Desc:     "股票代码（A股：sh,sz开头;港股hk开头,美股：us开头）",
+				Required: true,
+			},
+		}),
+	}, nil
+}
+
+func (q QueryStockIndicators) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	stockCode := GetStockCode(gjson.Get(argumentsInJSON, "stockCode").String())
+	toIntDay, err := convertor.ToInt(gjson.Get(argumentsInJSON, "days").String())
+	if err != nil || toIntDay <= 0 {
+		toIntDay = 30
+	}
+	if !strutil.HasPrefixAny(stockCode, []string{"sz", "sh", "hk", "us", "gb_"}) {
+		return "无数据，可能股票代码错误。（A股：sh,sz开头;港股hk开头,美股：us开头）", fmt.Errorf("不支持的股票代码:%s", stockCode)
+	}
+	res := indicators.GetStockIndicators(stockCode, toIntDay)
+	if len(res.Rows) == 0 {
+		return "无K线数据，无法计算技术指标", nil
+	}
+	var md strings.Builder
+	md.WriteString("\r\n ### " + stockCode + " 最新技术信号：\r\n")
+	for _, signal := range res.Signals {
+		md.WriteString("- " + signal + "\r\n")
+	}
+	md.WriteString(util.MarkdownTableWithTitle(stockCode+" "+convertor.ToString(toIntDay)+"日技术指标", res.Rows))
+	return md.String(), nil
+}
@@ -51,13 +51,7 @@ func (q QueryStockKLine) InvokableRun(ctx context.Context, argumentsInJSON strin
 		toIntDay = 90
 	}
 	if strutil.HasPrefixAny(stockCode, []string{"sz", "sh", "hk", "us", "gb_"}) {
-		K := &[]data.KLineData{}
-		if strutil.HasPrefixAny(stockCode, []string{"sz", "sh"}) {
-			K = data.NewStockDataApi().GetKLineData(stockCode, "240", toIntDay)
-		}
-		if strutil.HasPrefixAny(stockCode, []string{"hk", "us", "gb_"}) {
-			K = data.NewStockDataApi().GetHK_KLineData(stockCode, "day", toIntDay)
-		}
+		K := data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, toIntDay)
 		Kmap := &[]map[string]any{}
 		for _, kline := range *K {
 			mapk := make(map[string]any, 6)
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/mathutil"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/tidwall/gjson"
+	"lumos-stock/backend/data"
+)
+
+// @Author spark
+// @Date 2025/9/30 15:10
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func GetStockMoneyFlowTool() tool.InvokableTool {
+	return &QueryStockMoneyFlow{}
+}
+
+type QueryStockMoneyFlow struct {
+}
+
+func (q QueryStockMoneyFlow) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: "QueryStockMoneyFlow",
+		Desc: "获取A股个股每日资金流向数据。输入股票代码和天数，返回每日主力、超大单、大单、中单、小单(散户)净流入。",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"days": {
+				Type:     "string",
+				Desc:     "资金流向数据天数。",
+				Required: true,
+			},
+			"stockCode": {
+				Type:     "string",
+				Desc:     "股票代码（A股：sh,sz,bj开头）",
+				Required: true,
+			},
+		}),
+	}, nil
+}
+
+func (q QueryStockMoneyFlow) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	stockCode := GetStockCode(gjson.Get(argumentsInJSON, "stockCode").String())
+	days, err := convertor.ToInt(gjson.Get(argumentsInJSON, "days").String())
+	if err != nil {
+		days = 30
+	}
+	if !strutil.HasPrefixAny(stockCode, []string{"sh", "sz", "bj"}) {
+		return "无数据，资金流向只支持A股。（A股：sh,sz,bj开头）", fmt.Errorf("不支持的股票代码:%s", stockCode)
+	}
+	rows := data.NewMoneyFlowApi().GetMoneyFlow(stockCode, int(days))
+	if len(*rows) == 0 {
+		return "暂无资金流向数据", nil
+	}
+	flows := &[]map[string]any{}
+	for _, row := range *rows {
+		flow := make(map[string]any, 8)
+		flow["日期"] = row.Day
+		flow["收盘价"] = row.Close
+		flow["涨跌幅(%)"] = row.ChangePercent
+		flow["主力净流入(万元)"] = mathutil.RoundToFloat(row.MainNet/10000, 2)
+		flow["主力净占比(%)"] = row.MainNetRatio
+		flow["超大单净流入(万元)"] = mathutil.RoundToFloat(row.SuperLargeNet/10000, 2)
+		flow["大单净流入(万元)"] = mathutil.RoundToFloat(row.LargeNet/10000, 2)
+		flow["中单净流入(万元)"] = mathutil.RoundToFloat(row.MediumNet/10000, 2)
+		flow["小单(散户)净流入(万元)"] = mathutil.RoundToFloat(row.SmallNet/10000, 2)
+		*flows = append(*flows, flow)
+	}
+	jsonData, _ := json.Marshal(flows)
+	markdownTable, _ := JSONToMarkdownTable(jsonData)
+	return "\r\n ### " + stockCode + " 近" + convertor.ToString(len(*rows)) + "日资金流向：\r\n" + markdownTable + "\r\n", nil
+}
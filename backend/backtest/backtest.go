@@ -0,0 +1,216 @@
+package backtest
+
+import (
+	"fmt"
+	"lumos-stock/backend/calendar"
+	"lumos-stock/backend/data"
+	"lumos-stock/backend/indicators"
+	"lumos-stock/backend/util"
+	"strings"
+
+	"github.com/duke-git/lancet/v2/strutil"
+)
+
+// @Author spark
+// @Date 2025/9/16 10:00
+// @Desc 策略回测引擎
+// 每根日K线收盘后调用策略 OnBar,产生的委托在下一根K线开盘价撮合
+// -----------------------------------------------------------------------------------
+
+type Bar = indicators.Bar
+
+// Strategy 回测策略
+type Strategy interface {
+	Name() string
+	OnStart(ctx *Context)
+	OnBar(ctx *Context, bar Bar)
+}
+
+// Config 回测参数
+type Config struct {
+	InitialCash float64  `json:"initialCash"`
+	LotSize     int64    `json:"lotSize"`
+	TPlusOne    bool     `json:"tPlusOne"`
+	PriceLimit  float64  `json:"priceLimit"`
+	Fee         FeeModel `json:"-"`
+}
+
+// DefaultConfig 按股票所属市场确定交易规则:A股一手100股、T+1、按股票代码确定涨跌幅限制;
+// 港股美股 T+0、无涨跌幅限制,港股每手股数因股票而异,按1股计算
+func DefaultConfig(stockCode string, initialCash float64) Config {
+	if initialCash <= 0 {
+		initialCash = 100000
+	}
+	switch calendar.ForStock(stockCode) {
+	case calendar.HKEX:
+		return Config{InitialCash: initialCash, LotSize: 1, Fee: DefaultHKShareFee()}
+	case calendar.NYSE:
+		return Config{InitialCash: initialCash, LotSize: 1, Fee: DefaultUSShareFee()}
+	}
+	return Config{
+		InitialCash: initialCash,
+		LotSize:     100,
+		TPlusOne:    true,
+		PriceLimit:  PriceLimitOf(stockCode),
+		Fee:         DefaultAShareFee(),
+	}
+}
+
+// PriceLimitOf 涨跌幅限制:主板10%,创业板/科创板20%,北交所30%
+func PriceLimitOf(stockCode string) float64 {
+	code := strings.ToLower(stockCode)
+	switch {
+	case strings.HasPrefix(code, "bj"):
+		return 0.3
+	case strutil.HasPrefixAny(code, []string{"sh688", "sh689", "sz300", "sz301"}):
+		return 0.2
+	case strutil.HasPrefixAny(code, []string{"sh", "sz"}):
+		return 0.1
+	}
+	return 0
+}
+
+// Context 策略运行上下文
+type Context struct {
+	Code   string
+	bars   []Bar
+	index  int
+	broker *Broker
+}
+
+// History 截止当前K线(含)的历史K线
+func (c *Context) History() []Bar {
+	return c.bars[:c.index+1]
+}
+
+func (c *Context) Cash() float64 {
+	return c.broker.Cash
+}
+
+func (c *Context) Position() Position {
+	return c.broker.Position
+}
+
+func (c *Context) Buy(shares int64, reason string) {
+	c.broker.submit(Order{Day: c.bars[c.index].Day, Side: Buy, Shares: shares, Reason: reason})
+}
+
+func (c *Context) Sell(shares int64, reason string) {
+	c.broker.submit(Order{Day: c.bars[c.index].Day, Side: Sell, Shares: shares, Reason: reason})
+}
+
+// BuyPercent 按当前收盘价估算,用可用资金的 percent(0-1) 买入
+func (c *Context) BuyPercent(percent float64, reason string) {
+	bar := c.bars[c.index]
+	c.Buy(c.broker.affordable(bar.Close, c.broker.Cash*percent), reason)
+}
+
+// SellAll 卖出全部持仓
+func (c *Context) SellAll(reason string) {
+	c.Sell(c.broker.Position.Shares, reason)
+}
+
+// EquityPoint 权益曲线
+type EquityPoint struct {
+	Day      string  `json:"day" md:"日期"`
+	Close    float64 `json:"close" md:"收盘价"`
+	Cash     float64 `json:"cash" md:"现金"`
+	Shares   int64   `json:"shares" md:"持仓"`
+	Equity   float64 `json:"equity" md:"总资产"`
+	Drawdown float64 `json:"drawdown" md:"回撤(%)"`
+}
+
+// Result 回测结果
+type Result struct {
+	Code       string        `json:"code"`
+	Strategy   string        `json:"strategy"`
+	Config     Config        `json:"config"`
+	Stats      Stats         `json:"stats"`
+	Equity     []EquityPoint `json:"equity"`
+	Trades     []Trade       `json:"trades"`
+	Rejections []Order       `json:"rejections"`
+}
+
+// Run 执行回测,bars 需按日期升序
+func Run(stockCode string, bars []Bar, strategy Strategy, config Config) (*Result, error) {
+	if strategy == nil {
+		return nil, fmt.Errorf("策略不能为空")
+	}
+	if len(bars) == 0 {
+		return nil, fmt.Errorf("%s 无K线数据", stockCode)
+	}
+	if config.Fee == nil {
+		config.Fee = DefaultAShareFee()
+	}
+	broker := newBroker(config)
+	ctx := &Context{Code: stockCode, bars: bars, broker: broker}
+	strategy.OnStart(ctx)
+
+	equity := make([]EquityPoint, 0, len(bars))
+	peak := config.InitialCash
+	for i, bar := range bars {
+		broker.settle(bar.Day)
+		if i > 0 {
+			broker.fill(bar, bars[i-1].Close)
+		}
+		ctx.index = i
+		strategy.OnBar(ctx, bar)
+
+		value := broker.equity(bar.Close)
+		if value > peak {
+			peak = value
+		}
+		drawdown := 0.0
+		if peak > 0 {
+			drawdown = (peak - value) / peak * 100
+		}
+		equity = append(equity, EquityPoint{
+			Day:      bar.Day,
+			Close:    bar.Close,
+			Cash:     round2(broker.Cash),
+			Shares:   broker.Position.Shares,
+			Equity:   round2(value),
+			Drawdown: round2(drawdown),
+		})
+	}
+	return &Result{
+		Code:       stockCode,
+		Strategy:   strategy.Name(),
+		Config:     config,
+		Stats:      calcStats(config.InitialCash, equity, broker.Trades),
+		Equity:     equity,
+		Trades:     broker.Trades,
+		Rejections: broker.Rejections,
+	}, nil
+}
+
+// RunStockBacktest 读取本地日K线执行回测
+func RunStockBacktest(stockCode, strategyName string, days int64, initialCash float64) (*Result, error) {
+	strategy, err := NewStrategy(strategyName)
+	if err != nil {
+		return nil, err
+	}
+	if days <= 0 {
+		days = 365
+	}
+	K := data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, days)
+	return Run(stockCode, indicators.FromKLine(*K), strategy, DefaultConfig(stockCode, initialCash))
+}
+
+// Markdown 导出回测报告
+func (r *Result) Markdown() string {
+	var md strings.Builder
+	md.WriteString("# " + r.Code + " " + r.Strategy + " 回测报告\n")
+	priceLimit := "无"
+	if r.Config.PriceLimit > 0 {
+		priceLimit = fmt.Sprintf("%.0f%%", r.Config.PriceLimit*100)
+	}
+	md.WriteString(fmt.Sprintf("\n初始资金:%.2f 一手:%d股 T+1:%v 涨跌幅限制:%s\n",
+		r.Config.InitialCash, r.Config.LotSize, r.Config.TPlusOne, priceLimit))
+	md.WriteString("\n## 统计\n")
+	md.WriteString(util.MarkdownTable(r.Stats))
+	md.WriteString(util.MarkdownTableWithTitle("成交记录", r.Trades))
+	md.WriteString(util.MarkdownTableWithTitle("未成交委托", r.Rejections))
+	md.WriteString(util.MarkdownTableWithTitle("权益曲线", r.Equity))
+	return md.String()
+}
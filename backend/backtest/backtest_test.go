@@ -0,0 +1,180 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/16 14:00
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func makeBars(closes ...float64) []Bar {
+	start, _ := time.Parse(time.DateOnly, "2025-01-02")
+	bars := make([]Bar, len(closes))
+	for i, c := range closes {
+		bars[i] = Bar{
+			Day:    start.AddDate(0, 0, i).Format(time.DateOnly),
+			Open:   c,
+			High:   c,
+			Low:    c,
+			Close:  c,
+			Volume: 1000,
+		}
+	}
+	return bars
+}
+
+// scripted 按K线序号执行预设操作
+type scripted struct {
+	actions map[int]func(ctx *Context)
+	i       int
+}
+
+func (s *scripted) Name() string { return "scripted" }
+
+func (s *scripted) OnStart(ctx *Context) { s.i = 0 }
+
+func (s *scripted) OnBar(ctx *Context, bar Bar) {
+	if action, ok := s.actions[s.i]; ok {
+		action(ctx)
+	}
+	s.i++
+}
+
+func TestFee(t *testing.T) {
+	fee := DefaultAShareFee()
+	if c := fee.Commission(Buy, 10000); c != 5.1 {
+		t.Errorf("min commission expected 5.1, got %v", c)
+	}
+	if c := fee.Commission(Sell, 1000000); c != 260 {
+		t.Errorf("commission expected 260, got %v", c)
+	}
+	if s := fee.StampDuty(Buy, 10000); s != 0 {
+		t.Errorf("stamp duty only applies to sells, got %v", s)
+	}
+	if s := fee.StampDuty(Sell, 10000); s != 5 {
+		t.Errorf("stamp duty expected 5, got %v", s)
+	}
+}
+
+func TestLotAndTPlusOne(t *testing.T) {
+	strategy := &scripted{actions: map[int]func(ctx *Context){
+		0: func(ctx *Context) { ctx.Buy(250, "buy") },
+		// 第1根K线开盘成交,当日卖出会在第2根撮合,此时已可卖
+		1: func(ctx *Context) {
+			if ctx.Position().Available != 0 {
+				t.Errorf("shares bought today should not be available")
+			}
+			ctx.Sell(150, "sell")
+		},
+	}}
+	res, err := Run("sh600000", makeBars(10, 10, 10, 10), strategy, DefaultConfig("sh600000", 100000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trades) != 2 {
+		t.Fatalf("expected 2 trades, got %+v", res.Trades)
+	}
+	if res.Trades[0].Shares != 200 || res.Trades[1].Shares != 100 {
+		t.Errorf("orders should be rounded to lots: %+v", res.Trades)
+	}
+	if res.Equity[len(res.Equity)-1].Shares != 100 {
+		t.Errorf("expected 100 shares left, got %d", res.Equity[len(res.Equity)-1].Shares)
+	}
+}
+
+func TestSameDaySellRejected(t *testing.T) {
+	config := DefaultConfig("sh600000", 100000)
+	broker := newBroker(config)
+	bar := makeBars(10)[0]
+	broker.settle(bar.Day)
+	broker.submit(Order{Side: Buy, Shares: 100})
+	broker.submit(Order{Side: Sell, Shares: 100})
+	broker.fill(bar, 10)
+	if len(broker.Trades) != 1 || len(broker.Rejections) != 1 {
+		t.Errorf("same day sell should be rejected under T+1: %+v %+v", broker.Trades, broker.Rejections)
+	}
+}
+
+func TestMarketRules(t *testing.T) {
+	for _, code := range []string{"hk00700", "gb_aapl", "us.AAPL"} {
+		config := DefaultConfig(code, 100000)
+		if config.LotSize != 1 || config.TPlusOne || config.PriceLimit != 0 {
+			t.Errorf("%s should trade T+0 without lots or limits: %+v", code, config)
+		}
+	}
+	strategy := &scripted{actions: map[int]func(ctx *Context){
+		0: func(ctx *Context) { ctx.Buy(250, "buy") },
+		1: func(ctx *Context) { ctx.Sell(250, "sell") },
+	}}
+	// 港股次日大涨20%仍可成交,当日买入可卖出
+	res, err := Run("hk00700", makeBars(10, 12, 12), strategy, DefaultConfig("hk00700", 100000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trades) != 2 || res.Trades[0].Shares != 250 || res.Trades[0].StampDuty != 3 {
+		t.Errorf("unexpected hk trades %+v %+v", res.Trades, res.Rejections)
+	}
+}
+
+func TestLimitBlocking(t *testing.T) {
+	strategy := &scripted{actions: map[int]func(ctx *Context){
+		0: func(ctx *Context) { ctx.Buy(100, "buy") },
+	}}
+	// 次日一字涨停,买单无法成交
+	res, err := Run("sh600000", makeBars(10, 11, 11), strategy, DefaultConfig("sh600000", 100000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trades) != 0 || len(res.Rejections) != 1 {
+		t.Errorf("limit up buy should be blocked: %+v", res)
+	}
+	// 创业板20%涨跌幅,10%的涨幅可以成交
+	res, _ = Run("sz300750", makeBars(10, 11, 11), strategy, DefaultConfig("sz300750", 100000))
+	if len(res.Trades) != 1 {
+		t.Errorf("20%% limit board should fill at +10%%: %+v", res.Rejections)
+	}
+}
+
+func TestStats(t *testing.T) {
+	bars := makeBars(10, 10, 12, 10, 11)
+	strategy := &scripted{actions: map[int]func(ctx *Context){
+		0: func(ctx *Context) { ctx.BuyPercent(1, "buy") },
+		2: func(ctx *Context) { ctx.SellAll("sell") },
+	}}
+	config := DefaultConfig("sz300750", 100000)
+	res, err := Run("sz300750", bars, strategy, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stats.ClosedTrades != 1 || res.Stats.WinRate != 0 {
+		t.Errorf("sold at a loss, unexpected stats %+v", res.Stats)
+	}
+	if res.Stats.MaxDrawdown <= 0 {
+		t.Errorf("expected drawdown, got %+v", res.Stats)
+	}
+	if math.IsNaN(res.Stats.Sharpe) || math.IsNaN(res.Stats.CAGR) {
+		t.Errorf("stats should be finite %+v", res.Stats)
+	}
+	t.Log(res.Markdown())
+}
+
+func TestMACross(t *testing.T) {
+	closes := make([]float64, 0, 120)
+	for i := 0; i < 120; i++ {
+		closes = append(closes, math.Round((10+2*math.Sin(float64(i)/8))*100)/100)
+	}
+	res, err := Run("sh600000", makeBars(closes...), &MACross{Fast: 5, Slow: 20}, DefaultConfig("sh600000", 100000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trades) == 0 {
+		t.Error("expected ma cross trades")
+	}
+	if len(res.Equity) != len(closes) {
+		t.Errorf("equity curve should cover every bar")
+	}
+}
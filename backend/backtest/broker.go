@@ -0,0 +1,218 @@
+package backtest
+
+import (
+	"math"
+	"strings"
+)
+
+// @Author spark
+// @Date 2025/9/16 10:45
+// @Desc 模拟券商:T+1交收、100股一手、涨跌停无法成交
+// -----------------------------------------------------------------------------------
+
+type Side string
+
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
+// Order 委托,在下一根K线开盘价成交
+type Order struct {
+	Day    string `json:"day" md:"委托日期"`
+	Side   Side   `json:"side" md:"方向"`
+	Shares int64  `json:"shares" md:"数量"`
+	Reason string `json:"reason" md:"说明"`
+}
+
+// Trade 成交记录,Profit 为卖出时的已实现盈亏(已扣除买卖费用)
+type Trade struct {
+	Day        string  `json:"day" md:"成交日期"`
+	Side       Side    `json:"side" md:"方向"`
+	Price      float64 `json:"price" md:"成交价"`
+	Shares     int64   `json:"shares" md:"数量"`
+	Amount     float64 `json:"amount" md:"成交金额"`
+	Commission float64 `json:"commission" md:"佣金"`
+	StampDuty  float64 `json:"stampDuty" md:"印花税"`
+	Profit     float64 `json:"profit" md:"盈亏"`
+	Reason     string  `json:"reason" md:"说明"`
+}
+
+// Position 持仓,Available 为可卖数量(T+1)
+type Position struct {
+	Shares    int64   `json:"shares"`
+	Available int64   `json:"available"`
+	AvgCost   float64 `json:"avgCost"`
+}
+
+type Broker struct {
+	config     Config
+	Cash       float64
+	Position   Position
+	Trades     []Trade
+	Rejections []Order
+	pending    []Order
+	day        string
+}
+
+func newBroker(config Config) *Broker {
+	return &Broker{config: config, Cash: config.InitialCash}
+}
+
+// settle 进入新交易日,前一日买入的股份变为可卖
+func (b *Broker) settle(day string) {
+	if b.day == day {
+		return
+	}
+	b.day = day
+	if b.config.TPlusOne {
+		b.Position.Available = b.Position.Shares
+	}
+}
+
+func (b *Broker) submit(order Order) {
+	if order.Shares <= 0 {
+		return
+	}
+	b.pending = append(b.pending, order)
+}
+
+// fill 以当前K线开盘价撮合前一交易日的委托
+func (b *Broker) fill(bar Bar, prevClose float64) {
+	orders := b.pending
+	b.pending = nil
+	for _, order := range orders {
+		if reason := b.blocked(order.Side, bar, prevClose); reason != "" {
+			order.Reason = reason
+			b.Rejections = append(b.Rejections, order)
+			continue
+		}
+		switch order.Side {
+		case Buy:
+			b.buy(order, bar)
+		case Sell:
+			b.sell(order, bar)
+		}
+	}
+}
+
+// blocked 开盘即涨停无法买入,开盘即跌停无法卖出
+func (b *Broker) blocked(side Side, bar Bar, prevClose float64) string {
+	limit := b.config.PriceLimit
+	if limit <= 0 || prevClose <= 0 {
+		return ""
+	}
+	limitUp := round2(prevClose * (1 + limit))
+	limitDown := round2(prevClose * (1 - limit))
+	if side == Buy && bar.Open >= limitUp {
+		return "涨停无法买入"
+	}
+	if side == Sell && bar.Open <= limitDown {
+		return "跌停无法卖出"
+	}
+	return ""
+}
+
+func (b *Broker) lot(shares int64) int64 {
+	if b.config.LotSize <= 1 {
+		return shares
+	}
+	return shares / b.config.LotSize * b.config.LotSize
+}
+
+func (b *Broker) buy(order Order, bar Bar) {
+	price := bar.Open
+	shares := b.lot(order.Shares)
+	//资金不足时按可买数量成交
+	for shares > 0 {
+		amount := price * float64(shares)
+		if amount+b.config.Fee.Commission(Buy, amount)+b.config.Fee.StampDuty(Buy, amount) <= b.Cash {
+			break
+		}
+		step := b.config.LotSize
+		if step <= 1 {
+			step = 1
+		}
+		shares -= step
+	}
+	if shares <= 0 {
+		order.Reason = "资金不足"
+		b.Rejections = append(b.Rejections, order)
+		return
+	}
+	amount := price * float64(shares)
+	commission := b.config.Fee.Commission(Buy, amount)
+	//港股买入也需缴纳印花税
+	stampDuty := b.config.Fee.StampDuty(Buy, amount)
+	cost := b.Position.AvgCost*float64(b.Position.Shares) + amount + commission + stampDuty
+	b.Cash -= amount + commission + stampDuty
+	b.Position.Shares += shares
+	b.Position.AvgCost = cost / float64(b.Position.Shares)
+	if !b.config.TPlusOne {
+		b.Position.Available += shares
+	}
+	b.Trades = append(b.Trades, Trade{
+		Day:        bar.Day,
+		Side:       Buy,
+		Price:      price,
+		Shares:     shares,
+		Amount:     round2(amount),
+		Commission: commission,
+		StampDuty:  stampDuty,
+		Reason:     order.Reason,
+	})
+}
+
+func (b *Broker) sell(order Order, bar Bar) {
+	price := bar.Open
+	shares := order.Shares
+	if shares > b.Position.Available {
+		shares = b.Position.Available
+	}
+	//零股只能一次性卖出
+	if shares != b.Position.Shares {
+		shares = b.lot(shares)
+	}
+	if shares <= 0 {
+		if b.Position.Shares > 0 {
+			order.Reason = strings.TrimSpace(order.Reason + " T+1当日买入不可卖出")
+		} else {
+			order.Reason = strings.TrimSpace(order.Reason + " 无持仓")
+		}
+		b.Rejections = append(b.Rejections, order)
+		return
+	}
+	amount := price * float64(shares)
+	commission := b.config.Fee.Commission(Sell, amount)
+	stampDuty := b.config.Fee.StampDuty(Sell, amount)
+	profit := amount - commission - stampDuty - b.Position.AvgCost*float64(shares)
+	b.Cash += amount - commission - stampDuty
+	b.Position.Shares -= shares
+	b.Position.Available -= shares
+	if b.Position.Shares == 0 {
+		b.Position.AvgCost = 0
+	}
+	b.Trades = append(b.Trades, Trade{
+		Day:        bar.Day,
+		Side:       Sell,
+		Price:      price,
+		Shares:     shares,
+		Amount:     round2(amount),
+		Commission: commission,
+		StampDuty:  stampDuty,
+		Profit:     round2(profit),
+		Reason:     order.Reason,
+	})
+}
+
+func (b *Broker) equity(price float64) float64 {
+	return b.Cash + price*float64(b.Position.Shares)
+}
+
+// affordable 按参考价格估算可买数量
+func (b *Broker) affordable(price, cash float64) int64 {
+	if price <= 0 {
+		return 0
+	}
+	return b.lot(int64(math.Floor(cash / price)))
+}
@@ -0,0 +1,119 @@
+package backtest
+
+import "math"
+
+// @Author spark
+// @Date 2025/9/16 10:20
+// @Desc 交易费用模型
+// -----------------------------------------------------------------------------------
+
+// FeeModel 交易费用模型,amount 为成交金额
+type FeeModel interface {
+	Commission(side Side, amount float64) float64
+	StampDuty(side Side, amount float64) float64
+}
+
+// AShareFee A股费用:佣金(双向,有最低收费)、印花税(卖出单向)、过户费(双向)
+type AShareFee struct {
+	CommissionRate  float64 `json:"commissionRate"`
+	MinCommission   float64 `json:"minCommission"`
+	StampDutyRate   float64 `json:"stampDutyRate"`
+	TransferFeeRate float64 `json:"transferFeeRate"`
+}
+
+// DefaultAShareFee 万2.5佣金最低5元,印花税千分之0.5(2023-08-28起),过户费十万分之1
+func DefaultAShareFee() AShareFee {
+	return AShareFee{
+		CommissionRate:  0.00025,
+		MinCommission:   5,
+		StampDutyRate:   0.0005,
+		TransferFeeRate: 0.00001,
+	}
+}
+
+// Commission 佣金和过户费
+func (f AShareFee) Commission(side Side, amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	commission := math.Max(amount*f.CommissionRate, f.MinCommission)
+	return round2(commission + amount*f.TransferFeeRate)
+}
+
+func (f AShareFee) StampDuty(side Side, amount float64) float64 {
+	if side != Sell || amount <= 0 {
+		return 0
+	}
+	return round2(amount * f.StampDutyRate)
+}
+
+// HKShareFee 港股费用:佣金(有最低收费)、印花税(双向)、交易征费等(双向)
+type HKShareFee struct {
+	CommissionRate float64 `json:"commissionRate"`
+	MinCommission  float64 `json:"minCommission"`
+	StampDutyRate  float64 `json:"stampDutyRate"`
+	LevyRate       float64 `json:"levyRate"`
+}
+
+// DefaultHKShareFee 万3佣金最低3港元,印花税千分之1(2023-11-17起),交易费、交易征费及财汇局征费合计约十万分之8.5
+func DefaultHKShareFee() HKShareFee {
+	return HKShareFee{
+		CommissionRate: 0.0003,
+		MinCommission:  3,
+		StampDutyRate:  0.001,
+		LevyRate:       0.0000850,
+	}
+}
+
+// Commission 佣金和交易征费
+func (f HKShareFee) Commission(side Side, amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	commission := math.Max(amount*f.CommissionRate, f.MinCommission)
+	return round2(commission + amount*f.LevyRate)
+}
+
+// StampDuty 港股印花税买卖双方均需缴纳,不足1元按1元计
+func (f HKShareFee) StampDuty(side Side, amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	return math.Ceil(amount * f.StampDutyRate)
+}
+
+// USShareFee 美股费用:按成交金额收取佣金(有最低收费),卖出收取证监会费,无印花税
+type USShareFee struct {
+	CommissionRate float64 `json:"commissionRate"`
+	MinCommission  float64 `json:"minCommission"`
+	SecFeeRate     float64 `json:"secFeeRate"`
+}
+
+// DefaultUSShareFee 万1佣金最低1美元,证监会费百万分之27.8(卖出)
+func DefaultUSShareFee() USShareFee {
+	return USShareFee{
+		CommissionRate: 0.0001,
+		MinCommission:  1,
+		SecFeeRate:     0.0000278,
+	}
+}
+
+// Commission 佣金,卖出时包含证监会费
+func (f USShareFee) Commission(side Side, amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	commission := math.Max(amount*f.CommissionRate, f.MinCommission)
+	if side == Sell {
+		commission += amount * f.SecFeeRate
+	}
+	return round2(commission)
+}
+
+func (f USShareFee) StampDuty(side Side, amount float64) float64 {
+	return 0
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
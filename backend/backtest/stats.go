@@ -0,0 +1,112 @@
+package backtest
+
+import (
+	"math"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/16 11:20
+// @Desc 回测统计:年化收益、最大回撤、夏普比率、胜率
+// -----------------------------------------------------------------------------------
+
+// tradingDaysPerYear 年化使用的交易日数
+const tradingDaysPerYear = 252
+
+type Stats struct {
+	StartDay     string  `json:"startDay" md:"开始日期"`
+	EndDay       string  `json:"endDay" md:"结束日期"`
+	InitialCash  float64 `json:"initialCash" md:"初始资金"`
+	FinalEquity  float64 `json:"finalEquity" md:"期末资产"`
+	TotalReturn  float64 `json:"totalReturn" md:"总收益率(%)"`
+	CAGR         float64 `json:"cagr" md:"年化收益率(%)"`
+	MaxDrawdown  float64 `json:"maxDrawdown" md:"最大回撤(%)"`
+	Sharpe       float64 `json:"sharpe" md:"夏普比率"`
+	WinRate      float64 `json:"winRate" md:"胜率(%)"`
+	TradeCount   int     `json:"tradeCount" md:"成交笔数"`
+	ClosedTrades int     `json:"closedTrades" md:"平仓笔数"`
+	TotalFee     float64 `json:"totalFee" md:"交易费用"`
+}
+
+func calcStats(initialCash float64, equity []EquityPoint, trades []Trade) Stats {
+	stats := Stats{InitialCash: initialCash, TradeCount: len(trades)}
+	if len(equity) == 0 || initialCash <= 0 {
+		return stats
+	}
+	stats.StartDay = equity[0].Day
+	stats.EndDay = equity[len(equity)-1].Day
+	final := equity[len(equity)-1].Equity
+	stats.FinalEquity = final
+	stats.TotalReturn = round2((final/initialCash - 1) * 100)
+
+	years := calendarYears(stats.StartDay, stats.EndDay)
+	if years <= 0 {
+		years = float64(len(equity)) / tradingDaysPerYear
+	}
+	if years > 0 && final > 0 {
+		stats.CAGR = round2((math.Pow(final/initialCash, 1/years) - 1) * 100)
+	}
+
+	for _, point := range equity {
+		stats.MaxDrawdown = math.Max(stats.MaxDrawdown, point.Drawdown)
+	}
+	stats.Sharpe = sharpe(initialCash, equity)
+
+	wins := 0
+	for _, trade := range trades {
+		stats.TotalFee += trade.Commission + trade.StampDuty
+		if trade.Side != Sell {
+			continue
+		}
+		stats.ClosedTrades++
+		if trade.Profit > 0 {
+			wins++
+		}
+	}
+	stats.TotalFee = round2(stats.TotalFee)
+	if stats.ClosedTrades > 0 {
+		stats.WinRate = round2(float64(wins) / float64(stats.ClosedTrades) * 100)
+	}
+	return stats
+}
+
+// sharpe 日收益率年化夏普比率,无风险利率按0计算
+func sharpe(initialCash float64, equity []EquityPoint) float64 {
+	if len(equity) < 2 {
+		return 0
+	}
+	returns := make([]float64, 0, len(equity))
+	prev := initialCash
+	for _, point := range equity {
+		if prev > 0 {
+			returns = append(returns, point.Equity/prev-1)
+		}
+		prev = point.Equity
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std == 0 {
+		return 0
+	}
+	return round2(mean / std * math.Sqrt(tradingDaysPerYear))
+}
+
+func calendarYears(start, end string) float64 {
+	s, err := time.Parse(time.DateOnly, start)
+	if err != nil {
+		return 0
+	}
+	e, err := time.Parse(time.DateOnly, end)
+	if err != nil {
+		return 0
+	}
+	return e.Sub(s).Hours() / 24 / 365.25
+}
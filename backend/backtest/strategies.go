@@ -0,0 +1,110 @@
+package backtest
+
+import (
+	"fmt"
+	"lumos-stock/backend/indicators"
+	"math"
+)
+
+// @Author spark
+// @Date 2025/9/16 11:40
+// @Desc 内置策略
+// -----------------------------------------------------------------------------------
+
+const (
+	StrategyBuyAndHold = "buy_and_hold"
+	StrategyMACross    = "ma_cross"
+	StrategyMACD       = "macd"
+)
+
+// NewStrategy 按名称创建内置策略
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case StrategyBuyAndHold:
+		return &BuyAndHold{}, nil
+	case StrategyMACross, "":
+		return &MACross{Fast: 5, Slow: 20}, nil
+	case StrategyMACD:
+		return &MACDCross{}, nil
+	}
+	return nil, fmt.Errorf("不支持的策略:%s", name)
+}
+
+// BuyAndHold 第一根K线满仓买入并持有
+type BuyAndHold struct {
+	bought bool
+}
+
+func (s *BuyAndHold) Name() string { return "买入持有" }
+
+func (s *BuyAndHold) OnStart(ctx *Context) {
+	s.bought = false
+}
+
+func (s *BuyAndHold) OnBar(ctx *Context, bar Bar) {
+	if s.bought {
+		return
+	}
+	ctx.BuyPercent(1, "建仓")
+	s.bought = true
+}
+
+// MACross 均线金叉买入,死叉卖出
+type MACross struct {
+	Fast int
+	Slow int
+}
+
+func (s *MACross) Name() string { return fmt.Sprintf("MA%d/MA%d均线交叉", s.Fast, s.Slow) }
+
+func (s *MACross) OnStart(ctx *Context) {}
+
+func (s *MACross) OnBar(ctx *Context, bar Bar) {
+	history := ctx.History()
+	if len(history) < s.Slow+1 {
+		return
+	}
+	closes := make([]float64, len(history))
+	for i, h := range history {
+		closes[i] = h.Close
+	}
+	fast := indicators.MA(closes, s.Fast)
+	slow := indicators.MA(closes, s.Slow)
+	last := len(closes) - 1
+	crossSignal(ctx, fast[last-1]-slow[last-1], fast[last]-slow[last], "均线金叉", "均线死叉")
+}
+
+// MACDCross DIF上穿DEA买入,下穿卖出
+type MACDCross struct{}
+
+func (s *MACDCross) Name() string { return "MACD金叉死叉" }
+
+func (s *MACDCross) OnStart(ctx *Context) {}
+
+func (s *MACDCross) OnBar(ctx *Context, bar Bar) {
+	history := ctx.History()
+	if len(history) < 35 {
+		return
+	}
+	closes := make([]float64, len(history))
+	for i, h := range history {
+		closes[i] = h.Close
+	}
+	dif, dea, _ := indicators.MACD(closes, 12, 26, 9)
+	last := len(closes) - 1
+	crossSignal(ctx, dif[last-1]-dea[last-1], dif[last]-dea[last], "MACD金叉", "MACD死叉")
+}
+
+// crossSignal prev、cur 为快线与慢线的差值,由负转正买入,由正转负卖出
+func crossSignal(ctx *Context, prev, cur float64, buyReason, sellReason string) {
+	if math.IsNaN(prev) || math.IsNaN(cur) {
+		return
+	}
+	position := ctx.Position()
+	if prev <= 0 && cur > 0 && position.Shares == 0 {
+		ctx.BuyPercent(1, buyReason)
+	}
+	if prev >= 0 && cur < 0 && position.Shares > 0 {
+		ctx.SellAll(sellReason)
+	}
+}
@@ -0,0 +1,364 @@
+package calendar
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"lumos-stock/backend/logger"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+)
+
+// @Author spark
+// @Date 2025/9/10 10:05
+// @Desc 交易所交易日历:交易时段、节假日休市、半日市
+// 节假日表内置于 data/holidays.json,交易所公布下一年安排后在内置表中追加当年条目;
+// 未发版前可在程序目录的 data/holidays.json 按相同格式补充,启动时通过 LoadFile 覆盖合并
+// -----------------------------------------------------------------------------------
+
+//go:embed data/holidays.json
+var holidaysJson []byte
+
+const (
+	SSECode  = "SSE"  //上交所/深交所
+	HKEXCode = "HKEX" //港交所
+	NYSECode = "NYSE" //纽交所/纳斯达克
+)
+
+// Session 交易时段,时间为交易所当地时间 HH:MM,包含结束时间所在的那一分钟
+type Session struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (s Session) contains(minute int) bool {
+	return minute >= clockMinute(s.Start) && minute <= clockMinute(s.End)
+}
+
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+// EarlyClose 半日市,Close 为常规时段收市时间,ExtendedClose 为盘后交易结束时间(仅美股)
+type EarlyClose struct {
+	Date          string `json:"date"`
+	Close         string `json:"close"`
+	ExtendedClose string `json:"extendedClose"`
+	Name          string `json:"name"`
+}
+
+type holidayTable struct {
+	Holidays    []Holiday    `json:"holidays"`
+	EarlyCloses []EarlyClose `json:"earlyCloses"`
+}
+
+// Exchange 交易所日历
+type Exchange struct {
+	Code     string
+	Name     string
+	Location *time.Location
+	// Sessions 常规交易时段(含集合竞价)
+	Sessions []Session
+	// ExtendedSessions 盘前盘后时段
+	ExtendedSessions []Session
+
+	mu          sync.RWMutex
+	holidays    map[string]string
+	earlyCloses map[string]EarlyClose
+	//节假日表覆盖到的最后一年
+	lastYear int
+	//已提示过超出节假日表的年份
+	warned sync.Map
+}
+
+var (
+	SSE = &Exchange{
+		Code:     SSECode,
+		Name:     "沪深交易所",
+		Location: mustLoadLocation("Asia/Shanghai", 8),
+		Sessions: []Session{{"09:15", "11:30"}, {"13:00", "15:00"}},
+	}
+	HKEX = &Exchange{
+		Code:     HKEXCode,
+		Name:     "香港交易所",
+		Location: mustLoadLocation("Asia/Hong_Kong", 8),
+		Sessions: []Session{{"09:00", "12:00"}, {"13:00", "16:10"}},
+	}
+	NYSE = &Exchange{
+		Code:             NYSECode,
+		Name:             "纽约证券交易所",
+		Location:         mustLoadLocation("America/New_York", -5),
+		Sessions:         []Session{{"09:30", "16:00"}},
+		ExtendedSessions: []Session{{"04:00", "09:29"}, {"16:01", "20:00"}},
+	}
+	exchanges = map[string]*Exchange{SSECode: SSE, HKEXCode: HKEX, NYSECode: NYSE}
+)
+
+func init() {
+	if err := Load(holidaysJson); err != nil {
+		logger.SugaredLogger.Errorf("load trading calendar error:%s", err.Error())
+	}
+}
+
+// Load 加载节假日表,格式与 data/holidays.json 一致,同一日期以后加载的为准
+func Load(content []byte) error {
+	tables := map[string]holidayTable{}
+	if err := json.Unmarshal(content, &tables); err != nil {
+		return err
+	}
+	for code, table := range tables {
+		exchange, ok := exchanges[code]
+		if !ok {
+			return fmt.Errorf("unknown exchange:%s", code)
+		}
+		exchange.mu.Lock()
+		if exchange.holidays == nil {
+			exchange.holidays = map[string]string{}
+			exchange.earlyCloses = map[string]EarlyClose{}
+		}
+		for _, h := range table.Holidays {
+			exchange.holidays[h.Date] = h.Name
+			exchange.lastYear = max(exchange.lastYear, dateYear(h.Date))
+		}
+		for _, e := range table.EarlyCloses {
+			exchange.earlyCloses[e.Date] = e
+			exchange.lastYear = max(exchange.lastYear, dateYear(e.Date))
+		}
+		exchange.mu.Unlock()
+	}
+	return nil
+}
+
+// LoadFile 加载本地节假日表,用于内置表尚未包含的年份,文件不存在时忽略
+func LoadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return Load(content)
+}
+
+// Get 按交易所代码获取日历
+func Get(code string) *Exchange {
+	return exchanges[strings.ToUpper(code)]
+}
+
+// ForStock 根据股票代码获取所属交易所,无法识别时返回nil
+func ForStock(stockCode string) *Exchange {
+	code := strings.ToLower(stockCode)
+	switch {
+	case strings.HasPrefix(code, "sh"), strings.HasPrefix(code, "sz"), strings.HasPrefix(code, "bj"):
+		return SSE
+	case strings.HasPrefix(code, "hk"):
+		return HKEX
+	case strings.HasPrefix(code, "us"), strings.HasPrefix(code, "gb_"):
+		return NYSE
+	default:
+		return nil
+	}
+}
+
+// IsStockTradingTime 股票所属市场当前是否可交易(美股包含盘前盘后),无法识别市场时返回true
+func IsStockTradingTime(stockCode string, t time.Time) bool {
+	exchange := ForStock(stockCode)
+	if exchange == nil {
+		return true
+	}
+	if exchange == NYSE {
+		return exchange.IsExtendedTradingTime(t)
+	}
+	return exchange.IsTradingTime(t)
+}
+
+// IsStockTradingDay 股票所属市场当天是否为交易日,无法识别市场时返回true
+func IsStockTradingDay(stockCode string, t time.Time) bool {
+	exchange := ForStock(stockCode)
+	if exchange == nil {
+		return true
+	}
+	return exchange.IsTradingDay(t)
+}
+
+// IsAnyTradingDay 任一市场当天为交易日
+func IsAnyTradingDay(t time.Time) bool {
+	return SSE.IsTradingDay(t) || HKEX.IsTradingDay(t) || NYSE.IsTradingDay(t)
+}
+
+// IsTradingDay 交易所当地日期是否为交易日
+func (e *Exchange) IsTradingDay(t time.Time) bool {
+	local := t.In(e.Location)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	_, holiday := e.Holiday(local)
+	return !holiday
+}
+
+// Holiday 返回休市原因,超出节假日表的年份只能按周末判断,每年提示一次
+func (e *Exchange) Holiday(t time.Time) (string, bool) {
+	local := t.In(e.Location)
+	if !e.Covers(local) {
+		if _, warned := e.warned.LoadOrStore(local.Year(), true); !warned {
+			logger.SugaredLogger.Warnf("%s节假日表只到%d年,%d年按周末判断休市,请在data/holidays.json中补充", e.Name, e.LastYear(), local.Year())
+		}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	name, ok := e.holidays[local.Format(time.DateOnly)]
+	return name, ok
+}
+
+// LastYear 节假日表覆盖到的最后一年
+func (e *Exchange) LastYear() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lastYear
+}
+
+// Covers 节假日表是否覆盖当地日期所在年份
+func (e *Exchange) Covers(t time.Time) bool {
+	return t.In(e.Location).Year() <= e.LastYear()
+}
+
+// EarlyClose 返回半日市信息
+func (e *Exchange) EarlyClose(t time.Time) (EarlyClose, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	early, ok := e.earlyCloses[t.In(e.Location).Format(time.DateOnly)]
+	return early, ok
+}
+
+// SessionsOf 返回当天的常规交易时段,非交易日返回空,半日市按收市时间截断
+func (e *Exchange) SessionsOf(t time.Time) []Session {
+	if !e.IsTradingDay(t) {
+		return nil
+	}
+	early, ok := e.EarlyClose(t)
+	if !ok {
+		return e.Sessions
+	}
+	return clip(e.Sessions, early.Close)
+}
+
+// ExtendedSessionsOf 返回当天的盘前盘后时段,半日市盘后从提前收市后开始
+func (e *Exchange) ExtendedSessionsOf(t time.Time) []Session {
+	if !e.IsTradingDay(t) || len(e.ExtendedSessions) == 0 {
+		return nil
+	}
+	early, ok := e.EarlyClose(t)
+	if !ok || early.ExtendedClose == "" {
+		return e.ExtendedSessions
+	}
+	regularClose := clockMinute(e.Sessions[len(e.Sessions)-1].End)
+	sessions := make([]Session, 0, len(e.ExtendedSessions))
+	for _, s := range e.ExtendedSessions {
+		if clockMinute(s.Start) > regularClose {
+			s = Session{Start: minuteClock(clockMinute(early.Close) + 1), End: early.ExtendedClose}
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// IsTradingTime 是否处于常规交易时段
+func (e *Exchange) IsTradingTime(t time.Time) bool {
+	local := t.In(e.Location)
+	minute := local.Hour()*60 + local.Minute()
+	for _, s := range e.SessionsOf(local) {
+		if s.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExtendedTradingTime 是否处于常规或盘前盘后交易时段
+func (e *Exchange) IsExtendedTradingTime(t time.Time) bool {
+	if e.IsTradingTime(t) {
+		return true
+	}
+	local := t.In(e.Location)
+	minute := local.Hour()*60 + local.Minute()
+	for _, s := range e.ExtendedSessionsOf(local) {
+		if s.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextTradingDay 下一个交易日(不含当天),返回交易所当地零点
+func (e *Exchange) NextTradingDay(t time.Time) time.Time {
+	local := t.In(e.Location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, e.Location)
+	for i := 0; i < 366; i++ {
+		day = day.AddDate(0, 0, 1)
+		if e.IsTradingDay(day) {
+			return day
+		}
+	}
+	return day
+}
+
+// PrevTradingDay 上一个交易日(不含当天),返回交易所当地零点
+func (e *Exchange) PrevTradingDay(t time.Time) time.Time {
+	local := t.In(e.Location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, e.Location)
+	for i := 0; i < 366; i++ {
+		day = day.AddDate(0, 0, -1)
+		if e.IsTradingDay(day) {
+			return day
+		}
+	}
+	return day
+}
+
+func clip(sessions []Session, close string) []Session {
+	closeMinute := clockMinute(close)
+	res := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		if clockMinute(s.Start) >= closeMinute {
+			continue
+		}
+		if clockMinute(s.End) > closeMinute {
+			s.End = close
+		}
+		res = append(res, s)
+	}
+	//港股半日市收市竞价在午市前进行,最后一个时段延长到收市时间
+	if len(res) > 0 && clockMinute(res[len(res)-1].End) < closeMinute {
+		res[len(res)-1].End = close
+	}
+	return res
+}
+
+func dateYear(date string) int {
+	var year int
+	fmt.Sscanf(date, "%d-", &year)
+	return year
+}
+
+func clockMinute(clock string) int {
+	var hour, minute int
+	fmt.Sscanf(clock, "%d:%d", &hour, &minute)
+	return hour*60 + minute
+}
+
+func minuteClock(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+func mustLoadLocation(name string, offsetHours int) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return time.FixedZone(name, offsetHours*3600)
+	}
+	return location
+}
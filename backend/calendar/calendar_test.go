@@ -0,0 +1,120 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/10 14:32
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func at(e *Exchange, value string) time.Time {
+	t, err := time.ParseInLocation(time.DateTime, value, e.Location)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestSSE(t *testing.T) {
+	cases := []struct {
+		time string
+		want bool
+	}{
+		{"2025-09-10 09:14:59", false},
+		{"2025-09-10 09:15:00", true},
+		{"2025-09-10 11:30:30", true},
+		{"2025-09-10 12:00:00", false},
+		{"2025-09-10 15:00:00", true},
+		{"2025-09-10 15:01:00", false},
+		{"2025-09-13 10:00:00", false}, //周六
+		{"2025-10-03 10:00:00", false}, //国庆
+		{"2026-02-18 10:00:00", false}, //春节
+	}
+	for _, c := range cases {
+		if got := SSE.IsTradingTime(at(SSE, c.time)); got != c.want {
+			t.Errorf("%s: got %v want %v", c.time, got, c.want)
+		}
+	}
+	name, ok := SSE.Holiday(at(SSE, "2025-10-01 10:00:00"))
+	if !ok {
+		t.Error("2025-10-01 should be a holiday")
+	}
+	t.Log(name)
+}
+
+func TestHKEXEarlyClose(t *testing.T) {
+	if !HKEX.IsTradingTime(at(HKEX, "2025-12-24 12:05:00")) {
+		t.Error("closing auction of half day should be open")
+	}
+	if HKEX.IsTradingTime(at(HKEX, "2025-12-24 14:00:00")) {
+		t.Error("half day afternoon should be closed")
+	}
+	if !HKEX.IsTradingTime(at(HKEX, "2025-12-23 14:00:00")) {
+		t.Error("normal day afternoon should be open")
+	}
+	if HKEX.IsTradingTime(at(HKEX, "2025-09-13 10:00:00")) {
+		t.Error("weekend should be closed")
+	}
+}
+
+func TestNYSE(t *testing.T) {
+	if NYSE.IsTradingTime(at(NYSE, "2025-07-04 10:00:00")) {
+		t.Error("independence day should be closed")
+	}
+	if !NYSE.IsExtendedTradingTime(at(NYSE, "2025-11-28 14:00:00")) {
+		t.Error("after hours of early close day should be open")
+	}
+	if NYSE.IsExtendedTradingTime(at(NYSE, "2025-11-28 18:00:00")) {
+		t.Error("after hours of early close day should end at 17:00")
+	}
+	if !NYSE.IsExtendedTradingTime(at(NYSE, "2025-09-10 05:00:00")) {
+		t.Error("pre market should be open")
+	}
+	//北京时间周二凌晨对应纽约周一
+	beijing := at(SSE, "2025-09-09 02:00:00")
+	if !NYSE.IsExtendedTradingTime(beijing) {
+		t.Error("beijing time should be converted to new york time")
+	}
+}
+
+func TestTradingDayNavigation(t *testing.T) {
+	next := SSE.NextTradingDay(at(SSE, "2025-09-30 10:00:00"))
+	if next.Format(time.DateOnly) != "2025-10-09" {
+		t.Errorf("next trading day got %s", next.Format(time.DateOnly))
+	}
+	prev := SSE.PrevTradingDay(at(SSE, "2025-10-09 10:00:00"))
+	if prev.Format(time.DateOnly) != "2025-09-30" {
+		t.Errorf("prev trading day got %s", prev.Format(time.DateOnly))
+	}
+	if ForStock("gb_aapl") != NYSE || ForStock("sz000001") != SSE || ForStock("hk00700") != HKEX || ForStock("fund") != nil {
+		t.Error("unexpected exchange for stock code")
+	}
+}
+
+func TestHolidayTableCoverage(t *testing.T) {
+	if SSE.LastYear() < 2026 || !SSE.Covers(at(SSE, "2026-12-31 10:00:00")) {
+		t.Errorf("builtin table should cover 2026, got %d", SSE.LastYear())
+	}
+	if SSE.Covers(at(SSE, "2098-01-05 10:00:00")) {
+		t.Error("2098 should not be covered")
+	}
+	if err := LoadFile(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("missing file should be ignored, got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "holidays.json")
+	content := `{"SSE":{"holidays":[{"date":"2098-01-01","name":"元旦"}]}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !SSE.Covers(at(SSE, "2098-01-05 10:00:00")) || SSE.IsTradingDay(at(SSE, "2098-01-01 10:00:00")) {
+		t.Error("loaded table should cover 2098")
+	}
+}
@@ -0,0 +1,47 @@
+//go:build linux
+// +build linux
+
+package data
+
+import (
+	"lumos-stock/backend/logger"
+
+	"github.com/gen2brain/beeep"
+)
+
+// AlertWindowsApi @Author spark
+// @Date 2025/9/29 10:20
+// @Desc Linux 桌面通知,通过 D-Bus 发送 freedesktop 通知
+// -----------------------------------------------------------------------------------
+type AlertWindowsApi struct {
+	AppID string
+	// 窗口标题
+	Title string
+	// 窗口内容
+	Content string
+	// 窗口图标
+	Icon string
+}
+
+func NewAlertWindowsApi(AppID string, Title string, Content string, Icon string) *AlertWindowsApi {
+	return &AlertWindowsApi{
+		AppID:   AppID,
+		Title:   Title,
+		Content: Content,
+		Icon:    Icon,
+	}
+}
+
+func (a AlertWindowsApi) SendNotification() bool {
+	if GetSettingConfig().LocalPushEnable == false {
+		logger.SugaredLogger.Error("本地推送未开启")
+		return false
+	}
+
+	err := beeep.Notify(a.Title, a.Content, a.Icon)
+	if err != nil {
+		logger.SugaredLogger.Error(err)
+		return false
+	}
+	return true
+}
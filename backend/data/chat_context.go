@@ -0,0 +1,319 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/util"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/random"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+)
+
+// @Author spark
+// @Date 2025/10/18 18:30
+// @Desc AI 分析上下文:声明各个数据来源,并发获取,每个来源有独立的超时和缓存,结果按声明顺序合并,提示词模板可以选择使用哪些来源
+// -----------------------------------------------------------------------------------
+
+const (
+	ChatContextQuote       = "quote"
+	ChatContextInteractive = "interactive"
+	ChatContextMacro       = "macro"
+	ChatContextCalendar    = "calendar"
+	ChatContextKLine       = "kline"
+	ChatContextPrice       = "price"
+	ChatContextFinancial   = "financial"
+	ChatContextMarketNews  = "market_news"
+	ChatContextStockNews   = "stock_news"
+)
+
+// ChatContextRequest 获取上下文的股票和参数
+type ChatContextRequest struct {
+	Stock        string
+	StockCode    string
+	KDays        int64
+	CrawlTimeOut int64
+}
+
+// ChatContextSource 上下文来源,Fetch 返回空字符串表示没有数据,返回错误时提示 Warning
+type ChatContextSource struct {
+	Name     string                                       `json:"name"`
+	Title    string                                       `json:"title"`    //作为提问写入对话,如 "国内宏观经济数据"
+	PerStock bool                                         `json:"perStock"` //按股票缓存,否则所有股票共用
+	Timeout  time.Duration                                `json:"-"`        //0 使用爬虫超时
+	CacheTTL time.Duration                                `json:"-"`        //0 不缓存
+	Warning  string                                       `json:"-"`        //获取失败时的提示,为空不提示
+	Fetch    func(req ChatContextRequest) (string, error) `json:"-"`
+}
+
+// ChatContextResult 一个来源的获取结果
+type ChatContextResult struct {
+	Source  ChatContextSource
+	Content string
+	Err     error
+}
+
+// chatContextCache 上下文缓存,键为来源名称,按股票缓存的来源加上股票代码
+type chatContextCache struct {
+	lock  sync.Mutex
+	items map[string]chatContextCacheItem
+}
+
+type chatContextCacheItem struct {
+	content string
+	expires time.Time
+}
+
+func newChatContextCache() *chatContextCache {
+	return &chatContextCache{items: map[string]chatContextCacheItem{}}
+}
+
+func (c *chatContextCache) get(key string) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	item, ok := c.items[key]
+	if !ok || time.Now().After(item.expires) {
+		delete(c.items, key)
+		return "", false
+	}
+	return item.content, true
+}
+
+func (c *chatContextCache) set(key, content string, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.items[key] = chatContextCacheItem{content: content, expires: time.Now().Add(ttl)}
+}
+
+var defaultChatContextCache = newChatContextCache()
+
+type ChatContextBuilder struct {
+	sources []ChatContextSource
+	cache   *chatContextCache
+}
+
+// NewChatContextBuilder 使用内置的上下文来源
+func NewChatContextBuilder() *ChatContextBuilder {
+	return &ChatContextBuilder{sources: ChatContextSources(), cache: defaultChatContextCache}
+}
+
+// Build 并发获取 names 指定的来源,names 为空时使用全部来源;结果按来源的声明顺序返回,超时的来源返回错误
+func (b ChatContextBuilder) Build(req ChatContextRequest, names []string) []ChatContextResult {
+	sources := lo.Filter(b.sources, func(source ChatContextSource, _ int) bool {
+		return len(names) == 0 || lo.Contains(names, source.Name)
+	})
+	results := make([]ChatContextResult, len(sources))
+	wg := &sync.WaitGroup{}
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := b.fetch(source, req)
+			results[i] = ChatContextResult{Source: source, Content: content, Err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (b ChatContextBuilder) fetch(source ChatContextSource, req ChatContextRequest) (string, error) {
+	key := source.Name
+	if source.PerStock {
+		key += "|" + req.StockCode
+	}
+	//K线数量不同的请求分别缓存
+	if source.Name == ChatContextKLine {
+		key += fmt.Sprintf("|%d", req.KDays)
+	}
+	if source.CacheTTL > 0 {
+		if content, ok := b.cache.get(key); ok {
+			return content, nil
+		}
+	}
+	timeout := source.Timeout
+	if timeout <= 0 {
+		timeout = time.Duration(max(req.CrawlTimeOut, 10)) * time.Second
+	}
+	type fetchResult struct {
+		content string
+		err     error
+	}
+	//超时后不再等待,获取完成的结果丢弃
+	done := make(chan fetchResult, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- fetchResult{err: fmt.Errorf("panic:%v", err)}
+			}
+		}()
+		content, err := source.Fetch(req)
+		done <- fetchResult{content: content, err: err}
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil {
+			logger.SugaredLogger.Errorf("chat context %s error:%s", source.Name, res.err.Error())
+			return "", res.err
+		}
+		if source.CacheTTL > 0 && res.content != "" {
+			b.cache.set(key, res.content, source.CacheTTL)
+		}
+		return res.content, nil
+	case <-time.After(timeout):
+		logger.SugaredLogger.Errorf("chat context %s timeout:%s", source.Name, timeout)
+		return "", fmt.Errorf("%s 获取超时", source.Title)
+	}
+}
+
+// ChatContextMessages 有内容的结果转换为一问一答的对话消息,按股票获取的来源提问中带上股票名称
+func ChatContextMessages(req ChatContextRequest, results []ChatContextResult) []map[string]any {
+	var messages []map[string]any
+	for _, result := range results {
+		if result.Err != nil || result.Content == "" {
+			continue
+		}
+		title := result.Source.Title
+		if result.Source.PerStock {
+			title = req.Stock + title
+		}
+		messages = append(messages, map[string]any{
+			"role":    "user",
+			"content": title,
+		}, map[string]any{
+			"role":    "assistant",
+			"content": result.Content,
+		})
+	}
+	return messages
+}
+
+// ParseChatContextSources 解析提示词模板中逗号分隔的来源名称,忽略未知的来源
+func ParseChatContextSources(value string) []string {
+	names := lo.Map(ChatContextSources(), func(source ChatContextSource, _ int) string {
+		return source.Name
+	})
+	return lo.Uniq(lo.Filter(strutil.SplitAndTrim(value, ","), func(name string, _ int) bool {
+		return lo.Contains(names, name)
+	}))
+}
+
+// ChatContextSources 内置的上下文来源,顺序即合并到对话中的顺序
+func ChatContextSources() []ChatContextSource {
+	return []ChatContextSource{
+		{Name: ChatContextQuote, Title: "当前价格", PerStock: true, Timeout: 10 * time.Second, Fetch: quoteContext},
+		{Name: ChatContextInteractive, Title: "投资者互动数据", PerStock: true, Timeout: 15 * time.Second, CacheTTL: 30 * time.Minute, Fetch: interactiveContext},
+		{Name: ChatContextMacro, Title: "国内宏观经济数据", Timeout: 30 * time.Second, CacheTTL: 12 * time.Hour, Fetch: macroContext},
+		{Name: ChatContextCalendar, Title: "近期重大事件/会议", Timeout: 15 * time.Second, CacheTTL: time.Hour, Fetch: calendarContext},
+		{Name: ChatContextKLine, Title: "日K数据", PerStock: true, Timeout: 30 * time.Second, CacheTTL: 10 * time.Minute, Fetch: kLineContext},
+		{Name: ChatContextPrice, Title: "股价数据", PerStock: true, Warning: "❗获取股票价格失败,分析结果可能不准确", Fetch: priceContext},
+		{Name: ChatContextFinancial, Title: "财报数据", PerStock: true, CacheTTL: 12 * time.Hour, Warning: "❗获取股票财报失败,分析结果可能不准确", Fetch: financialContext},
+		{Name: ChatContextMarketNews, Title: "市场资讯", Timeout: 10 * time.Second, CacheTTL: 5 * time.Minute, Fetch: marketNewsContext},
+		{Name: ChatContextStockNews, Title: "相关新闻资讯", PerStock: true, CacheTTL: 10 * time.Minute, Fetch: stockNewsContext},
+	}
+}
+
+func quoteContext(req ChatContextRequest) (string, error) {
+	stockData, err := NewStockDataApi().GetStockCodeRealTimeData(req.StockCode)
+	if err != nil || len(*stockData) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("截止到%s,当前%s[%s]价格是%s", (*stockData)[0].Date+" "+(*stockData)[0].Time, req.Stock, req.StockCode, (*stockData)[0].Price), nil
+}
+
+func interactiveContext(req ChatContextRequest) (string, error) {
+	datas := NewMarketNewsApi().InteractiveAnswer(1, 100, req.Stock)
+	return util.MarkdownTableWithTitle("当前最新投资者互动数据", datas.Results), nil
+}
+
+func macroContext(_ ChatContextRequest) (string, error) {
+	var market strings.Builder
+	market.WriteString(util.MarkdownTableWithTitle("国内生产总值(GDP)", NewMarketNewsApi().GetGDP().GDPResult.Data))
+	market.WriteString(util.MarkdownTableWithTitle("居民消费价格指数(CPI)", NewMarketNewsApi().GetCPI().CPIResult.Data))
+	market.WriteString(util.MarkdownTableWithTitle("工业品出厂价格指数(PPI)", NewMarketNewsApi().GetPPI().PPIResult.Data))
+	market.WriteString(util.MarkdownTableWithTitle("采购经理人指数(PMI)", NewMarketNewsApi().GetPMI().PMIResult.Data))
+	return "\n# 国内宏观经济数据：\n" + market.String(), nil
+}
+
+func calendarContext(_ ChatContextRequest) (string, error) {
+	md := strings.Builder{}
+	for _, a := range NewMarketNewsApi().ClsCalendar() {
+		bytes, err := json.Marshal(a)
+		if err != nil {
+			continue
+		}
+		md.WriteString("\n### 事件/会议日期：" + gjson.GetBytes(bytes, "calendar_day").String())
+		gjson.GetBytes(bytes, "items").ForEach(func(key, value gjson.Result) bool {
+			md.WriteString("\n- " + value.Get("title").String())
+			return true
+		})
+	}
+	return "近期重大事件/会议如下：\n" + md.String(), nil
+}
+
+func kLineContext(req ChatContextRequest) (string, error) {
+	if !strutil.HasPrefixAny(req.StockCode, []string{"sz", "sh", "hk", "us", "gb_"}) {
+		return "", nil
+	}
+	K := NewKLineStoreApi().GetKLine(req.StockCode, KLinePeriodDay, req.KDays)
+	Kmap := &[]map[string]any{}
+	for _, kline := range *K {
+		mapk := make(map[string]any, 6)
+		mapk["日期"] = kline.Day
+		mapk["开盘价"] = kline.Open
+		mapk["最高价"] = kline.High
+		mapk["最低价"] = kline.Low
+		mapk["收盘价"] = kline.Close
+		Volume, _ := convertor.ToFloat(kline.Volume)
+		mapk["成交量(万手)"] = Volume / 10000.00 / 100.00
+		*Kmap = append(*Kmap, mapk)
+	}
+	jsonData, _ := json.Marshal(Kmap)
+	markdownTable, _ := JSONToMarkdownTable(jsonData)
+	return "## " + req.Stock + "日K数据如下：\n" + markdownTable, nil
+}
+
+func priceContext(req ChatContextRequest) (string, error) {
+	messages := SearchStockPriceInfo(req.Stock, req.StockCode, req.CrawlTimeOut)
+	if messages == nil || len(*messages) == 0 {
+		return "", fmt.Errorf("获取股票价格失败")
+	}
+	return "\n## " + req.Stock + "股价数据：\n" + strings.Join(*messages, ";") + ";", nil
+}
+
+func financialContext(req ChatContextRequest) (string, error) {
+	if checkIsIndexBasic(req.Stock) {
+		return "", nil
+	}
+	messages := GetFinancialReportsByXUEQIU(req.StockCode, req.CrawlTimeOut)
+	if messages == nil || len(*messages) == 0 {
+		return "", fmt.Errorf("获取股票财报失败")
+	}
+	return req.Stock + strings.Join(*messages, "\n"+req.Stock), nil
+}
+
+func marketNewsContext(_ ChatContextRequest) (string, error) {
+	messages := NewMarketNewsApi().GetNews24HoursList("", random.RandInt(200, 1000))
+	if messages == nil || len(*messages) == 0 {
+		return "", fmt.Errorf("获取市场资讯失败")
+	}
+	var messageText strings.Builder
+	for _, telegraph := range *messages {
+		messageText.WriteString("## " + telegraph.Time + ":" + "\n")
+		messageText.WriteString("### " + telegraph.Content + "\n")
+	}
+	return messageText.String(), nil
+}
+
+func stockNewsContext(req ChatContextRequest) (string, error) {
+	messages := SearchStockInfo(req.Stock, "telegram", req.CrawlTimeOut)
+	if messages == nil || len(*messages) == 0 {
+		return "", fmt.Errorf("获取股票电报资讯失败")
+	}
+	return strings.Join(*messages, "\n") + "\n", nil
+}
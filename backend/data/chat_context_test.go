@@ -0,0 +1,113 @@
+package data
+
+import (
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/models"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/10/18 19:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestChatContextBuilder(t *testing.T) {
+	var calls atomic.Int32
+	delayed := func(content string, delay time.Duration) func(req ChatContextRequest) (string, error) {
+		return func(req ChatContextRequest) (string, error) {
+			calls.Add(1)
+			time.Sleep(delay)
+			return content + ":" + req.StockCode, nil
+		}
+	}
+	builder := ChatContextBuilder{
+		cache: newChatContextCache(),
+		sources: []ChatContextSource{
+			{Name: "slow", Title: "慢", PerStock: true, Timeout: time.Second, Fetch: delayed("slow", 50*time.Millisecond)},
+			{Name: "fast", Title: "快", Timeout: time.Second, CacheTTL: time.Minute, Fetch: delayed("fast", 0)},
+			{Name: "empty", Title: "空", Timeout: time.Second, Fetch: func(ChatContextRequest) (string, error) {
+				return "", nil
+			}},
+			{Name: "fail", Title: "失败", Timeout: time.Second, Warning: "获取失败", Fetch: func(ChatContextRequest) (string, error) {
+				return "", fmt.Errorf("fail")
+			}},
+			{Name: "timeout", Title: "超时", Timeout: 20 * time.Millisecond, Fetch: delayed("timeout", time.Second)},
+			{Name: "panic", Title: "异常", Timeout: time.Second, Fetch: func(ChatContextRequest) (string, error) {
+				panic("boom")
+			}},
+		},
+	}
+	req := ChatContextRequest{Stock: "贵州茅台", StockCode: "sh600519"}
+
+	start := time.Now()
+	results := builder.Build(req, nil)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("sources should run concurrently and stop at their deadlines, took %s", elapsed)
+	}
+	names := make([]string, len(results))
+	for i, result := range results {
+		names[i] = result.Source.Name
+	}
+	if !slices.Equal(names, []string{"slow", "fast", "empty", "fail", "timeout", "panic"}) {
+		t.Fatalf("results should keep declared order %v", names)
+	}
+	if results[0].Content != "slow:sh600519" || results[1].Content != "fast:sh600519" {
+		t.Errorf("unexpected content %+v", results[:2])
+	}
+	for _, i := range []int{3, 4, 5} {
+		if results[i].Err == nil {
+			t.Errorf("%s should fail", results[i].Source.Name)
+		}
+	}
+
+	messages := ChatContextMessages(req, results)
+	if len(messages) != 4 || messages[0]["content"] != "贵州茅台慢" || messages[2]["content"] != "快" || messages[3]["content"] != "fast:sh600519" {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+
+	//只获取选择的来源,缓存未过期时不再获取
+	calls.Store(0)
+	results = builder.Build(ChatContextRequest{StockCode: "sz000001"}, []string{"fast"})
+	if len(results) != 1 || results[0].Content != "fast:sh600519" || calls.Load() != 0 {
+		t.Errorf("cached source should not fetch again %+v calls:%d", results, calls.Load())
+	}
+
+	//K线按天数分别缓存
+	kline := ChatContextBuilder{cache: newChatContextCache(), sources: []ChatContextSource{
+		{Name: ChatContextKLine, Title: "日K数据", PerStock: true, Timeout: time.Second, CacheTTL: time.Minute, Fetch: func(req ChatContextRequest) (string, error) {
+			return fmt.Sprintf("%d", req.KDays), nil
+		}},
+	}}
+	kline.Build(ChatContextRequest{StockCode: "sh600519", KDays: 30}, nil)
+	if results := kline.Build(ChatContextRequest{StockCode: "sh600519", KDays: 60}, nil); results[0].Content != "60" {
+		t.Errorf("kline cache should be keyed by days %+v", results)
+	}
+}
+
+func TestParseChatContextSources(t *testing.T) {
+	if sources := ParseChatContextSources(" kline, macro,unknown,kline "); !slices.Equal(sources, []string{ChatContextKLine, ChatContextMacro}) {
+		t.Errorf("unexpected sources %v", sources)
+	}
+	if sources := ParseChatContextSources(""); len(sources) != 0 {
+		t.Errorf("empty sources %v", sources)
+	}
+
+	db.Init(filepath.Join(t.TempDir(), "prompt.db"))
+	db.Dao.AutoMigrate(&models.PromptTemplate{})
+	api := NewPromptTemplateApi()
+	api.AddPrompt(models.PromptTemplate{Name: "技术面", Content: "分析K线", Type: "模型系统Prompt", ContextSources: "quote,kline"})
+	template := (*api.GetPromptTemplates("技术面", ""))[0]
+	if sources := api.GetPromptTemplateContextSources(template.ID); !slices.Equal(sources, []string{ChatContextQuote, ChatContextKLine}) {
+		t.Errorf("template sources %v", sources)
+	}
+	template.ContextSources = ""
+	api.AddPrompt(template)
+	if sources := api.GetPromptTemplateContextSources(template.ID); sources != nil {
+		t.Errorf("cleared sources should use all sources %v", sources)
+	}
+}
@@ -0,0 +1,344 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/10/18 14:30
+// @Desc AI 多轮对话会话:保存股票分析和 agent 对话的消息,继续对话时在 token 预算内回放历史,支持重命名、删除和导出
+// -----------------------------------------------------------------------------------
+
+const (
+	ChatKindStock = "stock" //股票分析 NewChatStream
+	ChatKindAgent = "agent" //agent 对话
+
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+	ChatRoleTool      = "tool"
+
+	//继续对话时回放历史消息的 token 预算
+	ChatHistoryTokenBudget = 8000
+	chatTitleMaxRunes      = 30
+)
+
+// ChatSession 对话会话
+type ChatSession struct {
+	gorm.Model
+	Title       string `json:"title"`
+	Kind        string `json:"kind" gorm:"index"`
+	StockCode   string `json:"stockCode"`
+	StockName   string `json:"stockName"`
+	AiConfigId  int    `json:"aiConfigId"`
+	SysPromptId int    `json:"sysPromptId"`
+}
+
+func (ChatSession) TableName() string {
+	return "chat_session"
+}
+
+// ChatMessage 会话中的一条消息,ToolCalls 为 OpenAI 格式的 tool_calls JSON
+type ChatMessage struct {
+	gorm.Model
+	SessionId        uint   `json:"sessionId" gorm:"index"`
+	Role             string `json:"role"`
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoningContent"`
+	ToolCalls        string `json:"toolCalls"`
+	ToolCallId       string `json:"toolCallId"`
+	Tokens           int    `json:"tokens"`
+}
+
+func (ChatMessage) TableName() string {
+	return "chat_message"
+}
+
+// SysPrompt 会话使用的提示词模板 id,0 表示默认提示词
+func (s ChatSession) SysPrompt() *int {
+	id := s.SysPromptId
+	return &id
+}
+
+type ChatSessionApi struct {
+}
+
+func NewChatSessionApi() *ChatSessionApi {
+	return &ChatSessionApi{}
+}
+
+// CreateSession 新建会话,标题取自第一个问题,没有问题时使用股票名称
+func (c ChatSessionApi) CreateSession(kind, question, stockCode, stockName string, aiConfigId int, sysPromptId *int) *ChatSession {
+	session := &ChatSession{
+		Title:      chatSessionTitle(question, stockName),
+		Kind:       kind,
+		StockCode:  stockCode,
+		StockName:  stockName,
+		AiConfigId: aiConfigId,
+	}
+	if sysPromptId != nil {
+		session.SysPromptId = *sysPromptId
+	}
+	if err := db.Dao.Create(session).Error; err != nil {
+		logger.SugaredLogger.Errorf("create chat session error:%s", err.Error())
+	}
+	return session
+}
+
+// GetSession 查询会话,不存在时返回 nil
+func (c ChatSessionApi) GetSession(id uint) *ChatSession {
+	session := &ChatSession{}
+	if err := db.Dao.Model(&ChatSession{}).Where("id = ?", id).First(session).Error; err != nil {
+		return nil
+	}
+	return session
+}
+
+// GetSessions 会话列表,最近更新的在前,kind 为空时返回全部
+func (c ChatSessionApi) GetSessions(kind string) *[]ChatSession {
+	sessions := &[]ChatSession{}
+	tx := db.Dao.Model(&ChatSession{})
+	if kind != "" {
+		tx = tx.Where("kind = ?", kind)
+	}
+	tx.Order("updated_at desc").Find(sessions)
+	return sessions
+}
+
+// GetMessages 会话的全部消息
+func (c ChatSessionApi) GetMessages(sessionId uint) *[]ChatMessage {
+	messages := &[]ChatMessage{}
+	db.Dao.Model(&ChatMessage{}).Where("session_id = ?", sessionId).Order("id").Find(messages)
+	return messages
+}
+
+// RenameSession 修改会话标题
+func (c ChatSessionApi) RenameSession(id uint, title string) string {
+	title = strutil.Trim(title)
+	if title == "" {
+		return "标题不能为空"
+	}
+	res := db.Dao.Model(&ChatSession{}).Where("id = ?", id).Update("title", title)
+	if res.Error != nil {
+		logger.SugaredLogger.Errorf("rename chat session error:%s", res.Error.Error())
+		return "修改失败"
+	}
+	if res.RowsAffected == 0 {
+		return "会话不存在"
+	}
+	return "修改成功"
+}
+
+// DeleteSession 删除会话及其消息
+func (c ChatSessionApi) DeleteSession(id uint) string {
+	err := db.Dao.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("session_id = ?", id).Delete(&ChatMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id = ?", id).Delete(&ChatSession{}).Error
+	})
+	if err != nil {
+		logger.SugaredLogger.Errorf("delete chat session error:%s", err.Error())
+		return "删除失败"
+	}
+	return "删除成功"
+}
+
+// AppendMessages 保存一轮对话的消息并更新会话时间
+func (c ChatSessionApi) AppendMessages(sessionId uint, messages ...ChatMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	for i := range messages {
+		messages[i].SessionId = sessionId
+		messages[i].Tokens = estimateTokens(messages[i].Content) + estimateTokens(messages[i].ToolCalls)
+	}
+	err := db.Dao.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&messages).Error; err != nil {
+			return err
+		}
+		return tx.Model(&ChatSession{}).Where("id = ?", sessionId).Update("updated_at", time.Now()).Error
+	})
+	if err != nil {
+		logger.SugaredLogger.Errorf("save chat message error:%s", err.Error())
+	}
+	return err
+}
+
+// History 按 token 预算从最近的消息往前截取历史,从完整的一轮(用户消息)开始,保证工具调用和结果成对出现
+func (c ChatSessionApi) History(sessionId uint, budget int) []ChatMessage {
+	messages := *c.GetMessages(sessionId)
+	start, used := len(messages), 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		used += messages[i].Tokens + 4
+		if used > budget {
+			break
+		}
+		start = i
+	}
+	for start < len(messages) && messages[start].Role != ChatRoleUser {
+		start++
+	}
+	return messages[start:]
+}
+
+// Export 导出会话为 Markdown
+func (c ChatSessionApi) Export(sessionId uint) (string, error) {
+	session := c.GetSession(sessionId)
+	if session == nil {
+		return "", fmt.Errorf("会话不存在")
+	}
+	var sb strings.Builder
+	sb.WriteString("# " + session.Title + "\n\n")
+	if session.StockCode != "" {
+		sb.WriteString(fmt.Sprintf("- 股票:%s(%s)\n", session.StockName, session.StockCode))
+	}
+	sb.WriteString("- 创建时间:" + session.CreatedAt.Format(time.DateTime) + "\n")
+	for _, message := range *c.GetMessages(sessionId) {
+		switch message.Role {
+		case ChatRoleUser:
+			sb.WriteString("\n## 提问 " + message.CreatedAt.Format(time.DateTime) + "\n\n" + message.Content + "\n")
+		case ChatRoleTool:
+			sb.WriteString("\n```\n" + message.Content + "\n```\n")
+		default:
+			sb.WriteString("\n## 回答\n\n")
+			if message.ReasoningContent != "" {
+				sb.WriteString("> " + strings.ReplaceAll(strings.TrimSpace(message.ReasoningContent), "\n", "\n> ") + "\n\n")
+			}
+			for _, call := range chatToolCalls(message.ToolCalls) {
+				function, _ := call["function"].(map[string]any)
+				sb.WriteString(fmt.Sprintf("调用工具:%v %v\n", function["name"], function["arguments"]))
+			}
+			sb.WriteString(message.Content + "\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// ChatHistoryMessages 会话历史转换为 chat/completions 的 messages,不使用工具时去掉工具调用和结果
+func ChatHistoryMessages(history []ChatMessage, withTools bool) []map[string]any {
+	var messages []map[string]any
+	for _, message := range history {
+		calls := chatToolCalls(message.ToolCalls)
+		if !withTools && (message.Role == ChatRoleTool || len(calls) > 0) {
+			continue
+		}
+		item := map[string]any{
+			"role":    message.Role,
+			"content": message.Content,
+		}
+		if message.ReasoningContent != "" {
+			item["reasoning_content"] = message.ReasoningContent
+		}
+		if len(calls) > 0 {
+			item["tool_calls"] = calls
+		}
+		if message.ToolCallId != "" {
+			item["tool_call_id"] = message.ToolCallId
+		}
+		messages = append(messages, item)
+	}
+	return messages
+}
+
+func chatToolCalls(value string) []map[string]any {
+	var calls []map[string]any
+	if value != "" {
+		_ = json.Unmarshal([]byte(value), &calls)
+	}
+	return calls
+}
+
+// WithSession 在会话中对话:回放会话历史,对话结束后保存本轮消息
+func (o *OpenAi) WithSession(session *ChatSession) *OpenAi {
+	o.session = session
+	o.recorder = &chatRecorder{}
+	return o
+}
+
+// chatRecorder 记录一轮对话中模型的输出,包括工具调用、工具结果和最终回答;nil 时不记录
+type chatRecorder struct {
+	messages  []ChatMessage
+	content   strings.Builder
+	reasoning strings.Builder
+}
+
+func (r *chatRecorder) writeContent(content string) {
+	if r != nil {
+		r.content.WriteString(content)
+	}
+}
+
+func (r *chatRecorder) writeReasoning(reasoning string) {
+	if r != nil {
+		r.reasoning.WriteString(reasoning)
+	}
+}
+
+// writeToolMessages 记录工具调用及结果,此前输出的内容已包含在工具调用消息中
+func (r *chatRecorder) writeToolMessages(messages []map[string]any) {
+	if r == nil {
+		return
+	}
+	for _, message := range messages {
+		item := ChatMessage{
+			Role:             fmt.Sprint(message["role"]),
+			Content:          fmt.Sprint(lo.ValueOr(message, "content", "")),
+			ReasoningContent: fmt.Sprint(lo.ValueOr(message, "reasoning_content", "")),
+			ToolCallId:       fmt.Sprint(lo.ValueOr(message, "tool_call_id", "")),
+		}
+		if calls, ok := message["tool_calls"]; ok {
+			bytes, _ := json.Marshal(calls)
+			item.ToolCalls = string(bytes)
+		}
+		r.messages = append(r.messages, item)
+	}
+	r.content.Reset()
+	r.reasoning.Reset()
+}
+
+// result 本轮记录的消息,没有最终回答时返回 nil
+func (r *chatRecorder) result() []ChatMessage {
+	if r == nil || strings.TrimSpace(r.content.String()) == "" {
+		return nil
+	}
+	return append(r.messages, ChatMessage{
+		Role:             ChatRoleAssistant,
+		Content:          r.content.String(),
+		ReasoningContent: r.reasoning.String(),
+	})
+}
+
+func chatSessionTitle(question, stockName string) string {
+	title := strings.Join(strings.Fields(question), " ")
+	if title == "" {
+		title = stockName + "分析"
+	}
+	if utf8.RuneCountInString(title) > chatTitleMaxRunes {
+		title = string([]rune(title)[:chatTitleMaxRunes]) + "..."
+	}
+	return title
+}
+
+// estimateTokens 估算 token 数:汉字按一个 token,其他字符按四个一个 token
+func estimateTokens(text string) int {
+	han, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			han++
+		} else {
+			other++
+		}
+	}
+	return han + (other+3)/4
+}
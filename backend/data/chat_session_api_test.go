@@ -0,0 +1,129 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// @Author spark
+// @Date 2025/10/18 16:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestEstimateTokens(t *testing.T) {
+	if n := estimateTokens("贵州茅台"); n != 4 {
+		t.Errorf("han tokens %d", n)
+	}
+	if n := estimateTokens("hello world!"); n != 3 {
+		t.Errorf("ascii tokens %d", n)
+	}
+	if title := chatSessionTitle("  ", "贵州茅台"); title != "贵州茅台分析" {
+		t.Errorf("title %s", title)
+	}
+	if title := chatSessionTitle(strings.Repeat("问", 40), ""); title != strings.Repeat("问", chatTitleMaxRunes)+"..." {
+		t.Errorf("title should be truncated %s", title)
+	}
+}
+
+func TestChatRecorder(t *testing.T) {
+	var nilRecorder *chatRecorder
+	nilRecorder.writeContent("ignored")
+	if nilRecorder.result() != nil {
+		t.Fatalf("nil recorder should record nothing")
+	}
+
+	recorder := &chatRecorder{}
+	recorder.writeReasoning("先查询行情")
+	recorder.writeContent("调用工具")
+	recorder.writeToolMessages([]map[string]any{
+		{"role": "assistant", "content": "调用工具", "reasoning_content": "先查询行情", "tool_calls": []map[string]any{{"id": "call_1", "type": "function", "function": map[string]string{"name": "QueryPrice", "arguments": "{}"}}}},
+		{"role": "tool", "content": "价格 10.5", "tool_call_id": "call_1"},
+	})
+	if recorder.result() != nil {
+		t.Errorf("no final answer yet")
+	}
+	recorder.writeReasoning("价格上涨")
+	recorder.writeContent("建议持有")
+	messages := recorder.result()
+	if len(messages) != 3 {
+		t.Fatalf("unexpected messages %+v", messages)
+	}
+	if messages[0].ToolCalls == "" || messages[0].ReasoningContent != "先查询行情" || messages[1].ToolCallId != "call_1" {
+		t.Errorf("tool messages %+v", messages[:2])
+	}
+	if messages[2].Role != ChatRoleAssistant || messages[2].Content != "建议持有" || messages[2].ReasoningContent != "价格上涨" {
+		t.Errorf("final answer %+v", messages[2])
+	}
+
+	replay := ChatHistoryMessages(messages, true)
+	if len(replay) != 3 || replay[0]["tool_calls"] == nil || replay[1]["tool_call_id"] != "call_1" {
+		t.Errorf("replay with tools %+v", replay)
+	}
+	if replay = ChatHistoryMessages(messages, false); len(replay) != 1 || replay[0]["content"] != "建议持有" {
+		t.Errorf("replay without tools %+v", replay)
+	}
+}
+
+func TestChatSessionApi(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "chat.db"))
+	db.Dao.AutoMigrate(&ChatSession{}, &ChatMessage{})
+
+	api := NewChatSessionApi()
+	promptId := 3
+	session := api.CreateSession(ChatKindStock, "", "sh600519", "贵州茅台", 1, &promptId)
+	if session.ID == 0 || session.Title != "贵州茅台分析" || *session.SysPrompt() != 3 {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	api.CreateSession(ChatKindAgent, "今天市场怎么样", "", "", 1, nil)
+
+	for i, content := range []string{"第一轮问题", "第二轮问题"} {
+		err := api.AppendMessages(session.ID,
+			ChatMessage{Role: ChatRoleUser, Content: content},
+			ChatMessage{Role: ChatRoleAssistant, Content: strings.Repeat("答", 100*(i+1)), ReasoningContent: "思考"},
+		)
+		if err != nil {
+			t.Fatalf("append:%s", err.Error())
+		}
+	}
+	if history := api.History(session.ID, ChatHistoryTokenBudget); len(history) != 4 {
+		t.Errorf("history within budget %d", len(history))
+	}
+	//预算不足以容纳第一轮时从第二轮的提问开始
+	history := api.History(session.ID, 250)
+	if len(history) != 2 || history[0].Content != "第二轮问题" {
+		t.Errorf("history should start at a user message %+v", history)
+	}
+	if history = api.History(session.ID, 10); len(history) != 0 {
+		t.Errorf("history over budget %+v", history)
+	}
+
+	if sessions := *api.GetSessions(ChatKindStock); len(sessions) != 1 {
+		t.Errorf("stock sessions %d", len(sessions))
+	}
+	if sessions := *api.GetSessions(""); len(sessions) != 2 || sessions[0].ID != session.ID {
+		t.Errorf("sessions should be ordered by update time %+v", sessions)
+	}
+	if msg := api.RenameSession(session.ID, "茅台复盘"); msg != "修改成功" {
+		t.Errorf("rename:%s", msg)
+	}
+	if msg := api.RenameSession(999, "x"); msg != "会话不存在" {
+		t.Errorf("rename missing:%s", msg)
+	}
+
+	content, err := api.Export(session.ID)
+	if err != nil || !strings.Contains(content, "# 茅台复盘") || !strings.Contains(content, "第二轮问题") || !strings.Contains(content, "> 思考") {
+		t.Errorf("export:%v\n%s", err, content)
+	}
+	if _, err = api.Export(999); err == nil {
+		t.Errorf("export missing session should fail")
+	}
+
+	if msg := api.DeleteSession(session.ID); msg != "删除成功" {
+		t.Errorf("delete:%s", msg)
+	}
+	if api.GetSession(session.ID) != nil || len(*api.GetMessages(session.ID)) != 0 {
+		t.Errorf("session and messages should be deleted")
+	}
+}
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"strings"
+	"unicode"
+)
+
+// @Author spark
+// @Date 2025/10/18 9:30
+// @Desc 英文资讯情感分析:语言识别、英文分词和词形还原,英文金融词典与中文词典共用情感词表和打分规则
+// -----------------------------------------------------------------------------------
+
+// 英文金融情感词典，作为情感词表的初始数据
+var (
+	defaultEnglishPositiveWords = map[string]float64{
+		"gain": 1.5, "rise": 1.5, "rose": 1.5, "rally": 2.0, "surge": 2.5, "soar": 3.0, "jump": 2.0,
+		"climb": 1.5, "beat": 2.0, "upgrade": 2.0, "outperform": 2.0, "bullish": 2.5, "record": 1.5,
+		"strong": 1.5, "growth": 1.5, "grow": 1.5, "grew": 1.5, "profit": 1.5, "rebound": 2.0,
+		"recover": 1.5, "recovery": 1.5, "boost": 1.5, "optimism": 2.0, "optimistic": 2.0, "buy": 1.5,
+		"higher": 1.5, "exceed": 2.0, "upbeat": 2.0, "breakthrough": 2.0, "approve": 1.0, "expand": 1.0,
+	}
+
+	defaultEnglishNegativeWords = map[string]float64{
+		"fall": 1.5, "fell": 1.5, "drop": 1.5, "decline": 1.5, "slump": 2.5, "plunge": 3.0, "tumble": 2.5,
+		"sink": 2.0, "sank": 2.0, "slide": 1.5, "slid": 1.5, "loss": 2.0, "lost": 1.5, "miss": 2.0,
+		"downgrade": 2.0, "underperform": 2.0, "bearish": 2.5, "weak": 1.5, "weaker": 1.5, "lower": 1.5,
+		"cut": 1.5, "fear": 2.0, "concern": 1.5, "crash": 3.0, "selloff": 2.5, "recession": 2.5,
+		"default": 2.0, "bankruptcy": 3.0, "lawsuit": 2.0, "probe": 2.0, "investigation": 2.0,
+		"layoff": 2.0, "warn": 2.0, "warning": 2.0, "slowdown": 2.0, "sanction": 1.5,
+	}
+
+	defaultEnglishNegationWords = map[string]struct{}{
+		"not": {}, "no": {}, "never": {}, "without": {}, "neither": {}, "nor": {}, "hardly": {},
+	}
+
+	defaultEnglishDegreeWords = map[string]float64{
+		"very": 1.5, "sharply": 1.8, "significantly": 1.5, "strongly": 1.8, "highly": 1.5, "extremely": 2.2,
+		"substantially": 1.8, "slightly": 0.6, "modestly": 0.7, "marginally": 0.6,
+	}
+
+	defaultEnglishTransitionWords = map[string]struct{}{
+		"but": {}, "however": {}, "yet": {},
+	}
+
+	//不影响情感的虚词,去掉后否定词、程度副词能与情感词相邻,如 did not fall、is not a loss
+	englishStopWords = map[string]struct{}{
+		"a": {}, "an": {}, "the": {}, "to": {}, "be": {}, "been": {}, "is": {}, "are": {}, "was": {}, "were": {},
+		"do": {}, "does": {}, "did": {}, "has": {}, "have": {}, "had": {}, "will": {}, "would": {}, "its": {},
+	}
+)
+
+// isEnglishText 拉丁字母明显多于汉字时按英文处理
+func isEnglishText(text string) bool {
+	han, letters := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			letters++
+		}
+	}
+	return letters > 0 && letters > han*4
+}
+
+// englishWords 英文分词:小写化,n't 转为 not,按非字母切分,去掉虚词并词形还原为情感词表中的原形;
+// 英文程度副词常在情感词之后(shares plunged sharply),调整到情感词之前以便按中文规则打分
+func englishWords(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "n't", " not")
+	text = strings.ReplaceAll(text, "sell-off", "selloff")
+	lexicon := currentSentimentLexicon()
+	var words []string
+	for _, token := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if _, ok := englishStopWords[token]; ok {
+			continue
+		}
+		word := lexicon.englishLemma(token)
+		if n := len(words); n > 0 && lexicon.isDegree(word) && lexicon.isSentiment(words[n-1]) && (n < 2 || !lexicon.isDegree(words[n-2])) {
+			words = append(words[:n-1], word, words[n-1])
+			continue
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// englishLemma 去掉复数、过去式、进行时等词尾后在词表中查找,找不到时返回原词
+func (l *sentimentLexicon) englishLemma(token string) string {
+	for _, candidate := range englishStems(token) {
+		if l.contains(candidate) {
+			return candidate
+		}
+	}
+	return token
+}
+
+func (l *sentimentLexicon) isSentiment(word string) bool {
+	if _, ok := l.positive[word]; ok {
+		return true
+	}
+	_, ok := l.negative[word]
+	return ok
+}
+
+func (l *sentimentLexicon) isDegree(word string) bool {
+	_, ok := l.degree[word]
+	return ok
+}
+
+func (l *sentimentLexicon) contains(word string) bool {
+	if l.isSentiment(word) || l.isDegree(word) {
+		return true
+	}
+	if _, ok := l.negation[word]; ok {
+		return true
+	}
+	_, ok := l.transition[word]
+	return ok
+}
+
+// englishStems 原词及可能的原形,如 rallied→rally、surged→surge、dropped→drop、rising→rise
+func englishStems(token string) []string {
+	stems := []string{token}
+	undouble := func(base string) string {
+		if n := len(base); n > 2 && base[n-1] == base[n-2] {
+			return base[:n-1]
+		}
+		return base
+	}
+	for _, suffix := range []string{"ies", "ied"} {
+		if base, ok := strings.CutSuffix(token, suffix); ok && base != "" {
+			stems = append(stems, base+"y")
+		}
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		if base, ok := strings.CutSuffix(token, suffix); ok && len(base) > 1 {
+			stems = append(stems, base, base+"e", undouble(base))
+		}
+	}
+	for _, suffix := range []string{"es", "s"} {
+		if base, ok := strings.CutSuffix(token, suffix); ok && len(base) > 1 {
+			stems = append(stems, base)
+		}
+	}
+	return stems
+}
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// @Author spark
+// @Date 2025/10/18 11:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestIsEnglishText(t *testing.T) {
+	cases := map[string]bool{
+		"Stocks surged after earnings beat":  true,
+		"贵州茅台发布年报,净利润同比增长":                   false,
+		"英伟达(NVDA)盘前上涨":                      false,
+		"Apple 发布 iPhone 17, shares rose 3%": true,
+		"2025-10-18 12:00":                   false,
+	}
+	for text, want := range cases {
+		if got := isEnglishText(text); got != want {
+			t.Errorf("isEnglishText(%q)=%v", text, got)
+		}
+	}
+}
+
+func TestEnglishWords(t *testing.T) {
+	defer activeSentimentLexicon.Store(nil)
+	activeSentimentLexicon.Store(nil)
+	cases := map[string][]string{
+		"Oil prices rallied":                  {"oil", "prices", "rally"},
+		"Shares dropped, rising fears":        {"shares", "drop", "rise", "fear"},
+		"Chipmakers surged; Tesla sinks":      {"chipmakers", "surge", "tesla", "sink"},
+		"Markets didn't see a sell-off":       {"markets", "not", "see", "selloff"},
+		"The stock plunged sharply on Monday": {"stock", "sharply", "plunge", "on", "monday"},
+	}
+	for text, want := range cases {
+		if got := englishWords(text); !slices.Equal(got, want) {
+			t.Errorf("englishWords(%q)=%v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestAnalyzeEnglishSentiment(t *testing.T) {
+	defer activeSentimentLexicon.Store(nil)
+	activeSentimentLexicon.Store(nil)
+
+	if result := AnalyzeSentiment("Stocks surged after earnings beat expectations"); result.Category != Positive {
+		t.Errorf("positive headline got %+v", result)
+	}
+	if result := AnalyzeSentiment("Shares plunged as the company warned of losses"); result.Category != Negative {
+		t.Errorf("negative headline got %+v", result)
+	}
+	plain := AnalyzeSentiment("Shares fell")
+	negated := AnalyzeSentiment("Shares did not fall")
+	if plain.Score >= 0 || negated.Score <= 0 {
+		t.Errorf("negation should flip the score: %f %f", plain.Score, negated.Score)
+	}
+	if sharp := AnalyzeSentiment("Shares fell sharply"); sharp.Score >= plain.Score {
+		t.Errorf("intensifier should strengthen the score: %f %f", sharp.Score, plain.Score)
+	}
+	if result := AnalyzeSentiment("Revenue grew, but the outlook was cut sharply"); result.Score >= 0 {
+		t.Errorf("clause after transition should dominate, got %f", result.Score)
+	}
+	if result := AnalyzeSentiment("Fed meeting scheduled for Wednesday"); result.Category != Neutral {
+		t.Errorf("neutral headline got %+v", result)
+	}
+}
+
+func TestEnglishSentimentLexicon(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "lexicon.db"))
+	InitSentimentLexicon()
+	defer activeSentimentLexicon.Store(nil)
+
+	api := NewSentimentLexiconApi()
+	cnt := int64(0)
+	db.Dao.Model(&SentimentWord{}).Where("lang = ?", SentimentLangEn).Count(&cnt)
+	if cnt == 0 {
+		t.Fatalf("english lexicon should be seeded")
+	}
+	if result := AnalyzeSentiment("Analysts expect a Windfall"); result.Score != 0 {
+		t.Errorf("unknown word should not score %f", result.Score)
+	}
+	if msg := api.SaveWord(SentimentPositive, "Windfall", 2); msg != "保存成功" {
+		t.Fatalf("save:%s", msg)
+	}
+	if !slices.ContainsFunc(*api.GetWords(SentimentPositive), func(item SentimentWord) bool {
+		return item.Word == "windfall" && item.Lang == SentimentLangEn
+	}) {
+		t.Errorf("english word should be saved in lower case")
+	}
+	if result := AnalyzeSentiment("Analysts expect a Windfall"); result.Category != Positive {
+		t.Errorf("saved english word should take effect, got %+v", result)
+	}
+
+	//只有中文词时补充英文内置词典
+	db.Dao.Unscoped().Where("lang = ?", SentimentLangEn).Delete(&SentimentWord{})
+	InitSentimentLexicon()
+	if n := len(*api.GetWords("")); n != len(defaultSentimentWords()) {
+		t.Errorf("english lexicon should be seeded again, got %d", n)
+	}
+}
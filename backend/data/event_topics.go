@@ -0,0 +1,45 @@
+package data
+
+import (
+	"lumos-stock/backend/events"
+	"lumos-stock/backend/models"
+)
+
+// @Author spark
+// @Date 2025/9/27 11:00
+// @Desc 事件主题,主题名即前端 EventsOn 监听的事件名
+// -----------------------------------------------------------------------------------
+
+// 行情
+var (
+	TopicPriceTick      = events.NewTopic[StockInfo]("stock_price")
+	TopicRealtimeProfit = events.NewTopic[RealtimeProfit]("realtime_profit")
+	TopicAlert          = events.NewTopic[AlertHistory]("stock_alert")
+)
+
+// 资讯
+var (
+	TopicTelegraph       = events.NewTopic[*[]models.Telegraph]("newTelegraph")
+	TopicSinaNews        = events.NewTopic[*[]models.Telegraph]("newSinaNews")
+	TopicTradingViewNews = events.NewTopic[*[]models.Telegraph]("tradingViewNews")
+	TopicSourceNews      = events.NewTopic[*[]models.Telegraph]("newSourceNews") //其他资讯来源,如 RSS 订阅
+	TopicClsTelegraph    = events.NewTopic[*[]string]("telegraph")
+	TopicNewsPush        = events.NewTopic[models.Telegraph]("newsPush")
+)
+
+// AI分析进度
+var (
+	TopicChatStream    = events.NewStreamTopic[map[string]any]("newChatStream")
+	TopicSummaryStream = events.NewStreamTopic[map[string]any]("summaryStockNews")
+	// TopicAgentMessage 载荷为 agent 的 *schema.Message,data 包不依赖 eino 因此不限定类型
+	TopicAgentMessage = events.NewTopic[any]("agent-message")
+	// TopicChatSession 新建对话会话,前端据此继续对话
+	TopicChatSession = events.NewTopic[*ChatSession]("chatSession")
+)
+
+// 应用提示
+var (
+	TopicWarnMsg       = events.NewTopic[string]("warnMsg")
+	TopicLoadingMsg    = events.NewTopic[string]("loadingMsg")
+	TopicUpdateVersion = events.NewTopic[*models.GitHubReleaseVersion]("updateVersion")
+)
@@ -0,0 +1,224 @@
+package data
+
+import (
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/go-resty/resty/v2"
+)
+
+// @Author spark
+// @Date 2025/9/20 10:30
+// @Desc 汇率服务,从新浪外汇行情获取人民币汇率并缓存,用于持仓盈亏按基础货币汇总
+// -----------------------------------------------------------------------------------
+
+const (
+	CurrencyCNY = "CNY"
+	CurrencyHKD = "HKD"
+	CurrencyUSD = "USD"
+
+	// fxRateTTL 汇率缓存时间
+	fxRateTTL = 10 * time.Minute
+	// fxRetryInterval 刷新失败后的重试间隔
+	fxRetryInterval = time.Minute
+)
+
+// defaultFxRates 从未成功获取汇率时使用的参考汇率(1外币兑人民币)
+var defaultFxRates = map[string]float64{
+	CurrencyCNY: 1,
+	CurrencyHKD: 0.91,
+	CurrencyUSD: 7.1,
+}
+
+type fxRate struct {
+	rate      float64
+	updatedAt time.Time
+}
+
+var (
+	fxRates       = map[string]fxRate{}
+	fxLastRefresh time.Time
+	fxRatesMu     sync.RWMutex
+)
+
+type FxRateApi struct {
+	client *resty.Client
+}
+
+func NewFxRateApi() *FxRateApi {
+	return &FxRateApi{client: resty.New().SetTimeout(5 * time.Second)}
+}
+
+// CurrencyOf 股票交易货币
+func CurrencyOf(stockCode string) string {
+	switch GetQuoteMarket(stockCode) {
+	case QuoteMarketHK:
+		return CurrencyHKD
+	case QuoteMarketUS:
+		return CurrencyUSD
+	}
+	return CurrencyCNY
+}
+
+// CurrencySymbol 货币符号
+func CurrencySymbol(currency string) string {
+	switch strings.ToUpper(currency) {
+	case CurrencyHKD:
+		return "HK$"
+	case CurrencyUSD:
+		return "$"
+	}
+	return "¥"
+}
+
+// RealtimeProfit 按持仓汇总货币计算的当日盈亏
+type RealtimeProfit struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// Text 托盘和窗口标题上的展示文本
+func (p RealtimeProfit) Text() string {
+	return fmt.Sprintf("  %.2f%s", p.Amount, CurrencySymbol(p.Currency))
+}
+
+// GetBaseCurrency 设置中的持仓汇总货币,未设置时为人民币
+func GetBaseCurrency() string {
+	settings := &Settings{}
+	db.Dao.Model(&Settings{}).Select("base_currency").First(settings)
+	if settings.BaseCurrency == "" {
+		return CurrencyCNY
+	}
+	return strings.ToUpper(settings.BaseCurrency)
+}
+
+// Convert 金额换算
+func (f FxRateApi) Convert(amount float64, from, to string) float64 {
+	if amount == 0 {
+		return 0
+	}
+	return amount * f.GetRate(from, to)
+}
+
+// GetRate 1单位 from 货币可兑换的 to 货币数量,通过人民币交叉换算
+func (f FxRateApi) GetRate(from, to string) float64 {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == "" {
+		from = CurrencyCNY
+	}
+	if to == "" {
+		to = CurrencyCNY
+	}
+	if from == to {
+		return 1
+	}
+	toRate := f.cnyRate(to)
+	if toRate == 0 {
+		return 1
+	}
+	return f.cnyRate(from) / toRate
+}
+
+// GetRates 所有支持货币兑人民币的汇率
+func (f FxRateApi) GetRates() map[string]float64 {
+	res := make(map[string]float64, len(defaultFxRates))
+	for currency := range defaultFxRates {
+		res[currency] = f.cnyRate(currency)
+	}
+	return res
+}
+
+// cnyRate 1单位外币兑人民币,缓存过期时刷新,获取失败时沿用上次汇率
+func (f FxRateApi) cnyRate(currency string) float64 {
+	if currency == CurrencyCNY {
+		return 1
+	}
+	fxRatesMu.RLock()
+	cached, ok := fxRates[currency]
+	lastRefresh := fxLastRefresh
+	fxRatesMu.RUnlock()
+	if ok && time.Since(cached.updatedAt) < fxRateTTL {
+		return cached.rate
+	}
+	if time.Since(lastRefresh) >= fxRetryInterval {
+		if err := f.Refresh(); err != nil {
+			logger.SugaredLogger.Errorf("刷新汇率失败:%s", err.Error())
+		}
+	}
+	fxRatesMu.RLock()
+	defer fxRatesMu.RUnlock()
+	if cached, ok = fxRates[currency]; ok {
+		return cached.rate
+	}
+	return defaultFxRates[currency]
+}
+
+// Refresh 从新浪外汇行情刷新汇率,如 fx_susdcny、fx_shkdcny
+func (f FxRateApi) Refresh() error {
+	fxRatesMu.Lock()
+	fxLastRefresh = time.Now()
+	fxRatesMu.Unlock()
+
+	codes := make([]string, 0, len(defaultFxRates))
+	for currency := range defaultFxRates {
+		if currency != CurrencyCNY {
+			codes = append(codes, "fx_s"+strings.ToLower(currency)+"cny")
+		}
+	}
+	resp, err := f.client.R().
+		SetHeader("Host", "hq.sinajs.cn").
+		SetHeader("Referer", "https://finance.sina.com.cn/").
+		SetHeader("User-Agent", userAgent).
+		Get(fmt.Sprintf(sinaStockUrl, time.Now().Unix(), strings.Join(codes, ",")))
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("sina fx status:%d", resp.StatusCode())
+	}
+	rates := ParseSinaFxRates(GB18030ToUTF8(resp.Body()))
+	if len(rates) == 0 {
+		return fmt.Errorf("sina fx 无数据")
+	}
+	now := time.Now()
+	fxRatesMu.Lock()
+	defer fxRatesMu.Unlock()
+	for currency, rate := range rates {
+		fxRates[currency] = fxRate{rate: rate, updatedAt: now}
+	}
+	return nil
+}
+
+// ParseSinaFxRates 解析新浪外汇行情
+// var hq_str_fx_susdcny="时间,买入价,卖出价,昨收,点差,开盘,最高,最低,最新价,名称,..."
+func ParseSinaFxRates(str string) map[string]float64 {
+	rates := make(map[string]float64)
+	for _, line := range strutil.SplitEx(str, "\n", true) {
+		datas := strutil.SplitAndTrim(line, "=", "\";")
+		if len(datas) < 2 || !strings.Contains(datas[0], "hq_str_fx_s") {
+			continue
+		}
+		pair := strings.ToUpper(strutil.After(datas[0], "hq_str_fx_s"))
+		if len(pair) != 6 || !strings.HasSuffix(pair, CurrencyCNY) {
+			continue
+		}
+		fields := strings.Split(datas[1], ",")
+		if len(fields) < 9 {
+			continue
+		}
+		rate, _ := convertor.ToFloat(fields[8])
+		if rate <= 0 {
+			rate, _ = convertor.ToFloat(fields[1])
+		}
+		if rate > 0 {
+			rates[pair[:3]] = rate
+		}
+	}
+	return rates
+}
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/20 14:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestParseSinaFxRates(t *testing.T) {
+	str := `var hq_str_fx_susdcny="15:29:58,7.1325,7.1335,7.1290,25,7.1300,7.1380,7.1270,7.1330,在岸人民币,0.06,0.0040,0.0014,Onshore Yuan,0,0,0,0,2025-09-19";
+var hq_str_fx_shkdcny="15:29:58,0.9160,0.9170,0.9150,10,0.9152,0.9175,0.9148,0.9165,港元人民币,0.16,0.0015,0.0027,0,0,0,0,0,2025-09-19";
+var hq_str_fx_seurusd="";`
+	rates := ParseSinaFxRates(str)
+	if rates[CurrencyUSD] != 7.1330 || rates[CurrencyHKD] != 0.9165 {
+		t.Errorf("unexpected rates %v", rates)
+	}
+	if len(rates) != 2 {
+		t.Errorf("only cny pairs should be parsed %v", rates)
+	}
+}
+
+func TestFxConvert(t *testing.T) {
+	fxRatesMu.Lock()
+	fxRates[CurrencyUSD] = fxRate{rate: 7.2, updatedAt: time.Now()}
+	fxRates[CurrencyHKD] = fxRate{rate: 0.9, updatedAt: time.Now()}
+	fxRatesMu.Unlock()
+
+	fx := NewFxRateApi()
+	if v := fx.Convert(100, CurrencyUSD, CurrencyCNY); v != 720 {
+		t.Errorf("usd->cny got %v", v)
+	}
+	if v := fx.Convert(720, CurrencyCNY, CurrencyUSD); v != 100 {
+		t.Errorf("cny->usd got %v", v)
+	}
+	if v := fx.Convert(100, CurrencyUSD, CurrencyHKD); v != 800 {
+		t.Errorf("usd->hkd got %v", v)
+	}
+	if CurrencyOf("hk00700") != CurrencyHKD || CurrencyOf("gb_aapl") != CurrencyUSD || CurrencyOf("sz000001") != CurrencyCNY {
+		t.Error("unexpected currency of stock code")
+	}
+}
+
+func TestUpdateConfigKeepsBaseCurrency(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "settings.db"))
+	db.Dao.AutoMigrate(&Settings{}, &AIConfig{})
+	db.Dao.Create(&Settings{BaseCurrency: CurrencyUSD})
+
+	settings := &Settings{}
+	db.Dao.First(settings)
+	settings.BaseCurrency = ""
+	UpdateConfig(&SettingConfig{Settings: settings})
+	if currency := GetBaseCurrency(); currency != CurrencyUSD {
+		t.Errorf("base currency should be kept, got %s", currency)
+	}
+
+	settings.BaseCurrency = "hkd"
+	UpdateConfig(&SettingConfig{Settings: settings})
+	if currency := GetBaseCurrency(); currency != CurrencyHKD {
+		t.Errorf("base currency should be updated, got %s", currency)
+	}
+}
@@ -0,0 +1,309 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/9/8 9:40
+// @Desc 本地K线数据存储,按最后一根K线增量同步
+// -----------------------------------------------------------------------------------
+
+const (
+	KLinePeriodDay   = "day"
+	KLinePeriodWeek  = "week"
+	KLinePeriodMonth = "month"
+
+	defaultKLineDays = 365
+	//同一只股票同一周期两次同步的最小间隔
+	kLineSyncInterval = time.Minute
+)
+
+// KLineBar K线数据 (code, period, day) 唯一
+type KLineBar struct {
+	gorm.Model
+	Code   string  `json:"code" gorm:"uniqueIndex:idx_kline_bar,priority:1"`
+	Period string  `json:"period" gorm:"uniqueIndex:idx_kline_bar,priority:2"`
+	Day    string  `json:"day" gorm:"uniqueIndex:idx_kline_bar,priority:3"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume float64 `json:"volume"`
+}
+
+func (KLineBar) TableName() string {
+	return "kline_bar"
+}
+
+func (k KLineBar) ToKLineData() KLineData {
+	return KLineData{
+		Day:    k.Day,
+		Open:   convertor.ToString(k.Open),
+		High:   convertor.ToString(k.High),
+		Low:    convertor.ToString(k.Low),
+		Close:  convertor.ToString(k.Close),
+		Volume: convertor.ToString(k.Volume),
+	}
+}
+
+var kLineLastSync sync.Map
+
+// kLineFullHistory 远端返回的K线少于请求数量的股票,本地已是全部历史,不再按请求数量补齐
+var kLineFullHistory sync.Map
+
+type KLineStoreApi struct {
+}
+
+func NewKLineStoreApi() *KLineStoreApi {
+	return &KLineStoreApi{}
+}
+
+// GetKLine 读取本地K线,本地数据过期或不足时先增量同步;网络不可用时返回已有的本地数据
+func (receiver KLineStoreApi) GetKLine(stockCode, period string, days int64) *[]KLineData {
+	if days <= 0 {
+		days = defaultKLineDays
+	}
+	if period == "" {
+		period = KLinePeriodDay
+	}
+	code := NormalizeKLineCode(stockCode)
+	receiver.syncIfStale(code, period, days)
+	return receiver.QueryKLine(code, period, days)
+}
+
+// QueryKLine 只读取本地K线数据,按日期升序返回最近 days 根
+func (receiver KLineStoreApi) QueryKLine(stockCode, period string, days int64) *[]KLineData {
+	var bars []KLineBar
+	db.Dao.Model(&KLineBar{}).
+		Where("code = ? and period = ?", NormalizeKLineCode(stockCode), period).
+		Order("day desc").Limit(int(days)).Find(&bars)
+	K := make([]KLineData, 0, len(bars))
+	for i := len(bars) - 1; i >= 0; i-- {
+		K = append(K, bars[i].ToKLineData())
+	}
+	return &K
+}
+
+func (receiver KLineStoreApi) syncIfStale(code, period string, days int64) {
+	key := code + ":" + period
+	if v, ok := kLineLastSync.Load(key); ok && time.Since(v.(time.Time)) < kLineSyncInterval {
+		return
+	}
+	var count int64
+	db.Dao.Model(&KLineBar{}).Where("code = ? and period = ?", code, period).Count(&count)
+	if _, full := kLineFullHistory.Load(key); count < days && !full {
+		//本地数据不足,按请求数量补齐
+		n, err := receiver.fetchAndSave(code, period, days)
+		if err == nil && int64(n) < days {
+			kLineFullHistory.Store(key, true)
+		}
+	} else {
+		receiver.SyncKLine(code, period)
+	}
+	kLineLastSync.Store(key, time.Now())
+}
+
+// SyncKLine 从最后一根已保存的K线开始增量同步,返回写入的K线数量
+func (receiver KLineStoreApi) SyncKLine(stockCode, period string) (int, error) {
+	code := NormalizeKLineCode(stockCode)
+	last := KLineBar{}
+	db.Dao.Model(&KLineBar{}).Where("code = ? and period = ?", code, period).Order("day desc").Limit(1).Find(&last)
+	if last.Day == "" {
+		return receiver.fetchAndSave(code, period, defaultKLineDays)
+	}
+	//多取一根已保存的K线,用来发现除权除息后前复权价格的变化
+	bars, err := fetchKLineBars(code, period, missingKLineCount(last.Day, period, time.Now())+1)
+	if err != nil {
+		return 0, err
+	}
+	if kLineAdjusted(code, period, bars) {
+		logger.SugaredLogger.Infof("%s %s 复权价格变化,重新同步全部K线", code, period)
+		return receiver.resyncKLine(code, period)
+	}
+	return saveKLineBars(bars)
+}
+
+// SyncFollowedKLine 同步所有关注股票的日K线,返回同步失败的股票及原因
+func (receiver KLineStoreApi) SyncFollowedKLine() error {
+	var follows []FollowedStock
+	db.Dao.Model(&FollowedStock{}).Find(&follows)
+	var errs []error
+	for _, follow := range follows {
+		n, err := receiver.SyncKLine(follow.StockCode, KLinePeriodDay)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s:%w", follow.StockCode, follow.Name, err))
+			continue
+		}
+		logger.SugaredLogger.Infof("SyncKLine %s %s bars:%d", follow.StockCode, follow.Name, n)
+	}
+	return errors.Join(errs...)
+}
+
+// fetchAndSave 拉取最近 count 根K线并保存
+func (receiver KLineStoreApi) fetchAndSave(code, period string, count int64) (int, error) {
+	bars, err := fetchKLineBars(code, period, count)
+	if err != nil {
+		return 0, err
+	}
+	return saveKLineBars(bars)
+}
+
+// resyncKLine 重新拉取全部K线替换本地数据,拉取数量不少于本地已有的数量
+func (receiver KLineStoreApi) resyncKLine(code, period string) (int, error) {
+	var count int64
+	db.Dao.Model(&KLineBar{}).Where("code = ? and period = ?", code, period).Count(&count)
+	bars, err := fetchKLineBars(code, period, max(count, defaultKLineDays))
+	if err != nil {
+		return 0, err
+	}
+	err = db.Dao.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("code = ? and period = ?", code, period).Delete(&KLineBar{}).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(&bars, 200).Error
+	})
+	if err != nil {
+		logger.SugaredLogger.Errorf("resync kline error:%s", err.Error())
+		return 0, err
+	}
+	return len(bars), nil
+}
+
+// kLineAdjusted 港股美股保存的是前复权价格,除权除息后历史价格整体变化;
+// 比较拉取结果中最早的一根(已收盘)与本地保存的收盘价,不一致时说明复权价格已变化
+func kLineAdjusted(code, period string, bars []KLineBar) bool {
+	if len(bars) < 2 {
+		return false
+	}
+	oldest := bars[0]
+	for _, bar := range bars[1:] {
+		if bar.Day < oldest.Day {
+			oldest = bar
+		}
+	}
+	var stored KLineBar
+	db.Dao.Model(&KLineBar{}).Where("code = ? and period = ? and day = ?", code, period, oldest.Day).Limit(1).Find(&stored)
+	return stored.ID != 0 && math.Abs(stored.Close-oldest.Close) > 1e-6*math.Max(math.Abs(stored.Close), 1)
+}
+
+// fetchKLineBars 拉取最近 count 根K线,count 至少为 1,取不到数据视为拉取失败
+func fetchKLineBars(code, period string, count int64) ([]KLineBar, error) {
+	K := fetchRemoteKLine(code, period, count)
+	if K == nil || len(*K) == 0 {
+		return nil, errors.New("获取K线数据失败")
+	}
+	bars := slice.Map(*K, func(i int, k KLineData) KLineBar {
+		return toKLineBar(code, period, k)
+	})
+	bars = slice.Filter(bars, func(i int, bar KLineBar) bool {
+		return bar.Day != ""
+	})
+	if len(bars) == 0 {
+		return nil, errors.New("K线数据缺少日期")
+	}
+	return bars, nil
+}
+
+// saveKLineBars 保存K线,最后一根K线在盘中会变化,冲突时更新
+func saveKLineBars(bars []KLineBar) (int, error) {
+	err := db.Dao.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}, {Name: "period"}, {Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "updated_at"}),
+	}).CreateInBatches(&bars, 200).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("save kline error:%s", err.Error())
+		return 0, err
+	}
+	return len(bars), nil
+}
+
+// fetchRemoteKLine A股使用新浪K线,港股美股及其他使用腾讯K线
+func fetchRemoteKLine(code, period string, count int64) *[]KLineData {
+	api := NewStockDataApi()
+	if strutil.HasPrefixAny(code, []string{"sh", "sz"}) {
+		return api.GetKLineData(code, sinaKLineScale(period), count)
+	}
+	if strings.HasPrefix(code, "us") {
+		return api.GetHK_KLineData(strings.Replace(code, "us", "gb_", 1), period, count)
+	}
+	return api.GetCommonKLineData(code, period, count)
+}
+
+func sinaKLineScale(period string) string {
+	switch period {
+	case KLinePeriodWeek:
+		return "1200"
+	case KLinePeriodMonth:
+		return "7200"
+	default:
+		return "240"
+	}
+}
+
+func toKLineBar(code, period string, k KLineData) KLineBar {
+	open, _ := convertor.ToFloat(k.Open)
+	high, _ := convertor.ToFloat(k.High)
+	low, _ := convertor.ToFloat(k.Low)
+	closePrice, _ := convertor.ToFloat(k.Close)
+	volume, _ := convertor.ToFloat(k.Volume)
+	day := k.Day
+	//分钟级别数据带时间,日线只保留日期
+	if len(day) > 10 && period == KLinePeriodDay {
+		day = day[:10]
+	}
+	return KLineBar{
+		Code:   code,
+		Period: period,
+		Day:    day,
+		Open:   open,
+		High:   high,
+		Low:    low,
+		Close:  closePrice,
+		Volume: volume,
+	}
+}
+
+// missingKLineCount 最后一根K线到今天需要补充的数量,包含最后一根用于刷新
+func missingKLineCount(lastDay, period string, now time.Time) int64 {
+	last, err := time.ParseInLocation(time.DateOnly, lastDay, now.Location())
+	if err != nil {
+		return defaultKLineDays
+	}
+	days := int64(now.Sub(last).Hours()/24) + 1
+	switch period {
+	case KLinePeriodWeek:
+		days = days/7 + 1
+	case KLinePeriodMonth:
+		days = days/30 + 1
+	}
+	if days < 1 {
+		days = 1
+	}
+	if days > defaultKLineDays {
+		days = defaultKLineDays
+	}
+	return days
+}
+
+// NormalizeKLineCode 统一股票代码格式,美股 gb_ 转为 us
+func NormalizeKLineCode(stockCode string) string {
+	code := strings.ToLower(strutil.Trim(stockCode))
+	if strings.HasPrefix(code, "gb_") {
+		code = strings.Replace(code, "gb_", "us", 1)
+	}
+	return code
+}
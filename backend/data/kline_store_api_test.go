@@ -0,0 +1,82 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/8 14:16
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestMissingKLineCount(t *testing.T) {
+	now := time.Date(2025, 9, 8, 15, 30, 0, 0, time.Local)
+	cases := []struct {
+		last   string
+		period string
+		want   int64
+	}{
+		{"2025-09-08", KLinePeriodDay, 1},
+		{"2025-09-05", KLinePeriodDay, 4},
+		{"2025-08-01", KLinePeriodWeek, 6},
+		{"2020-01-01", KLinePeriodDay, defaultKLineDays},
+		{"bad", KLinePeriodDay, defaultKLineDays},
+	}
+	for _, c := range cases {
+		if got := missingKLineCount(c.last, c.period, now); got != c.want {
+			t.Errorf("%s %s: got %d want %d", c.last, c.period, got, c.want)
+		}
+	}
+}
+
+func TestToKLineBar(t *testing.T) {
+	bar := toKLineBar("sh600000", KLinePeriodDay, KLineData{
+		Day: "2025-09-08 15:00:00", Open: "10.1", High: "10.5", Low: "9.9", Close: "10.3", Volume: "123456",
+	})
+	if bar.Day != "2025-09-08" || bar.Close != 10.3 || bar.Volume != 123456 {
+		t.Errorf("unexpected bar %+v", bar)
+	}
+	k := bar.ToKLineData()
+	if k.Close != "10.3" || k.Day != "2025-09-08" {
+		t.Errorf("unexpected kline %+v", k)
+	}
+	if NormalizeKLineCode(" GB_AAPL ") != "usaapl" {
+		t.Errorf("unexpected code %s", NormalizeKLineCode(" GB_AAPL "))
+	}
+}
+
+func TestKLineAdjusted(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "kline.db"))
+	db.Dao.AutoMigrate(&KLineBar{})
+	saveKLineBars([]KLineBar{
+		{Code: "hk00700", Period: KLinePeriodDay, Day: "2025-09-04", Close: 600},
+		{Code: "hk00700", Period: KLinePeriodDay, Day: "2025-09-05", Close: 605},
+	})
+
+	fetched := []KLineBar{
+		{Code: "hk00700", Period: KLinePeriodDay, Day: "2025-09-08", Close: 610},
+		{Code: "hk00700", Period: KLinePeriodDay, Day: "2025-09-05", Close: 605},
+	}
+	if kLineAdjusted("hk00700", KLinePeriodDay, fetched) {
+		t.Error("unchanged close should not trigger a resync")
+	}
+	//除息后前复权价格整体下调
+	fetched[1].Close = 601.5
+	if !kLineAdjusted("hk00700", KLinePeriodDay, fetched) {
+		t.Error("changed close of a stored bar should trigger a resync")
+	}
+	//只有一根K线时是盘中的最新K线,不做比较
+	if kLineAdjusted("hk00700", KLinePeriodDay, fetched[1:]) {
+		t.Error("single bar should not be compared")
+	}
+}
+
+func TestGetKLineFromStore(t *testing.T) {
+	db.Init("../../data/stock.db")
+	db.Dao.AutoMigrate(&KLineBar{})
+	K := NewKLineStoreApi().GetKLine("sh600000", KLinePeriodDay, 30)
+	t.Logf("%d %+v", len(*K), K)
+}
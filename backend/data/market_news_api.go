@@ -74,8 +74,9 @@ func (m MarketNewsApi) TelegraphList(crawlTimeOut int64) *[]models.Telegraph {
 			if cnt > 0 {
 				continue
 			}
-			telegraphs = append(telegraphs, telegraph)
 			db.Dao.Model(&models.Telegraph{}).Create(&telegraph)
+			NewNewsEntityApi().LinkTelegraph(&telegraph)
+			telegraphs = append(telegraphs, telegraph)
 			logger.SugaredLogger.Debugf("telegraph: %+v", &telegraph)
 			if news["subjects"] == nil {
 				continue
@@ -149,6 +150,7 @@ func (m MarketNewsApi) GetNewTelegraph(crawlTimeOut int64) *[]models.Telegraph {
 			db.Dao.Model(telegraph).Where("time=? and content=?", telegraph.Time, telegraph.Content).Count(&cnt)
 			if cnt == 0 {
 				db.Dao.Create(&telegraph)
+				NewNewsEntityApi().LinkTelegraph(&telegraph)
 				telegraphs = append(telegraphs, telegraph)
 				for _, tag := range telegraph.SubjectTags {
 					tagInfo := &models.Tags{}
@@ -171,7 +173,7 @@ func (m MarketNewsApi) GetNewsList(source string, limit int) *[]*models.Telegrap
 	if source != "" {
 		db.Dao.Model(news).Preload("TelegraphTags").Where("source=?", source).Order("data_time desc,time desc").Limit(limit).Find(news)
 	} else {
-		db.Dao.Model(news).Preload("TelegraphTags").Order("data_time desc,time desc").Limit(limit).Find(news)
+		db.Dao.Model(news).Preload("TelegraphTags").Where("cluster_id = 0").Order("data_time desc,time desc").Limit(limit).Find(news)
 	}
 	for _, item := range *news {
 		tags := &[]models.Tags{}
@@ -184,6 +186,8 @@ func (m MarketNewsApi) GetNewsList(source string, limit int) *[]*models.Telegrap
 		item.SubjectTags = tagNames
 		logger.SugaredLogger.Infof("tagNames %v ，SubjectTags：%s", tagNames, item.SubjectTags)
 	}
+	fillStocksTags(*news)
+	fillAltSources(*news)
 	return news
 }
 func (m MarketNewsApi) GetNewsList2(source string, limit int) *[]*models.Telegraph {
@@ -192,7 +196,7 @@ func (m MarketNewsApi) GetNewsList2(source string, limit int) *[]*models.Telegra
 	if source != "" {
 		db.Dao.Model(news).Preload("TelegraphTags").Where("source=?", source).Order("data_time desc,is_red desc").Limit(limit).Find(news)
 	} else {
-		db.Dao.Model(news).Preload("TelegraphTags").Order("data_time desc,is_red desc").Limit(limit).Find(news)
+		db.Dao.Model(news).Preload("TelegraphTags").Where("cluster_id = 0").Order("data_time desc,is_red desc").Limit(limit).Find(news)
 	}
 	for _, item := range *news {
 		tags := &[]models.Tags{}
@@ -205,6 +209,8 @@ func (m MarketNewsApi) GetNewsList2(source string, limit int) *[]*models.Telegra
 		item.SubjectTags = tagNames
 		logger.SugaredLogger.Infof("tagNames %v ，SubjectTags：%s", tagNames, item.SubjectTags)
 	}
+	fillStocksTags(*news)
+	fillAltSources(*news)
 	return news
 }
 
@@ -213,7 +219,7 @@ func (m MarketNewsApi) GetTelegraphList(source string) *[]*models.Telegraph {
 	if source != "" {
 		db.Dao.Model(news).Preload("TelegraphTags").Where("source=?", source).Order("data_time desc,time desc").Limit(50).Find(news)
 	} else {
-		db.Dao.Model(news).Preload("TelegraphTags").Order("data_time desc,time desc").Limit(50).Find(news)
+		db.Dao.Model(news).Preload("TelegraphTags").Where("cluster_id = 0").Order("data_time desc,time desc").Limit(50).Find(news)
 	}
 	for _, item := range *news {
 		tags := &[]models.Tags{}
@@ -226,6 +232,8 @@ func (m MarketNewsApi) GetTelegraphList(source string) *[]*models.Telegraph {
 		item.SubjectTags = tagNames
 		logger.SugaredLogger.Infof("tagNames %v ，SubjectTags：%s", tagNames, item.SubjectTags)
 	}
+	fillStocksTags(*news)
+	fillAltSources(*news)
 	return news
 }
 func (m MarketNewsApi) GetTelegraphListWithPaging(source string, page, pageSize int) *[]*models.Telegraph {
@@ -236,7 +244,7 @@ func (m MarketNewsApi) GetTelegraphListWithPaging(source string, page, pageSize
 	if source != "" {
 		db.Dao.Model(news).Preload("TelegraphTags").Where("source=?", source).Order("data_time desc,time desc").Limit(pageSize).Offset(offset).Find(news)
 	} else {
-		db.Dao.Model(news).Preload("TelegraphTags").Order("data_time desc,time desc").Limit(pageSize).Offset(offset).Find(news)
+		db.Dao.Model(news).Preload("TelegraphTags").Where("cluster_id = 0").Order("data_time desc,time desc").Limit(pageSize).Offset(offset).Find(news)
 	}
 	for _, item := range *news {
 		tags := &[]models.Tags{}
@@ -249,6 +257,8 @@ func (m MarketNewsApi) GetTelegraphListWithPaging(source string, page, pageSize
 		item.SubjectTags = tagNames
 		logger.SugaredLogger.Infof("tagNames %v ，SubjectTags：%s", tagNames, item.SubjectTags)
 	}
+	fillStocksTags(*news)
+	fillAltSources(*news)
 	return news
 }
 
@@ -323,6 +333,7 @@ func (m MarketNewsApi) GetSinaNews(crawlTimeOut uint) *[]models.Telegraph {
 				}
 				if cnt == 0 {
 					db.Dao.Create(&telegraph)
+					NewNewsEntityApi().LinkTelegraph(&telegraph)
 					telegraphs = append(telegraphs, telegraph)
 					for _, tag := range telegraph.SubjectTags {
 						tagInfo := &models.Tags{}
@@ -714,14 +725,14 @@ func (m MarketNewsApi) TradingViewNews() *[]models.Telegraph {
 		detail := NewMarketNewsApi().TradingViewNewsDetail(a.Id)
 		dataTime := time.Unix(int64(a.Published), 0).Local()
 		description := ""
-		sentimentResult := ""
 		if detail != nil {
 			description = detail.ShortDescription
-			sentimentResult = AnalyzeSentiment(description).Description
 		}
 		if a.Title == "" {
 			continue
 		}
+		//外媒多为英文,标题和摘要一起判断情感
+		sentimentResult := AnalyzeSentiment(a.Title + " " + description).Description
 		telegraph := &models.Telegraph{
 			Title:           a.Title,
 			Content:         description,
@@ -742,6 +753,7 @@ func (m MarketNewsApi) TradingViewNews() *[]models.Telegraph {
 			continue
 		}
 		db.Dao.Model(&models.Telegraph{}).Where("time=? and title=? and source=?", telegraph.Time, telegraph.Title, "外媒").FirstOrCreate(&telegraph)
+		NewNewsEntityApi().LinkTelegraph(telegraph)
 		*news = append(*news, *telegraph)
 	}
 	return news
@@ -1135,10 +1147,11 @@ func (m MarketNewsApi) CailianpressWeb(searchWords string) *models.CailianpressW
 
 func (m MarketNewsApi) GetNews24HoursList(source string, limit int) *[]*models.Telegraph {
 	news := &[]*models.Telegraph{}
+	//与其他资讯列表一致,按来源查看时保留该来源的重复报道,全部来源时只显示首条报道
 	if source != "" {
 		db.Dao.Model(news).Preload("TelegraphTags").Where("source=? and created_at>?", source, time.Now().Add(-24*time.Hour)).Order("data_time desc,is_red desc").Limit(limit).Find(news)
 	} else {
-		db.Dao.Model(news).Preload("TelegraphTags").Where("created_at>?", time.Now().Add(-24*time.Hour)).Order("data_time desc,is_red desc").Limit(limit).Find(news)
+		db.Dao.Model(news).Preload("TelegraphTags").Where("cluster_id = 0 and created_at>?", time.Now().Add(-24*time.Hour)).Order("data_time desc,is_red desc").Limit(limit).Find(news)
 	}
 	// 内容去重
 	uniqueNews := make([]*models.Telegraph, 0)
@@ -1160,5 +1173,6 @@ func (m MarketNewsApi) GetNews24HoursList(source string, limit int) *[]*models.T
 			uniqueNews = append(uniqueNews, item)
 		}
 	}
+	fillAltSources(uniqueNews)
 	return &uniqueNews
 }
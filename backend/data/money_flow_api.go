@@ -0,0 +1,192 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/go-resty/resty/v2"
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/9/30 9:30
+// @Desc 个股每日资金流向存储:主力、超大单、大单、中单、小单(散户)净流入,按最后一个交易日增量同步
+// -----------------------------------------------------------------------------------
+
+const (
+	defaultMoneyFlowDays = 120
+	//同一只股票两次同步的最小间隔
+	moneyFlowSyncInterval = time.Minute
+)
+
+// MoneyFlowDaily 个股每日资金流向,金额单位元,(code, day) 唯一
+type MoneyFlowDaily struct {
+	gorm.Model
+	Code          string  `json:"code" gorm:"uniqueIndex:idx_money_flow_daily,priority:1"`
+	Day           string  `json:"day" gorm:"uniqueIndex:idx_money_flow_daily,priority:2"`
+	Close         float64 `json:"close"`
+	ChangePercent float64 `json:"changePercent"`
+	MainNet       float64 `json:"mainNet"`       //主力净流入(超大单+大单)
+	MainNetRatio  float64 `json:"mainNetRatio"`  //主力净占比%
+	SuperLargeNet float64 `json:"superLargeNet"` //超大单净流入
+	LargeNet      float64 `json:"largeNet"`      //大单净流入
+	MediumNet     float64 `json:"mediumNet"`     //中单净流入
+	SmallNet      float64 `json:"smallNet"`      //小单(散户)净流入
+}
+
+func (MoneyFlowDaily) TableName() string {
+	return "money_flow_daily"
+}
+
+var moneyFlowLastSync sync.Map
+
+type MoneyFlowApi struct {
+}
+
+func NewMoneyFlowApi() *MoneyFlowApi {
+	return &MoneyFlowApi{}
+}
+
+// GetMoneyFlow 读取本地资金流向,本地数据过期时先增量同步;网络不可用时返回已有的本地数据
+func (receiver MoneyFlowApi) GetMoneyFlow(stockCode string, days int) *[]MoneyFlowDaily {
+	if days <= 0 {
+		days = defaultMoneyFlowDays
+	}
+	code := NormalizeKLineCode(stockCode)
+	if v, ok := moneyFlowLastSync.Load(code); !ok || time.Since(v.(time.Time)) >= moneyFlowSyncInterval {
+		receiver.SyncMoneyFlow(code)
+		moneyFlowLastSync.Store(code, time.Now())
+	}
+	return receiver.QueryMoneyFlow(code, days)
+}
+
+// QueryMoneyFlow 只读取本地资金流向数据,按日期升序返回最近 days 条
+func (receiver MoneyFlowApi) QueryMoneyFlow(stockCode string, days int) *[]MoneyFlowDaily {
+	var rows []MoneyFlowDaily
+	db.Dao.Model(&MoneyFlowDaily{}).
+		Where("code = ?", NormalizeKLineCode(stockCode)).
+		Order("day desc").Limit(days).Find(&rows)
+	res := make([]MoneyFlowDaily, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		res = append(res, rows[i])
+	}
+	return &res
+}
+
+// SyncMoneyFlow 从最后一条已保存的记录开始增量同步,没有记录时回补全部可获取的历史,返回写入的条数
+func (receiver MoneyFlowApi) SyncMoneyFlow(stockCode string) (int, error) {
+	code := NormalizeKLineCode(stockCode)
+	secid := moneyFlowSecid(code)
+	if secid == "" {
+		return 0, nil
+	}
+	last := MoneyFlowDaily{}
+	db.Dao.Model(&MoneyFlowDaily{}).Where("code = ?", code).Order("day desc").Limit(1).Find(&last)
+	limit := int64(0) //0 表示全部
+	if last.Day != "" {
+		limit = missingKLineCount(last.Day, KLinePeriodDay, time.Now())
+	}
+	rows, err := fetchMoneyFlow(code, secid, limit)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	//当日数据在盘中会变化,冲突时更新
+	err = db.Dao.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "code"}, {Name: "day"}},
+		DoUpdates: clause.AssignmentColumns([]string{"close", "change_percent", "main_net", "main_net_ratio",
+			"super_large_net", "large_net", "medium_net", "small_net", "updated_at"}),
+	}).CreateInBatches(&rows, 200).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("save money flow error:%s", err.Error())
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// SyncFollowedMoneyFlow 同步所有关注的A股资金流向,返回同步失败的股票及原因
+func (receiver MoneyFlowApi) SyncFollowedMoneyFlow() error {
+	var follows []FollowedStock
+	db.Dao.Model(&FollowedStock{}).Find(&follows)
+	var errs []error
+	for _, follow := range follows {
+		if moneyFlowSecid(NormalizeKLineCode(follow.StockCode)) == "" {
+			continue
+		}
+		n, err := receiver.SyncMoneyFlow(follow.StockCode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s:%w", follow.StockCode, follow.Name, err))
+			continue
+		}
+		logger.SugaredLogger.Infof("SyncMoneyFlow %s %s rows:%d", follow.StockCode, follow.Name, n)
+	}
+	return errors.Join(errs...)
+}
+
+// fetchMoneyFlow 东方财富个股日资金流向
+func fetchMoneyFlow(code, secid string, limit int64) ([]MoneyFlowDaily, error) {
+	url := fmt.Sprintf("https://push2his.eastmoney.com/api/qt/stock/fflow/daykline/get?lmt=%d&klt=101&secid=%s&fields1=f1,f2,f3,f7&fields2=f51,f52,f53,f54,f55,f56,f57,f58,f59,f60,f61,f62,f63", limit, secid)
+	resp, err := resty.New().SetTimeout(time.Duration(GetSettingConfig().CrawlTimeOut)*time.Second).R().
+		SetHeader("Referer", "https://data.eastmoney.com/zjlx/").
+		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:140.0) Gecko/20100101 Firefox/140.0").
+		Get(url)
+	if err != nil {
+		logger.SugaredLogger.Errorf("fetch money flow error:%s", err.Error())
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("获取资金流向失败:%s", resp.Status())
+	}
+	rows := make([]MoneyFlowDaily, 0)
+	for _, line := range gjson.GetBytes(resp.Body(), "data.klines").Array() {
+		if row, ok := parseMoneyFlowKLine(code, line.String()); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// parseMoneyFlowKLine 日期,主力净流入,小单净流入,中单净流入,大单净流入,超大单净流入,主力净占比,小单净占比,中单净占比,大单净占比,超大单净占比,收盘价,涨跌幅
+func parseMoneyFlowKLine(code, line string) (MoneyFlowDaily, bool) {
+	fields := strings.Split(line, ",")
+	if len(fields) < 13 || fields[0] == "" {
+		return MoneyFlowDaily{}, false
+	}
+	values := make([]float64, len(fields))
+	for i := 1; i < len(fields); i++ {
+		values[i], _ = convertor.ToFloat(fields[i])
+	}
+	return MoneyFlowDaily{
+		Code:          code,
+		Day:           fields[0],
+		MainNet:       values[1],
+		SmallNet:      values[2],
+		MediumNet:     values[3],
+		LargeNet:      values[4],
+		SuperLargeNet: values[5],
+		MainNetRatio:  values[6],
+		Close:         values[11],
+		ChangePercent: values[12],
+	}, true
+}
+
+// moneyFlowSecid 东方财富证券代码,只支持沪深北A股
+func moneyFlowSecid(code string) string {
+	switch {
+	case strings.HasPrefix(code, "sh"):
+		return "1." + code[2:]
+	case strings.HasPrefix(code, "sz"), strings.HasPrefix(code, "bj"):
+		return "0." + code[2:]
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package data
+
+import (
+	"testing"
+)
+
+// @Author spark
+// @Date 2025/9/30 14:20
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestParseMoneyFlowKLine(t *testing.T) {
+	row, ok := parseMoneyFlowKLine("sh600000", "2025-09-29,-12345678.0,8000000.0,4345678.0,-2345678.0,-10000000.0,-5.12,3.32,1.80,-0.97,-4.15,13.52,-1.24")
+	if !ok {
+		t.Fatal("line should be parsed")
+	}
+	if row.Day != "2025-09-29" || row.MainNet != -12345678 || row.SmallNet != 8000000 || row.SuperLargeNet != -10000000 {
+		t.Errorf("unexpected row %+v", row)
+	}
+	if row.MainNetRatio != -5.12 || row.Close != 13.52 || row.ChangePercent != -1.24 {
+		t.Errorf("unexpected row %+v", row)
+	}
+	if _, ok := parseMoneyFlowKLine("sh600000", "2025-09-29,1,2"); ok {
+		t.Errorf("short line should be rejected")
+	}
+}
+
+func TestMoneyFlowSecid(t *testing.T) {
+	cases := map[string]string{
+		"sh600000": "1.600000",
+		"sz000001": "0.000001",
+		"bj830799": "0.830799",
+		"hk00700":  "",
+		"usaapl":   "",
+	}
+	for code, want := range cases {
+		if got := moneyFlowSecid(code); got != want {
+			t.Errorf("%s: got %s want %s", code, got, want)
+		}
+	}
+}
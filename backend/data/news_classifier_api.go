@@ -0,0 +1,290 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/models"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/cryptor"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/go-resty/resty/v2"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/10/17 9:30
+// @Desc 资讯情绪模型分类:按批次调用设置中指定的模型输出严格的 JSON 结果,按模型和内容哈希缓存;未配置模型或调用失败时使用情感词典
+// -----------------------------------------------------------------------------------
+
+const (
+	SentimentLabelPositive = "positive"
+	SentimentLabelNegative = "negative"
+	SentimentLabelNeutral  = "neutral"
+
+	NewsClassifiedByModel   = "model"
+	NewsClassifiedByLexicon = "lexicon"
+
+	newsClassifyBatchSize = 20
+	//单条资讯提交给模型的最大字数
+	newsClassifyMaxRunes = 500
+)
+
+const newsClassifyPrompt = `你是金融资讯情绪分类器。判断每条资讯对相关股票或市场的影响,只输出 JSON,不要输出其他内容。
+输出格式:{"results":[{"id":1,"label":"positive","confidence":0.9,"tickers":["sh600000"]}]}
+- id: 资讯编号,每条资讯输出一个结果
+- label: positive(利好)、negative(利空)、neutral(中性)之一
+- confidence: 0 到 1 之间的置信度
+- tickers: 受影响的股票代码,A股如 sh600000、sz000001,港股如 hk00700,美股如 gb_aapl,没有则为空数组`
+
+// NewsSentimentCache 模型分类结果,按模型名称和资讯内容的哈希缓存
+type NewsSentimentCache struct {
+	gorm.Model
+	Hash       string  `json:"hash" gorm:"uniqueIndex"`
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+	Tickers    string  `json:"tickers"` //逗号分隔
+	ModelName  string  `json:"modelName"`
+}
+
+func (NewsSentimentCache) TableName() string {
+	return "news_sentiment_cache"
+}
+
+// NewsClassification 一条资讯的情绪分类结果
+type NewsClassification struct {
+	TelegraphId uint     `json:"telegraphId"`
+	Label       string   `json:"label"`
+	Confidence  float64  `json:"confidence"`
+	Tickers     []string `json:"tickers"`
+	By          string   `json:"by"` //model 模型 lexicon 情感词典
+}
+
+// Description 与 Telegraph.SentimentResult 一致的中文描述
+func (n NewsClassification) Description() string {
+	switch n.Label {
+	case SentimentLabelPositive:
+		return GetSentimentDescription(Positive)
+	case SentimentLabelNegative:
+		return GetSentimentDescription(Negative)
+	}
+	return GetSentimentDescription(Neutral)
+}
+
+type NewsClassifierApi struct {
+	aiConfig *AIConfig
+}
+
+// NewNewsClassifierApi 使用设置中的情绪分类模型,未配置时只使用情感词典
+func NewNewsClassifierApi() *NewsClassifierApi {
+	config := GetSettingConfig()
+	aiConfig, _ := lo.Find(config.AiConfigs, func(item *AIConfig) bool {
+		return config.SentimentAiConfigId > 0 && item.ID == uint(config.SentimentAiConfigId)
+	})
+	return &NewsClassifierApi{aiConfig: aiConfig}
+}
+
+// Enabled 是否配置了可用的分类模型
+func (c NewsClassifierApi) Enabled() bool {
+	return c.aiConfig != nil && c.aiConfig.BaseUrl != "" && c.aiConfig.ModelName != ""
+}
+
+// Classify 分类资讯情绪,结果与输入顺序一致;先查当前模型的缓存,未命中的按批次调用模型,仍未得到结果的使用情感词典
+func (c NewsClassifierApi) Classify(telegraphs []models.Telegraph) []NewsClassification {
+	res := make([]NewsClassification, len(telegraphs))
+	done := make([]bool, len(telegraphs))
+	var hashes []string
+
+	if c.Enabled() {
+		hashes = lo.Map(telegraphs, func(item models.Telegraph, _ int) string {
+			return newsContentHash(c.aiConfig.ModelName, item)
+		})
+		var caches []NewsSentimentCache
+		db.Dao.Model(&NewsSentimentCache{}).Where("hash in ?", lo.Uniq(hashes)).Find(&caches)
+		cached := lo.KeyBy(caches, func(item NewsSentimentCache) string {
+			return item.Hash
+		})
+		for i, hash := range hashes {
+			if cache, ok := cached[hash]; ok {
+				res[i] = NewsClassification{Label: cache.Label, Confidence: cache.Confidence, Tickers: splitTickers(cache.Tickers), By: NewsClassifiedByModel}
+				done[i] = true
+			}
+		}
+
+		misses := lo.Filter(lo.Range(len(telegraphs)), func(i int, _ int) bool {
+			return !done[i]
+		})
+		for _, batch := range lo.Chunk(misses, newsClassifyBatchSize) {
+			results, err := c.classifyBatch(lo.Map(batch, func(i int, _ int) models.Telegraph {
+				return telegraphs[i]
+			}))
+			if err != nil {
+				logger.SugaredLogger.Errorf("classify news error:%s", err.Error())
+				break
+			}
+			var saves []NewsSentimentCache
+			for j, i := range batch {
+				result, ok := results[j+1]
+				if !ok {
+					continue
+				}
+				res[i], done[i] = result, true
+				saves = append(saves, NewsSentimentCache{Hash: hashes[i], Label: result.Label, Confidence: result.Confidence, Tickers: strings.Join(result.Tickers, ","), ModelName: c.aiConfig.ModelName})
+			}
+			if len(saves) > 0 {
+				db.Dao.Clauses(clause.OnConflict{DoNothing: true}).Create(&saves)
+			}
+		}
+	}
+
+	for i, telegraph := range telegraphs {
+		if !done[i] {
+			res[i] = lexiconClassification(telegraph)
+		}
+		res[i].TelegraphId = telegraph.ID
+	}
+	return res
+}
+
+// ClassifyRecent 使用模型重新分类 since 之后的资讯并更新 SentimentResult,未配置模型时不处理,返回更新的数量
+func (c NewsClassifierApi) ClassifyRecent(since time.Time) int {
+	if !c.Enabled() {
+		return 0
+	}
+	var telegraphs []models.Telegraph
+	db.Dao.Model(&models.Telegraph{}).
+		Where("cluster_id = 0 and data_time >= ?", since).
+		Order("data_time desc").Limit(200).Find(&telegraphs)
+	updated := 0
+	for i, result := range c.Classify(telegraphs) {
+		if result.By != NewsClassifiedByModel || telegraphs[i].SentimentResult == result.Description() {
+			continue
+		}
+		db.Dao.Model(&models.Telegraph{}).Where("id = ?", telegraphs[i].ID).Update("sentiment_result", result.Description())
+		updated++
+	}
+	return updated
+}
+
+func (c NewsClassifierApi) classifyBatch(telegraphs []models.Telegraph) (map[int]NewsClassification, error) {
+	var sb strings.Builder
+	for i, telegraph := range telegraphs {
+		text := []rune(strings.TrimSpace(telegraph.Title + " " + telegraph.Content))
+		if len(text) > newsClassifyMaxRunes {
+			text = text[:newsClassifyMaxRunes]
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, string(text)))
+	}
+
+	client := resty.New()
+	client.SetBaseURL(strutil.Trim(c.aiConfig.BaseUrl))
+	client.SetHeader("Authorization", "Bearer "+c.aiConfig.ApiKey)
+	client.SetHeader("Content-Type", "application/json")
+	timeout := c.aiConfig.TimeOut
+	if timeout <= 0 {
+		timeout = 60
+	}
+	client.SetTimeout(time.Duration(timeout) * time.Second)
+	config := GetSettingConfig()
+	if config.HttpProxyEnabled && config.HttpProxy != "" {
+		client.SetProxy(config.HttpProxy)
+	}
+	aiResponse := &AiResponse{}
+	resp, err := client.R().
+		SetBody(map[string]any{
+			"model":           c.aiConfig.ModelName,
+			"temperature":     0,
+			"stream":          false,
+			"response_format": map[string]any{"type": "json_object"},
+			"messages": []map[string]any{
+				{"role": "system", "content": newsClassifyPrompt},
+				{"role": "user", "content": sb.String()},
+			},
+		}).
+		Post("/chat/completions")
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("status %d:%s", resp.StatusCode(), resp.String())
+	}
+	if err = json.Unmarshal(resp.Body(), aiResponse); err != nil {
+		return nil, err
+	}
+	if len(aiResponse.Choices) == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+	return parseNewsClassification(aiResponse.Choices[0].Message.Content, len(telegraphs))
+}
+
+// parseNewsClassification 解析模型输出,忽略编号越界、标签不合法的结果
+func parseNewsClassification(content string, n int) (map[int]NewsClassification, error) {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```"), "```")
+	var output struct {
+		Results []struct {
+			Id         int      `json:"id"`
+			Label      string   `json:"label"`
+			Confidence float64  `json:"confidence"`
+			Tickers    []string `json:"tickers"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &output); err != nil {
+		return nil, err
+	}
+	res := map[int]NewsClassification{}
+	for _, item := range output.Results {
+		label := strings.ToLower(strings.TrimSpace(item.Label))
+		if item.Id < 1 || item.Id > n || !lo.Contains([]string{SentimentLabelPositive, SentimentLabelNegative, SentimentLabelNeutral}, label) {
+			continue
+		}
+		tickers := lo.Uniq(lo.FilterMap(item.Tickers, func(ticker string, _ int) (string, bool) {
+			ticker = NewsStockCode(ticker)
+			return ticker, ticker != ""
+		}))
+		res[item.Id] = NewsClassification{
+			Label:      label,
+			Confidence: math.Max(0, math.Min(1, item.Confidence)),
+			Tickers:    tickers,
+			By:         NewsClassifiedByModel,
+		}
+	}
+	return res, nil
+}
+
+// lexiconClassification 情感词典分类,置信度按得分估算
+func lexiconClassification(telegraph models.Telegraph) NewsClassification {
+	result := AnalyzeSentiment(telegraph.Title + " " + telegraph.Content)
+	label := SentimentLabelNeutral
+	switch result.Category {
+	case Positive:
+		label = SentimentLabelPositive
+	case Negative:
+		label = SentimentLabelNegative
+	}
+	return NewsClassification{
+		Label:      label,
+		Confidence: math.Min(1, math.Abs(result.Score)/5),
+		Tickers:    []string{},
+		By:         NewsClassifiedByLexicon,
+	}
+}
+
+// newsContentHash 缓存键,不同模型的分类结果分别缓存
+func newsContentHash(modelName string, telegraph models.Telegraph) string {
+	return cryptor.Md5String(modelName + "\n" + strings.TrimSpace(telegraph.Title) + "\n" + strings.TrimSpace(telegraph.Content))
+}
+
+func splitTickers(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
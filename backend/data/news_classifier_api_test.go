@@ -0,0 +1,91 @@
+package data
+
+import (
+	"encoding/json"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// @Author spark
+// @Date 2025/10/17 14:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestParseNewsClassification(t *testing.T) {
+	content := "```json\n" + `{"results":[
+		{"id":1,"label":"Positive","confidence":1.5,"tickers":["SH600000","usAAPL","sh600000"]},
+		{"id":2,"label":"bullish","confidence":0.8},
+		{"id":9,"label":"negative","confidence":0.8}]}` + "\n```"
+	res, err := parseNewsClassification(content, 2)
+	if err != nil || len(res) != 1 {
+		t.Fatalf("unexpected result %v %+v", err, res)
+	}
+	if r := res[1]; r.Label != SentimentLabelPositive || r.Confidence != 1 || len(r.Tickers) != 2 || r.Tickers[1] != "gb_aapl" {
+		t.Errorf("unexpected classification %+v", r)
+	}
+	if _, err = parseNewsClassification("not json", 1); err == nil {
+		t.Errorf("invalid output should fail")
+	}
+}
+
+func TestNewsClassifier(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "classifier.db"))
+	db.Dao.AutoMigrate(&NewsSentimentCache{}, &Settings{}, &AIConfig{})
+
+	var calls atomic.Int32
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		content := `{"results":[{"id":1,"label":"negative","confidence":0.9,"tickers":["sh600000"]},{"id":2,"label":"neutral","confidence":0.6,"tickers":[]}]}`
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]any{"role": "assistant", "content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	telegraphs := []models.Telegraph{{Content: "浦发银行被立案调查"}, {Content: "央行开展逆回购操作"}}
+	classifier := NewsClassifierApi{aiConfig: &AIConfig{BaseUrl: server.URL, ModelName: "test", TimeOut: 5}}
+	res := classifier.Classify(telegraphs)
+	if res[0].Label != SentimentLabelNegative || res[0].By != NewsClassifiedByModel || res[0].Tickers[0] != "sh600000" || res[1].Label != SentimentLabelNeutral {
+		t.Fatalf("unexpected classification %+v", res)
+	}
+	if res[0].Description() != "看跌" {
+		t.Errorf("unexpected description %s", res[0].Description())
+	}
+
+	//命中缓存不再调用模型
+	fail.Store(true)
+	res = classifier.Classify(telegraphs)
+	if calls.Load() != 1 || res[0].Label != SentimentLabelNegative || res[0].By != NewsClassifiedByModel {
+		t.Errorf("cache not used, calls:%d %+v", calls.Load(), res)
+	}
+
+	//更换模型后不使用其他模型的缓存
+	other := NewsClassifierApi{aiConfig: &AIConfig{BaseUrl: server.URL, ModelName: "other", TimeOut: 5}}
+	res = other.Classify(telegraphs)
+	if calls.Load() != 2 || res[0].By != NewsClassifiedByLexicon {
+		t.Errorf("cache of another model should not be used, calls:%d %+v", calls.Load(), res)
+	}
+
+	//调用失败和未配置模型时使用情感词典
+	res = classifier.Classify([]models.Telegraph{{Content: "新的资讯"}})
+	if calls.Load() != 3 || res[0].By != NewsClassifiedByLexicon {
+		t.Errorf("should fall back to lexicon %+v", res)
+	}
+	res = NewsClassifierApi{}.Classify([]models.Telegraph{{Content: "另一条资讯"}})
+	if calls.Load() != 3 || res[0].By != NewsClassifiedByLexicon {
+		t.Errorf("should not call model without config %+v", res)
+	}
+	if NewNewsClassifierApi().Enabled() {
+		t.Errorf("classifier should be disabled without setting")
+	}
+}
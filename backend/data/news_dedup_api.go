@@ -0,0 +1,155 @@
+package data
+
+import (
+	"hash/fnv"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/models"
+	"math/bits"
+	"reflect"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/10/13 9:30
+// @Desc 跨来源相似资讯去重:按 gse 分词计算 SimHash,时间窗口内海明距离足够小的报道归为同一事件
+// -----------------------------------------------------------------------------------
+
+const (
+	//海明距离不超过该值视为同一事件
+	newsDupDistance = 6
+	//只在前后时间窗口内查找相似报道
+	newsDupWindow = 6 * time.Hour
+)
+
+type NewsDedupApi struct {
+}
+
+func NewNewsDedupApi() *NewsDedupApi {
+	return &NewsDedupApi{}
+}
+
+// InitNewsDedup 注册新增电报前的相似度检测回调
+func InitNewsDedup() {
+	if db.Dao.Callback().Create().Get("telegraph_dedup:cluster") != nil {
+		return
+	}
+	err := db.Dao.Callback().Create().Before("gorm:create").Register("telegraph_dedup:cluster", clusterTelegraphCallback)
+	if err != nil {
+		logger.SugaredLogger.Errorf("register telegraph_dedup callback error:%s", err.Error())
+	}
+}
+
+// Cluster 计算 SimHash 并查找时间窗口内的相似报道,找到时把 ClusterId 指向首条报道
+func (n NewsDedupApi) Cluster(tx *gorm.DB, telegraph *models.Telegraph) {
+	if telegraph.SimHash == 0 {
+		telegraph.SimHash = int64(simHash(newsWords(telegraph.Title + " " + telegraph.Content)))
+	}
+	if telegraph.ClusterId != 0 || telegraph.SimHash == 0 {
+		return
+	}
+	center := time.Now()
+	if telegraph.DataTime != nil {
+		center = *telegraph.DataTime
+	}
+	var candidates []models.Telegraph
+	tx.Model(&models.Telegraph{}).Select("id", "sim_hash").
+		Where("cluster_id = 0 and sim_hash != 0 and data_time between ? and ?", center.Add(-newsDupWindow), center.Add(newsDupWindow)).
+		Order("data_time desc").Limit(1000).Find(&candidates)
+	if id, ok := nearestTelegraph(uint64(telegraph.SimHash), candidates); ok {
+		telegraph.ClusterId = id
+	}
+}
+
+func clusterTelegraphCallback(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != (models.Telegraph{}).TableName() {
+		return
+	}
+	var telegraphs []*models.Telegraph
+	value := reflect.Indirect(tx.Statement.ReflectValue)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if item := reflect.Indirect(value.Index(i)); item.CanAddr() {
+				if telegraph, ok := item.Addr().Interface().(*models.Telegraph); ok {
+					telegraphs = append(telegraphs, telegraph)
+				}
+			}
+		}
+	case reflect.Struct:
+		if value.CanAddr() {
+			if telegraph, ok := value.Addr().Interface().(*models.Telegraph); ok {
+				telegraphs = append(telegraphs, telegraph)
+			}
+		}
+	}
+	session := tx.Session(&gorm.Session{NewDB: true})
+	for _, telegraph := range telegraphs {
+		NewNewsDedupApi().Cluster(session, telegraph)
+	}
+}
+
+// fillAltSources 填充同一事件其他报道的来源
+func fillAltSources(news []*models.Telegraph) {
+	if len(news) == 0 {
+		return
+	}
+	root := func(telegraph models.Telegraph) uint {
+		if telegraph.ClusterId != 0 {
+			return telegraph.ClusterId
+		}
+		return telegraph.ID
+	}
+	roots := lo.Uniq(lo.Map(news, func(item *models.Telegraph, _ int) uint {
+		return root(*item)
+	}))
+	var members []models.Telegraph
+	db.Dao.Model(&models.Telegraph{}).Select("id", "cluster_id", "source").
+		Where("id in ? or cluster_id in ?", roots, roots).Find(&members)
+	clusters := lo.GroupBy(members, root)
+	for _, item := range news {
+		item.AltSources = lo.Uniq(lo.FilterMap(clusters[root(*item)], func(member models.Telegraph, _ int) (string, bool) {
+			return member.Source, member.ID != item.ID && member.Source != "" && member.Source != item.Source
+		}))
+	}
+}
+
+// nearestTelegraph 海明距离最小且不超过阈值的报道
+func nearestTelegraph(hash uint64, candidates []models.Telegraph) (uint, bool) {
+	best, bestDistance := uint(0), newsDupDistance+1
+	for _, candidate := range candidates {
+		if d := bits.OnesCount64(hash ^ uint64(candidate.SimHash)); d < bestDistance {
+			best, bestDistance = candidate.ID, d
+		}
+	}
+	return best, best != 0
+}
+
+// simHash 64 位 SimHash,词的权重为字数
+func simHash(words []string) uint64 {
+	var vector [64]int
+	for _, word := range words {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(word))
+		sum := h.Sum64()
+		weight := utf8.RuneCountInString(word)
+		for i := 0; i < 64; i++ {
+			if sum>>i&1 == 1 {
+				vector[i] += weight
+			} else {
+				vector[i] -= weight
+			}
+		}
+	}
+	var hash uint64
+	for i, v := range vector {
+		if v > 0 {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
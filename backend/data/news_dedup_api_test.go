@@ -0,0 +1,89 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/models"
+	"math/bits"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/10/13 14:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestSimHash(t *testing.T) {
+	a := newsWords("央行宣布下调存款准备金率0.5个百分点,释放长期资金约1万亿元")
+	b := newsWords("央行宣布下调存款准备金率0.5个百分点,将释放长期资金约1万亿元")
+	c := newsWords("腾讯控股发布三季度财报,游戏业务收入同比增长")
+	if d := bits.OnesCount64(simHash(a) ^ simHash(b)); d > newsDupDistance {
+		t.Errorf("similar news distance too large:%d", d)
+	}
+	if d := bits.OnesCount64(simHash(a) ^ simHash(c)); d <= newsDupDistance {
+		t.Errorf("different news distance too small:%d", d)
+	}
+	if simHash(nil) != 0 {
+		t.Errorf("empty words should hash to 0")
+	}
+}
+
+func TestNearestTelegraph(t *testing.T) {
+	candidates := []models.Telegraph{
+		{SimHash: 0b1111},
+		{SimHash: 0b0001},
+	}
+	candidates[0].ID, candidates[1].ID = 1, 2
+	if id, ok := nearestTelegraph(0b0011, candidates); !ok || id != 2 {
+		t.Errorf("unexpected nearest %d %v", id, ok)
+	}
+	if _, ok := nearestTelegraph(0xffff_ffff_ffff_ff00, candidates); ok {
+		t.Errorf("distant hash should not match")
+	}
+}
+
+func TestClusterTelegraph(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "dedup.db"))
+	db.Dao.AutoMigrate(&models.Telegraph{}, &TelegraphStock{}, &models.Tags{}, &models.TelegraphTags{})
+	InitNewsDedup()
+
+	now := time.Now()
+	first := models.Telegraph{Content: "央行宣布下调存款准备金率0.5个百分点,释放长期资金约1万亿元", Source: "财联社电报", DataTime: &now}
+	db.Dao.Create(&first)
+	later := now.Add(10 * time.Minute)
+	dup := models.Telegraph{Content: "央行宣布下调存款准备金率0.5个百分点,将释放长期资金约1万亿元", Source: "新浪财经", DataTime: &later}
+	db.Dao.Create(&dup)
+	other := models.Telegraph{Content: "腾讯控股发布三季度财报,游戏业务收入同比增长", Source: "新浪财经", DataTime: &later}
+	db.Dao.Create(&other)
+	old := now.Add(-2 * newsDupWindow)
+	stale := models.Telegraph{Content: first.Content, Source: "外媒", DataTime: &old}
+	db.Dao.Create(&stale)
+
+	if first.ClusterId != 0 || dup.ClusterId != first.ID {
+		t.Fatalf("unexpected cluster first:%d dup:%d", first.ClusterId, dup.ClusterId)
+	}
+	if other.ClusterId != 0 || stale.ClusterId != 0 {
+		t.Errorf("unrelated news should not be clustered other:%d stale:%d", other.ClusterId, stale.ClusterId)
+	}
+
+	news := []*models.Telegraph{&first, &other}
+	fillAltSources(news)
+	if len(first.AltSources) != 1 || first.AltSources[0] != "新浪财经" {
+		t.Errorf("unexpected alt sources %v", first.AltSources)
+	}
+	if len(other.AltSources) != 0 {
+		t.Errorf("unexpected alt sources %v", other.AltSources)
+	}
+
+	//全部来源只显示首条报道,按来源查看时保留该来源的重复报道
+	if all := NewMarketNewsApi().GetNews24HoursList("", 10); len(*all) != 2 {
+		t.Errorf("duplicates should be hidden from all sources, got %d", len(*all))
+	}
+	if sina := NewMarketNewsApi().GetNews24HoursList("新浪财经", 10); len(*sina) != 2 {
+		t.Errorf("source list should keep its duplicates, got %d", len(*sina))
+	}
+	if sina := NewMarketNewsApi().GetNewsList("新浪财经", 10); len(*sina) != 2 {
+		t.Errorf("source list should keep its duplicates, got %d", len(*sina))
+	}
+}
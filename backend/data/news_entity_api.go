@@ -0,0 +1,230 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/models"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/10/10 9:30
+// @Desc 资讯实体链接:入库时按 gse 分词匹配 A股、港股、美股名称及板块名称,保存资讯与股票的关联
+// -----------------------------------------------------------------------------------
+
+const (
+	NewsEntityStock = "stock"
+	NewsEntityBoard = "board"
+
+	//补建关联的资讯时间范围
+	newsLinkBackfillDays = 90
+)
+
+// TelegraphStock 资讯提及的股票或板块,(telegraph_id, stock_code) 唯一
+type TelegraphStock struct {
+	gorm.Model
+	TelegraphId uint   `json:"telegraphId" gorm:"uniqueIndex:idx_telegraph_stock,priority:1"`
+	StockCode   string `json:"stockCode" gorm:"uniqueIndex:idx_telegraph_stock,priority:2;index"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"` //stock 个股 board 板块
+}
+
+func (TelegraphStock) TableName() string {
+	return "telegraph_stock"
+}
+
+type newsEntity struct {
+	Code string
+	Name string
+	Kind string
+}
+
+var (
+	newsEntitiesMu sync.RWMutex
+	newsEntities   map[string][]newsEntity
+)
+
+type NewsEntityApi struct {
+}
+
+func NewNewsEntityApi() *NewsEntityApi {
+	return &NewsEntityApi{}
+}
+
+// LoadNewsEntities 从股票基础信息加载名称到代码的映射,基础信息更新后可重新加载
+func LoadNewsEntities() {
+	entities := make(map[string][]newsEntity)
+	add := func(name, code, kind string) {
+		name, code = strutil.Trim(name), NewsStockCode(code)
+		//单字名称误匹配太多
+		if utf8.RuneCountInString(name) < 2 || code == "" {
+			return
+		}
+		entities[name] = lo.UniqBy(append(entities[name], newsEntity{Code: code, Name: name, Kind: kind}), func(item newsEntity) string {
+			return item.Code
+		})
+	}
+
+	var stocks []StockBasic
+	db.Dao.Model(&StockBasic{}).Select("ts_code", "name", "bk_name", "bk_code").Find(&stocks)
+	for _, stock := range stocks {
+		add(stock.Name, ConvertTushareCodeToStockCode(stock.TsCode), NewsEntityStock)
+		add(stock.BKName, stock.BKCode, NewsEntityBoard)
+	}
+	var hks []models.StockInfoHK
+	db.Dao.Model(&models.StockInfoHK{}).Select("code", "name", "bk_name", "bk_code").Find(&hks)
+	for _, stock := range hks {
+		add(stock.Name, ConvertTushareCodeToStockCode(stock.Code), NewsEntityStock)
+		add(stock.BKName, stock.BKCode, NewsEntityBoard)
+	}
+	var uss []models.StockInfoUS
+	db.Dao.Model(&models.StockInfoUS{}).Select("code", "name", "bk_name", "bk_code").Find(&uss)
+	for _, stock := range uss {
+		add(stock.Name, stock.Code, NewsEntityStock)
+		add(stock.BKName, stock.BKCode, NewsEntityBoard)
+	}
+
+	newsEntitiesMu.Lock()
+	newsEntities = entities
+	newsEntitiesMu.Unlock()
+	logger.SugaredLogger.Infof("加载资讯实体%d个", len(entities))
+}
+
+// LinkTelegraph 识别资讯提及的股票和板块并保存关联,同时填充 StocksTags
+func (n NewsEntityApi) LinkTelegraph(telegraph *models.Telegraph) {
+	if telegraph == nil || telegraph.ID == 0 {
+		return
+	}
+	newsEntitiesMu.RLock()
+	loaded := len(newsEntities) > 0
+	newsEntitiesMu.RUnlock()
+	if !loaded {
+		LoadNewsEntities()
+	}
+
+	words, _ := segCut(telegraph.Title + " " + telegraph.Content)
+	//来源页面自带的股票标签
+	words = append(words, telegraph.StocksTags...)
+
+	newsEntitiesMu.RLock()
+	matched := matchNewsEntities(words, newsEntities)
+	newsEntitiesMu.RUnlock()
+	if len(matched) == 0 {
+		return
+	}
+
+	links := lo.Map(matched, func(entity newsEntity, _ int) TelegraphStock {
+		return TelegraphStock{TelegraphId: telegraph.ID, StockCode: entity.Code, Name: entity.Name, Kind: entity.Kind}
+	})
+	err := db.Dao.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("save telegraph stock error:%s", err.Error())
+	}
+	telegraph.StocksTags = lo.Uniq(append(telegraph.StocksTags, stockNames(matched)...))
+}
+
+// BackfillLinks 实体链接上线前入库的资讯没有关联,关联表为空时为最近的资讯补建一次关联并重新汇总情绪,返回处理的资讯数量
+func (n NewsEntityApi) BackfillLinks() int {
+	var count int64
+	db.Dao.Model(&TelegraphStock{}).Count(&count)
+	if count > 0 {
+		return 0
+	}
+	since := time.Now().AddDate(0, 0, -newsLinkBackfillDays)
+	var telegraphs []*models.Telegraph
+	total := 0
+	err := db.Dao.Model(&models.Telegraph{}).Where("data_time >= ?", since).FindInBatches(&telegraphs, 500, func(tx *gorm.DB, batch int) error {
+		for _, telegraph := range telegraphs {
+			n.LinkTelegraph(telegraph)
+		}
+		total += len(telegraphs)
+		return nil
+	}).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("backfill telegraph stock error:%s", err.Error())
+	}
+	if total == 0 {
+		return 0
+	}
+	if _, err := NewNewsSentimentApi().Aggregate(since, time.Now()); err != nil {
+		logger.SugaredLogger.Errorf("aggregate news sentiment error:%s", err.Error())
+	}
+	logger.SugaredLogger.Infof("补建资讯关联%d条", total)
+	return total
+}
+
+// GetNewsByStockCodes 提及任一股票代码的资讯,按时间倒序
+func (n NewsEntityApi) GetNewsByStockCodes(codes []string, limit int) *[]*models.Telegraph {
+	news := &[]*models.Telegraph{}
+	if len(codes) == 0 {
+		return news
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	codes = lo.Map(codes, func(code string, _ int) string {
+		return NewsStockCode(code)
+	})
+	db.Dao.Model(&models.Telegraph{}).
+		Where("id in (?)", db.Dao.Model(&TelegraphStock{}).Select("telegraph_id").Where("stock_code in ?", codes)).
+		Order("data_time desc,time desc").Limit(limit).Find(news)
+	fillStocksTags(*news)
+	return news
+}
+
+// fillStocksTags 从关联表填充资讯的股票标签
+func fillStocksTags(news []*models.Telegraph) {
+	if len(news) == 0 {
+		return
+	}
+	var links []TelegraphStock
+	db.Dao.Model(&TelegraphStock{}).
+		Where("telegraph_id in ? and kind = ?", lo.Map(news, func(item *models.Telegraph, _ int) uint {
+			return item.ID
+		}), NewsEntityStock).Find(&links)
+	names := lo.GroupBy(links, func(link TelegraphStock) uint {
+		return link.TelegraphId
+	})
+	for _, item := range news {
+		for _, link := range names[item.ID] {
+			item.StocksTags = append(item.StocksTags, link.Name)
+		}
+		item.StocksTags = lo.Uniq(item.StocksTags)
+	}
+}
+
+// matchNewsEntities 分词结果中的股票和板块名称,按代码去重
+func matchNewsEntities(words []string, entities map[string][]newsEntity) []newsEntity {
+	var matched []newsEntity
+	for _, word := range words {
+		matched = append(matched, entities[strutil.Trim(word)]...)
+	}
+	return lo.UniqBy(matched, func(item newsEntity) string {
+		return item.Code
+	})
+}
+
+func stockNames(entities []newsEntity) []string {
+	return lo.FilterMap(entities, func(entity newsEntity, _ int) (string, bool) {
+		return entity.Name, entity.Kind == NewsEntityStock
+	})
+}
+
+// NewsStockCode 统一关联表中的股票代码格式,与关注列表一致,美股使用 gb_ 前缀(usAAPL、AAPL.US 均为 gb_aapl)
+func NewsStockCode(code string) string {
+	code = strings.ToLower(strutil.Trim(code))
+	if strings.HasPrefix(code, "us") {
+		code = strings.Replace(code, "us", "gb_", 1)
+	} else if strings.HasSuffix(code, ".us") {
+		code = "gb_" + strings.TrimSuffix(code, ".us")
+	}
+	return code
+}
@@ -0,0 +1,97 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/10/10 14:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestMatchNewsEntities(t *testing.T) {
+	entities := map[string][]newsEntity{
+		"中国银行": {{Code: "sh601988", Name: "中国银行", Kind: NewsEntityStock}, {Code: "hk03988", Name: "中国银行", Kind: NewsEntityStock}},
+		"银行":   {{Code: "BK0475", Name: "银行", Kind: NewsEntityBoard}},
+	}
+	matched := matchNewsEntities([]string{"中国银行", "发布", "公告", "银行", "中国银行"}, entities)
+	if len(matched) != 3 {
+		t.Fatalf("unexpected entities %+v", matched)
+	}
+	if names := stockNames(matched); len(names) != 2 || names[0] != "中国银行" {
+		t.Errorf("board should not be a stock tag %v", names)
+	}
+	if NewsStockCode("usAAPL") != "gb_aapl" || NewsStockCode("AAPL.US") != "gb_aapl" || NewsStockCode(" SH600000 ") != "sh600000" {
+		t.Errorf("unexpected code %s", NewsStockCode("usAAPL"))
+	}
+}
+
+func TestLinkTelegraph(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "entity.db"))
+	db.Dao.AutoMigrate(&models.Telegraph{}, &TelegraphStock{}, &StockBasic{}, &models.StockInfoHK{}, &models.StockInfoUS{})
+	db.Dao.Create(&StockBasic{TsCode: "600000.SH", Name: "浦发银行", BKName: "银行", BKCode: "BK0475"})
+	db.Dao.Create(&models.StockInfoHK{Code: "00700.HK", Name: "腾讯控股"})
+	db.Dao.Create(&models.StockInfoUS{Code: "AAPL.US", Name: "苹果"})
+	LoadNewsEntities()
+
+	now := time.Now()
+	telegraph := models.Telegraph{Content: "浦发银行发布公告", DataTime: &now, StocksTags: []string{"浦发银行", "银行"}}
+	db.Dao.Create(&telegraph)
+	NewNewsEntityApi().LinkTelegraph(&telegraph)
+	other := models.Telegraph{Content: "腾讯控股回购股份", DataTime: &now, StocksTags: []string{"腾讯控股"}}
+	db.Dao.Create(&other)
+	NewNewsEntityApi().LinkTelegraph(&other)
+	//重复关联不报错
+	NewNewsEntityApi().LinkTelegraph(&telegraph)
+
+	if len(telegraph.StocksTags) != 2 {
+		t.Errorf("unexpected stock tags %v", telegraph.StocksTags)
+	}
+	var count int64
+	db.Dao.Model(&TelegraphStock{}).Where("telegraph_id = ?", telegraph.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("stock and board should be linked, got %d", count)
+	}
+
+	news := NewNewsEntityApi().GetNewsByStockCodes([]string{"SH600000", "hk00700"}, 10)
+	if len(*news) != 2 {
+		t.Fatalf("unexpected news %d", len(*news))
+	}
+	for _, item := range *news {
+		if len(item.StocksTags) != 1 {
+			t.Errorf("stock tags should be filled %+v", item.StocksTags)
+		}
+	}
+	if news := NewNewsEntityApi().GetNewsByStockCodes([]string{"BK0475"}, 10); len(*news) != 1 {
+		t.Errorf("board link should be queryable %d", len(*news))
+	}
+
+	us := models.Telegraph{Content: "苹果发布新款手机", DataTime: &now, StocksTags: []string{"苹果"}}
+	db.Dao.Create(&us)
+	NewNewsEntityApi().LinkTelegraph(&us)
+	for _, code := range []string{"gb_aapl", "usAAPL"} {
+		if news := NewNewsEntityApi().GetNewsByStockCodes([]string{code}, 10); len(*news) != 1 {
+			t.Errorf("us stock should be linked as gb_aapl, %s got %d", code, len(*news))
+		}
+	}
+}
+
+func TestBackfillLinks(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "backfill.db"))
+	db.Dao.AutoMigrate(&models.Telegraph{}, &TelegraphStock{}, &NewsSentimentBucket{}, &StockBasic{}, &models.StockInfoHK{}, &models.StockInfoUS{})
+	now := time.Now()
+	old := now.AddDate(0, 0, -newsLinkBackfillDays-1)
+	db.Dao.Create(&models.Telegraph{Content: "浦发银行发布公告", DataTime: &now})
+	db.Dao.Create(&models.Telegraph{Content: "浦发银行发布年报", DataTime: &old})
+	if n := NewNewsEntityApi().BackfillLinks(); n != 1 {
+		t.Errorf("only recent news should be backfilled, got %d", n)
+	}
+	db.Dao.Create(&TelegraphStock{TelegraphId: 1, StockCode: "sh600000", Kind: NewsEntityStock})
+	if n := NewNewsEntityApi().BackfillLinks(); n != 0 {
+		t.Errorf("backfill should run only once, got %d", n)
+	}
+}
@@ -0,0 +1,285 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/models"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/10/8 9:30
+// @Desc 电报资讯全文检索:FTS5 虚拟表保存 gse 分词后的标题和内容,新增电报时自动建立索引
+// -----------------------------------------------------------------------------------
+
+const (
+	telegraphFtsTable     = "telegraph_fts"
+	newsSearchPageSize    = 20
+	newsSnippetWidth      = 80
+	newsHighlightStartTag = "<mark>"
+	newsHighlightEndTag   = "</mark>"
+)
+
+// NewsSearchHit 检索命中的电报及高亮摘要
+type NewsSearchHit struct {
+	Telegraph models.Telegraph `json:"telegraph"`
+	Snippet   string           `json:"snippet"`
+}
+
+// NewsSearchResult 分页检索结果
+type NewsSearchResult struct {
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"pageSize"`
+	Hits     []NewsSearchHit `json:"hits"`
+}
+
+type NewsSearchApi struct {
+}
+
+func NewNewsSearchApi() *NewsSearchApi {
+	return &NewsSearchApi{}
+}
+
+// InitNewsSearch 创建全文索引表,注册新增电报的索引回调,并在后台补齐历史电报的索引
+func InitNewsSearch() {
+	err := db.Dao.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS " + telegraphFtsTable + " USING fts5(content, tokenize = 'unicode61')").Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("create %s error:%s", telegraphFtsTable, err.Error())
+		return
+	}
+	if db.Dao.Callback().Create().Get("telegraph_fts:index") == nil {
+		err = db.Dao.Callback().Create().After("gorm:create").Register("telegraph_fts:index", indexTelegraphCallback)
+		if err != nil {
+			logger.SugaredLogger.Errorf("register telegraph_fts callback error:%s", err.Error())
+		}
+	}
+	go func() {
+		n := NewNewsSearchApi().IndexMissing()
+		logger.SugaredLogger.Infof("telegraph_fts indexed:%d", n)
+	}()
+}
+
+// IndexMissing 为尚未建立索引的电报建立索引,返回处理的数量
+func (n NewsSearchApi) IndexMissing() int {
+	total := 0
+	for {
+		var telegraphs []models.Telegraph
+		db.Dao.Model(&models.Telegraph{}).
+			Where("id not in (select rowid from " + telegraphFtsTable + ")").
+			Order("id").Limit(500).Find(&telegraphs)
+		if len(telegraphs) == 0 {
+			return total
+		}
+		for _, telegraph := range telegraphs {
+			if err := indexTelegraph(db.Dao, telegraph); err != nil {
+				logger.SugaredLogger.Errorf("index telegraph %d error:%s", telegraph.ID, err.Error())
+				return total
+			}
+		}
+		total += len(telegraphs)
+	}
+}
+
+// SearchNews 按关键词检索电报,可按来源和日期(yyyy-MM-dd,包含首尾)过滤,page 从 1 开始
+func (n NewsSearchApi) SearchNews(query, source, from, to string, page int) *NewsSearchResult {
+	if page < 1 {
+		page = 1
+	}
+	res := &NewsSearchResult{Page: page, PageSize: newsSearchPageSize, Hits: []NewsSearchHit{}}
+	//所有词都需要命中
+	terms := newsWords(query)
+	if len(terms) == 0 {
+		return res
+	}
+	tx := db.Dao.Model(&models.Telegraph{}).
+		Joins("join "+telegraphFtsTable+" on "+telegraphFtsTable+".rowid = telegraph_list.id").
+		Where(telegraphFtsTable+" match ?", buildFtsMatch(terms))
+	if source != "" {
+		tx = tx.Where("telegraph_list.source = ?", source)
+	}
+	if start, err := time.ParseInLocation(time.DateOnly, from, time.Local); err == nil {
+		tx = tx.Where("telegraph_list.data_time >= ?", start)
+	}
+	if end, err := time.ParseInLocation(time.DateOnly, to, time.Local); err == nil {
+		tx = tx.Where("telegraph_list.data_time < ?", end.AddDate(0, 0, 1))
+	}
+	tx = tx.Session(&gorm.Session{})
+	if err := tx.Count(&res.Total).Error; err != nil {
+		logger.SugaredLogger.Errorf("search news error:%s", err.Error())
+		return res
+	}
+
+	var telegraphs []models.Telegraph
+	tx.Select("telegraph_list.*").
+		Order(telegraphFtsTable + ".rank, telegraph_list.data_time desc").
+		Limit(newsSearchPageSize).Offset((page - 1) * newsSearchPageSize).
+		Find(&telegraphs)
+	for _, telegraph := range telegraphs {
+		text := telegraph.Content
+		if telegraph.Title != "" && !strings.Contains(text, telegraph.Title) {
+			text = telegraph.Title + " " + text
+		}
+		res.Hits = append(res.Hits, NewsSearchHit{
+			Telegraph: telegraph,
+			Snippet:   highlightSnippet(text, terms, newsSnippetWidth),
+		})
+	}
+	return res
+}
+
+func indexTelegraphCallback(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != (models.Telegraph{}).TableName() {
+		return
+	}
+	value := reflect.Indirect(tx.Statement.ReflectValue)
+	var telegraphs []models.Telegraph
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if telegraph, ok := reflect.Indirect(value.Index(i)).Interface().(models.Telegraph); ok {
+				telegraphs = append(telegraphs, telegraph)
+			}
+		}
+	case reflect.Struct:
+		if telegraph, ok := value.Interface().(models.Telegraph); ok {
+			telegraphs = append(telegraphs, telegraph)
+		}
+	}
+	session := tx.Session(&gorm.Session{NewDB: true})
+	for _, telegraph := range telegraphs {
+		if err := indexTelegraph(session, telegraph); err != nil {
+			logger.SugaredLogger.Errorf("index telegraph %d error:%s", telegraph.ID, err.Error())
+		}
+	}
+}
+
+func indexTelegraph(tx *gorm.DB, telegraph models.Telegraph) error {
+	if telegraph.ID == 0 {
+		return nil
+	}
+	tokens := newsTokens(telegraph.Title + " " + telegraph.Content)
+	return tx.Exec("insert or replace into "+telegraphFtsTable+"(rowid, content) values (?, ?)", telegraph.ID, strings.Join(tokens, " ")).Error
+}
+
+// newsTokens 索引分词,使用搜索模式切分出更细的词;分词词典未加载时退化为单字切分
+func newsTokens(text string) []string {
+	if words, ok := segCutSearch(text); ok {
+		return cleanNewsTokens(words)
+	}
+	return cleanNewsTokens(unigramTokens(text))
+}
+
+// newsWords 精确模式分词,用于检索词和相似度计算
+func newsWords(text string) []string {
+	if words, ok := segCut(text); ok {
+		return cleanNewsTokens(words)
+	}
+	return cleanNewsTokens(unigramTokens(text))
+}
+
+func cleanNewsTokens(words []string) []string {
+	return lo.Uniq(lo.FilterMap(words, func(word string, _ int) (string, bool) {
+		word = strings.ToLower(strings.TrimSpace(word))
+		return word, !isPunctuationOrSeparator(word)
+	}))
+}
+
+// unigramTokens 汉字按单字切分,字母和数字按连续片段切分
+func unigramTokens(text string) []string {
+	var tokens []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// buildFtsMatch 每个词作为短语,词之间为 AND
+func buildFtsMatch(terms []string) string {
+	return strings.Join(lo.Map(terms, func(term string, _ int) string {
+		return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}), " ")
+}
+
+// highlightSnippet 截取首个命中词附近 width 个字符的摘要,命中词用 <mark> 标记
+func highlightSnippet(text string, terms []string, width int) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+	termRunes := lo.Map(terms, func(term string, _ int) []rune {
+		return []rune(strings.ToLower(term))
+	})
+	//优先匹配较长的词
+	sort.SliceStable(termRunes, func(i, j int) bool {
+		return len(termRunes[i]) > len(termRunes[j])
+	})
+	matchAt := func(i int) int {
+		for _, term := range termRunes {
+			if len(term) > 0 && i+len(term) <= len(lower) && string(lower[i:i+len(term)]) == string(term) {
+				return len(term)
+			}
+		}
+		return 0
+	}
+
+	first := -1
+	for i := range lower {
+		if matchAt(i) > 0 {
+			first = i
+			break
+		}
+	}
+	start := 0
+	if first > width/4 {
+		start = first - width/4
+	}
+	end := start + width
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := matchAt(i); n > 0 {
+			sb.WriteString(newsHighlightStartTag)
+			sb.WriteString(string(runes[i : i+n]))
+			sb.WriteString(newsHighlightEndTag)
+			i += n
+			continue
+		}
+		sb.WriteRune(runes[i])
+		i++
+	}
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
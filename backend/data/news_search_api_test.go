@@ -0,0 +1,73 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/models"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/10/8 14:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestHighlightSnippet(t *testing.T) {
+	text := strings.Repeat("无关内容", 20) + "浦发银行发布公告,拟回购股份。" + strings.Repeat("其他", 30)
+	snippet := highlightSnippet(text, []string{"浦发银行", "回购"}, 30)
+	if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("snippet should be truncated: %s", snippet)
+	}
+	if !strings.Contains(snippet, "<mark>浦发银行</mark>发布公告,拟<mark>回购</mark>") {
+		t.Errorf("terms should be highlighted: %s", snippet)
+	}
+	if got := highlightSnippet("Apple 发布 iPhone", []string{"apple"}, 80); got != "<mark>Apple</mark> 发布 iPhone" {
+		t.Errorf("match should ignore case: %s", got)
+	}
+}
+
+func TestBuildFtsMatch(t *testing.T) {
+	if got := buildFtsMatch([]string{"浦发", `a"b`}); got != `"浦发" "a""b"` {
+		t.Errorf("unexpected match %s", got)
+	}
+	if got := unigramTokens("浦发银行AAPL 2025年"); strings.Join(got, " ") != "浦 发 银 行 AAPL 2025 年" {
+		t.Errorf("unexpected tokens %v", got)
+	}
+}
+
+func TestSearchNews(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "news.db"))
+	db.Dao.AutoMigrate(&models.Telegraph{})
+	InitNewsSearch()
+	//入库去重按内容查询,内容列保留普通索引
+	if !db.Dao.Migrator().HasIndex(&models.Telegraph{}, "idx_telegraph_list_content") {
+		t.Error("content index should be kept")
+	}
+
+	day := time.Date(2025, 10, 8, 10, 0, 0, 0, time.Local)
+	before := day.AddDate(0, 0, -10)
+	db.Dao.Create(&models.Telegraph{Content: "浦发银行发布公告拟回购股份", Source: "财联社电报", DataTime: &day})
+	db.Dao.Create(&[]models.Telegraph{
+		{Content: "央行开展逆回购操作", Source: "新浪财经", DataTime: &day},
+		{Content: "浦发银行召开业绩说明会", Source: "新浪财经", DataTime: &before},
+	})
+
+	res := NewNewsSearchApi().SearchNews("浦发银行", "", "", "", 1)
+	if res.Total != 2 || len(res.Hits) != 2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if !strings.Contains(res.Hits[0].Snippet, "<mark>") {
+		t.Errorf("snippet should be highlighted %s", res.Hits[0].Snippet)
+	}
+	if res := NewNewsSearchApi().SearchNews("浦发银行", "新浪财经", "", "", 1); res.Total != 1 {
+		t.Errorf("source filter failed %+v", res)
+	}
+	if res := NewNewsSearchApi().SearchNews("浦发银行", "", "2025-10-01", "2025-10-08", 1); res.Total != 1 || res.Hits[0].Telegraph.Source != "财联社电报" {
+		t.Errorf("date filter failed %+v", res)
+	}
+	if res := NewNewsSearchApi().SearchNews("回购", "", "", "", 2); res.Total != 2 || len(res.Hits) != 0 {
+		t.Errorf("paging failed %+v", res)
+	}
+}
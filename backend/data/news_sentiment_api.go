@@ -0,0 +1,241 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/models"
+	"sort"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/10/16 9:30
+// @Desc 个股和板块资讯情绪时间序列:按关联的股票和板块汇总每小时、每天的资讯数量和平均情绪得分,与K线对照发现情绪和走势的背离
+// -----------------------------------------------------------------------------------
+
+const (
+	SentimentPeriodHour = "hour"
+	SentimentPeriodDay  = "day"
+
+	sentimentHourLayout = "2006-01-02 15:00"
+	//定时任务每次重新汇总的时间范围
+	SentimentAggregateWindow = 48 * time.Hour
+	//平均得分(看涨1、看跌-1、中性0)超过该值才判断情绪方向
+	sentimentDivergenceScore = 0.3
+)
+
+// NewsSentimentBucket 股票或板块在一个时间段内的资讯情绪,(code, period, bucket) 唯一
+type NewsSentimentBucket struct {
+	gorm.Model
+	Code      string  `json:"code" gorm:"uniqueIndex:idx_news_sentiment_bucket,priority:1"`
+	Kind      string  `json:"kind"` //stock 个股 board 板块
+	Period    string  `json:"period" gorm:"uniqueIndex:idx_news_sentiment_bucket,priority:2"`
+	Bucket    string  `json:"bucket" gorm:"uniqueIndex:idx_news_sentiment_bucket,priority:3"` //hour: 2006-01-02 15:00 day: 2006-01-02
+	Count     int     `json:"count"`
+	Positive  int     `json:"positive"`
+	Negative  int     `json:"negative"`
+	Neutral   int     `json:"neutral"`
+	ScoreSum  float64 `json:"scoreSum"`
+	MeanScore float64 `json:"meanScore"`
+}
+
+func (NewsSentimentBucket) TableName() string {
+	return "news_sentiment_bucket"
+}
+
+// SentimentSeries 情绪序列及同期日K线,Divergence 为情绪与当日涨跌方向相反的日期
+type SentimentSeries struct {
+	Code       string                `json:"code"`
+	Period     string                `json:"period"`
+	Sentiment  []NewsSentimentBucket `json:"sentiment"`
+	Prices     []KLineData           `json:"prices"`
+	Divergence []string              `json:"divergence"`
+}
+
+// sentimentItem 一条资讯对一个股票或板块的情绪
+type sentimentItem struct {
+	Code     string
+	Kind     string
+	Time     time.Time
+	Score    float64
+	Category models.SentimentType
+}
+
+type NewsSentimentApi struct {
+}
+
+func NewNewsSentimentApi() *NewsSentimentApi {
+	return &NewsSentimentApi{}
+}
+
+// Aggregate 重新汇总 [from, to) 内资讯的情绪,from 对齐到当天零点保证日汇总完整,返回写入的汇总条数
+// 使用资讯入库时保存的情绪(模型分类后会更新),看涨计1分、看跌计-1分、中性计0分
+func (n NewsSentimentApi) Aggregate(from, to time.Time) (int, error) {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	var rows []struct {
+		StockCode       string
+		Kind            string
+		Id              uint
+		Title           string
+		Content         string
+		SentimentResult string
+		DataTime        *time.Time
+	}
+	//同一事件的其他来源报道不重复计入
+	err := db.Dao.Model(&TelegraphStock{}).
+		Select("telegraph_stock.stock_code, telegraph_stock.kind, telegraph_list.id, telegraph_list.title, telegraph_list.content, telegraph_list.sentiment_result, telegraph_list.data_time").
+		Joins("join telegraph_list on telegraph_list.id = telegraph_stock.telegraph_id and telegraph_list.deleted_at is null").
+		Where("telegraph_list.cluster_id = 0 and telegraph_list.data_time >= ? and telegraph_list.data_time < ?", from, to).
+		Find(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	categories := map[uint]models.SentimentType{}
+	items := make([]sentimentItem, 0, len(rows))
+	for _, row := range rows {
+		if row.DataTime == nil {
+			continue
+		}
+		category, ok := categories[row.Id]
+		if !ok {
+			category, ok = sentimentCategory(row.SentimentResult)
+			//早期资讯没有保存情绪时使用情感词典
+			if !ok {
+				category = AnalyzeSentiment(row.Title + " " + row.Content).Category
+			}
+			categories[row.Id] = category
+		}
+		items = append(items, sentimentItem{Code: row.StockCode, Kind: row.Kind, Time: row.DataTime.Local(), Score: sentimentCategoryScore(category), Category: category})
+	}
+
+	buckets := append(aggregateSentiment(items, SentimentPeriodHour), aggregateSentiment(items, SentimentPeriodDay)...)
+	err = db.Dao.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}, {Name: "period"}, {Name: "bucket"}},
+		DoUpdates: clause.AssignmentColumns([]string{"kind", "count", "positive", "negative", "neutral", "score_sum", "mean_score", "updated_at"}),
+	}).CreateInBatches(&buckets, 200).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("save news sentiment error:%s", err.Error())
+		return 0, err
+	}
+	return len(buckets), nil
+}
+
+// GetSentimentSeries 股票或板块最近 days 天的情绪序列,附带本地已保存的日K线
+func (n NewsSentimentApi) GetSentimentSeries(stockCode, period string, days int) *SentimentSeries {
+	if period != SentimentPeriodHour {
+		period = SentimentPeriodDay
+	}
+	if days <= 0 {
+		days = 30
+	}
+	code := NewsStockCode(stockCode)
+	series := &SentimentSeries{Code: code, Period: period, Sentiment: []NewsSentimentBucket{}, Prices: []KLineData{}, Divergence: []string{}}
+	since := time.Now().AddDate(0, 0, -days)
+	start := since.Format(time.DateOnly)
+	if period == SentimentPeriodHour {
+		start = since.Format(sentimentHourLayout)
+	}
+	db.Dao.Model(&NewsSentimentBucket{}).
+		Where("code = ? and period = ? and bucket >= ?", code, period, start).
+		Order("bucket").Find(&series.Sentiment)
+
+	prices := *NewKLineStoreApi().QueryKLine(stockCode, KLinePeriodDay, int64(days)+1)
+	series.Prices = lo.Filter(prices, func(item KLineData, _ int) bool {
+		return item.Day >= since.Format(time.DateOnly)
+	})
+	if period == SentimentPeriodDay {
+		series.Divergence = sentimentDivergence(series.Sentiment, prices)
+	}
+	return series
+}
+
+// sentimentCategory 由 Telegraph.SentimentResult 的中文描述还原情绪类别
+func sentimentCategory(description string) (models.SentimentType, bool) {
+	for _, category := range []models.SentimentType{Positive, Negative, Neutral} {
+		if description == GetSentimentDescription(category) {
+			return category, true
+		}
+	}
+	return Neutral, false
+}
+
+func sentimentCategoryScore(category models.SentimentType) float64 {
+	switch category {
+	case Positive:
+		return 1
+	case Negative:
+		return -1
+	}
+	return 0
+}
+
+// aggregateSentiment 按代码和时间段汇总情绪
+func aggregateSentiment(items []sentimentItem, period string) []NewsSentimentBucket {
+	layout := time.DateOnly
+	if period == SentimentPeriodHour {
+		layout = sentimentHourLayout
+	}
+	buckets := map[string]*NewsSentimentBucket{}
+	for _, item := range items {
+		key := item.Time.Format(layout)
+		bucket, ok := buckets[item.Code+"|"+key]
+		if !ok {
+			bucket = &NewsSentimentBucket{Code: item.Code, Kind: item.Kind, Period: period, Bucket: key}
+			buckets[item.Code+"|"+key] = bucket
+		}
+		bucket.Count++
+		bucket.ScoreSum += item.Score
+		switch item.Category {
+		case Positive:
+			bucket.Positive++
+		case Negative:
+			bucket.Negative++
+		default:
+			bucket.Neutral++
+		}
+	}
+	res := make([]NewsSentimentBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		bucket.MeanScore = bucket.ScoreSum / float64(bucket.Count)
+		res = append(res, *bucket)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Code != res[j].Code {
+			return res[i].Code < res[j].Code
+		}
+		return res[i].Bucket < res[j].Bucket
+	})
+	return res
+}
+
+// sentimentDivergence 平均情绪明显看涨而当日收跌,或明显看跌而当日收涨的日期
+func sentimentDivergence(sentiment []NewsSentimentBucket, prices []KLineData) []string {
+	change := map[string]float64{}
+	for i := 1; i < len(prices); i++ {
+		prev, _ := convertor.ToFloat(prices[i-1].Close)
+		closePrice, _ := convertor.ToFloat(prices[i].Close)
+		if prev > 0 {
+			change[prices[i].Day] = closePrice - prev
+		}
+	}
+	divergence := []string{}
+	for _, bucket := range sentiment {
+		diff, ok := change[bucket.Bucket]
+		if !ok {
+			continue
+		}
+		if (bucket.MeanScore > sentimentDivergenceScore && diff < 0) || (bucket.MeanScore < -sentimentDivergenceScore && diff > 0) {
+			divergence = append(divergence, bucket.Bucket)
+		}
+	}
+	return divergence
+}
@@ -0,0 +1,104 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/10/16 14:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestAggregateSentiment(t *testing.T) {
+	at := func(value string) time.Time {
+		v, _ := time.ParseInLocation(time.DateTime, value, time.Local)
+		return v
+	}
+	items := []sentimentItem{
+		{Code: "sh600000", Kind: NewsEntityStock, Time: at("2025-10-16 09:10:00"), Score: 3, Category: Positive},
+		{Code: "sh600000", Kind: NewsEntityStock, Time: at("2025-10-16 09:50:00"), Score: -1, Category: Neutral},
+		{Code: "sh600000", Kind: NewsEntityStock, Time: at("2025-10-16 14:00:00"), Score: -4, Category: Negative},
+		{Code: "bk0475", Kind: NewsEntityBoard, Time: at("2025-10-16 09:30:00"), Score: 2, Category: Positive},
+	}
+	hours := aggregateSentiment(items, SentimentPeriodHour)
+	if len(hours) != 3 || hours[1].Bucket != "2025-10-16 09:00" || hours[1].Count != 2 || hours[1].MeanScore != 1 {
+		t.Fatalf("unexpected hourly buckets %+v", hours)
+	}
+	days := aggregateSentiment(items, SentimentPeriodDay)
+	if len(days) != 2 {
+		t.Fatalf("unexpected daily buckets %+v", days)
+	}
+	day := days[1]
+	if day.Code != "sh600000" || day.Count != 3 || day.Positive != 1 || day.Negative != 1 || day.Neutral != 1 || day.MeanScore != -2.0/3 {
+		t.Errorf("unexpected daily bucket %+v", day)
+	}
+}
+
+func TestSentimentDivergence(t *testing.T) {
+	sentiment := []NewsSentimentBucket{
+		{Bucket: "2025-10-14", MeanScore: 1},
+		{Bucket: "2025-10-15", MeanScore: 0.5},
+		{Bucket: "2025-10-16", MeanScore: -1},
+		{Bucket: "2025-10-17", MeanScore: 0.2},
+	}
+	prices := []KLineData{
+		{Day: "2025-10-14", Close: "10"},
+		{Day: "2025-10-15", Close: "9.5"},
+		{Day: "2025-10-16", Close: "9.8"},
+		{Day: "2025-10-17", Close: "9.0"},
+	}
+	got := sentimentDivergence(sentiment, prices)
+	if len(got) != 2 || got[0] != "2025-10-15" || got[1] != "2025-10-16" {
+		t.Errorf("unexpected divergence %v", got)
+	}
+}
+
+func TestNewsSentimentApi(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "sentiment.db"))
+	db.Dao.AutoMigrate(&models.Telegraph{}, &TelegraphStock{}, &NewsSentimentBucket{}, &KLineBar{})
+
+	today := time.Now()
+	yesterday := today.AddDate(0, 0, -1)
+	create := func(content, sentiment string, dataTime time.Time, clusterId uint) {
+		telegraph := models.Telegraph{Content: content, SentimentResult: sentiment, DataTime: &dataTime, ClusterId: clusterId}
+		db.Dao.Create(&telegraph)
+		db.Dao.Create(&TelegraphStock{TelegraphId: telegraph.ID, StockCode: "sh600000", Name: "浦发银行", Kind: NewsEntityStock})
+	}
+	//已保存的情绪(如模型分类结果)优先于情感词典
+	create("涨 涨 涨", "看跌", yesterday, 0)
+	create("跌 跌", "", today, 0)
+	//重复报道不计入
+	create("跌 跌", "", today, 1)
+
+	if n, err := NewNewsSentimentApi().Aggregate(today.AddDate(0, 0, -2), today.Add(time.Minute)); err != nil || n != 4 {
+		t.Fatalf("unexpected bucket count %d %v", n, err)
+	}
+	//重新汇总覆盖原有结果
+	NewNewsSentimentApi().Aggregate(today.AddDate(0, 0, -2), today.Add(time.Minute))
+
+	db.Dao.Create(&KLineBar{Code: "sh600000", Period: KLinePeriodDay, Day: yesterday.AddDate(0, 0, -1).Format(time.DateOnly), Close: 10})
+	db.Dao.Create(&KLineBar{Code: "sh600000", Period: KLinePeriodDay, Day: yesterday.Format(time.DateOnly), Close: 9})
+	db.Dao.Create(&KLineBar{Code: "sh600000", Period: KLinePeriodDay, Day: today.Format(time.DateOnly), Close: 9.5})
+
+	series := NewNewsSentimentApi().GetSentimentSeries("SH600000", SentimentPeriodDay, 5)
+	if len(series.Sentiment) != 2 || series.Sentiment[1].Count != 1 {
+		t.Fatalf("unexpected series %+v", series.Sentiment)
+	}
+	if len(series.Prices) != 3 {
+		t.Errorf("unexpected prices %+v", series.Prices)
+	}
+	if len(series.Sentiment) == 2 && series.Sentiment[0].Negative != 1 {
+		t.Errorf("stored sentiment should be used %+v", series.Sentiment[0])
+	}
+	if len(series.Divergence) != 1 || series.Divergence[0] != today.Format(time.DateOnly) {
+		t.Errorf("unexpected divergence %v", series.Divergence)
+	}
+	hours := NewNewsSentimentApi().GetSentimentSeries("sh600000", SentimentPeriodHour, 5)
+	if len(hours.Sentiment) != 2 || len(hours.Divergence) != 0 {
+		t.Errorf("unexpected hourly series %+v", hours.Sentiment)
+	}
+}
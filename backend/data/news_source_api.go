@@ -0,0 +1,369 @@
+package data
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/models"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/cryptor"
+	"github.com/go-resty/resty/v2"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/10/14 9:30
+// @Desc 资讯来源插件:统一的拉取接口和注册表,每个来源可单独启用并设置刷新间隔,支持用户添加 RSS/Atom 订阅
+// -----------------------------------------------------------------------------------
+
+const (
+	NewsSourceBuiltin = "builtin"
+	NewsSourceRss     = "rss"
+
+	NewsSourceCls         = "cls"
+	NewsSourceSina        = "sina"
+	NewsSourceTradingView = "tradingview"
+	NewsSourceReuters     = "reuters"
+	NewsSourceGoStock     = "gostock"
+
+	//RSS 默认刷新间隔秒
+	defaultRssInterval = 300
+)
+
+// NewsSource 资讯来源,Fetch 拉取 since 之后的资讯并入库,返回新增的资讯;since 为零值时表示首次拉取
+type NewsSource interface {
+	Name() string
+	Fetch(since time.Time) []models.Telegraph
+}
+
+// NewsSourceConfig 资讯来源设置,Name 唯一
+type NewsSourceConfig struct {
+	gorm.Model
+	Name      string     `json:"name" gorm:"uniqueIndex"`
+	Title     string     `json:"title"` //显示名称,RSS 来源同时作为资讯的来源
+	Kind      string     `json:"kind"`  //builtin 内置 rss 用户添加的订阅
+	Url       string     `json:"url"`
+	Enabled   bool       `json:"enabled"`
+	Interval  int        `json:"interval"` //刷新间隔秒,0 跟随行情刷新间隔
+	Cursor    *time.Time `json:"cursor"`   //已拉取的最新资讯时间
+	FetchedAt *time.Time `json:"fetchedAt"`
+}
+
+func (NewsSourceConfig) TableName() string {
+	return "news_source_config"
+}
+
+type newsSourceEntry struct {
+	source   NewsSource
+	defaults NewsSourceConfig
+}
+
+var (
+	newsSourcesMu sync.RWMutex
+	newsSources   = map[string]newsSourceEntry{}
+)
+
+// RegisterNewsSource 注册内置资讯来源,defaults 为首次使用时保存的默认设置
+func RegisterNewsSource(source NewsSource, defaults NewsSourceConfig) {
+	defaults.Name, defaults.Kind = source.Name(), NewsSourceBuiltin
+	newsSourcesMu.Lock()
+	newsSources[source.Name()] = newsSourceEntry{source: source, defaults: defaults}
+	newsSourcesMu.Unlock()
+}
+
+// RegisterBuiltinNewsSources 注册内置资讯来源,外媒和 go-stock 资讯默认不定时刷新
+func RegisterBuiltinNewsSources() {
+	RegisterNewsSource(newsSourceFunc{name: NewsSourceCls, fetch: func(since time.Time) *[]models.Telegraph {
+		return NewMarketNewsApi().TelegraphList(30)
+	}}, NewsSourceConfig{Title: "财联社电报", Enabled: true})
+	RegisterNewsSource(newsSourceFunc{name: NewsSourceSina, fetch: func(since time.Time) *[]models.Telegraph {
+		return NewMarketNewsApi().GetSinaNews(30)
+	}}, NewsSourceConfig{Title: "新浪财经", Enabled: true})
+	RegisterNewsSource(newsSourceFunc{name: NewsSourceTradingView, fetch: func(since time.Time) *[]models.Telegraph {
+		return NewMarketNewsApi().TradingViewNews()
+	}}, NewsSourceConfig{Title: "TradingView", Enabled: true})
+	RegisterNewsSource(newsSourceFunc{name: NewsSourceReuters, fetch: reutersNews}, NewsSourceConfig{Title: "路透社", Interval: defaultRssInterval})
+	RegisterNewsSource(newsSourceFunc{name: NewsSourceGoStock, fetch: goStockNews}, NewsSourceConfig{Title: "go-stock资讯"})
+}
+
+// newsSourceFunc 把已有的抓取函数适配为资讯来源
+type newsSourceFunc struct {
+	name  string
+	fetch func(since time.Time) *[]models.Telegraph
+}
+
+func (n newsSourceFunc) Name() string {
+	return n.name
+}
+
+func (n newsSourceFunc) Fetch(since time.Time) []models.Telegraph {
+	news := n.fetch(since)
+	if news == nil {
+		return nil
+	}
+	return *news
+}
+
+type NewsSourceApi struct {
+}
+
+func NewNewsSourceApi() *NewsSourceApi {
+	return &NewsSourceApi{}
+}
+
+// GetNewsSources 所有资讯来源的设置,内置来源没有设置时按默认值创建
+func (n NewsSourceApi) GetNewsSources() []NewsSourceConfig {
+	newsSourcesMu.RLock()
+	entries := lo.Values(newsSources)
+	newsSourcesMu.RUnlock()
+	for _, entry := range entries {
+		config := entry.defaults
+		db.Dao.Where(NewsSourceConfig{Name: config.Name}).Attrs(config).FirstOrCreate(&config)
+	}
+	var configs []NewsSourceConfig
+	db.Dao.Model(&NewsSourceConfig{}).Order("id").Find(&configs)
+	return configs
+}
+
+// GetNewsSource 单个资讯来源的设置
+func (n NewsSourceApi) GetNewsSource(name string) (NewsSourceConfig, bool) {
+	var config NewsSourceConfig
+	db.Dao.Model(&NewsSourceConfig{}).Where("name = ?", name).Limit(1).Find(&config)
+	return config, config.ID != 0
+}
+
+// UpdateNewsSource 修改来源的启用状态和刷新间隔
+func (n NewsSourceApi) UpdateNewsSource(name string, enabled bool, interval int) string {
+	if interval < 0 {
+		return "刷新间隔不能小于0"
+	}
+	res := db.Dao.Model(&NewsSourceConfig{}).Where("name = ?", name).
+		Updates(map[string]any{"enabled": enabled, "interval": interval})
+	if res.Error != nil {
+		logger.SugaredLogger.Errorf("update news source error:%s", res.Error.Error())
+		return "保存失败"
+	}
+	if res.RowsAffected == 0 {
+		return "资讯来源不存在"
+	}
+	return "保存成功"
+}
+
+// AddRssSource 添加 RSS/Atom 订阅,title 作为资讯的来源显示
+func (n NewsSourceApi) AddRssSource(title, url string, interval int) (NewsSourceConfig, string) {
+	if title == "" || url == "" {
+		return NewsSourceConfig{}, "名称和地址不能为空"
+	}
+	if interval <= 0 {
+		interval = defaultRssInterval
+	}
+	config := NewsSourceConfig{
+		Name:     NewsSourceRss + "_" + cryptor.Md5String(url)[:8],
+		Title:    title,
+		Kind:     NewsSourceRss,
+		Url:      url,
+		Enabled:  true,
+		Interval: interval,
+	}
+	if _, ok := n.GetNewsSource(config.Name); ok {
+		return config, "订阅已存在"
+	}
+	if err := db.Dao.Create(&config).Error; err != nil {
+		logger.SugaredLogger.Errorf("add rss source error:%s", err.Error())
+		return config, "添加失败"
+	}
+	return config, "添加成功"
+}
+
+// DeleteNewsSource 删除 RSS 订阅,内置来源只能停用
+func (n NewsSourceApi) DeleteNewsSource(name string) string {
+	config, ok := n.GetNewsSource(name)
+	if !ok {
+		return "资讯来源不存在"
+	}
+	if config.Kind != NewsSourceRss {
+		return "内置来源不能删除"
+	}
+	db.Dao.Unscoped().Delete(&config)
+	return "删除成功"
+}
+
+// Fetch 拉取指定来源的新资讯并推进游标,来源不存在时返回空列表
+func (n NewsSourceApi) Fetch(name string) *[]models.Telegraph {
+	news := &[]models.Telegraph{}
+	config, ok := n.GetNewsSource(name)
+	if !ok {
+		return news
+	}
+	source := n.source(config)
+	if source == nil {
+		return news
+	}
+	since := time.Time{}
+	if config.Cursor != nil {
+		since = *config.Cursor
+	}
+	*news = source.Fetch(since)
+
+	now := time.Now()
+	updates := map[string]any{"fetched_at": now}
+	if cursor := newsCursor(since, *news); cursor.After(since) {
+		updates["cursor"] = cursor
+	}
+	db.Dao.Model(&NewsSourceConfig{}).Where("id = ?", config.ID).Updates(updates)
+	return news
+}
+
+func (n NewsSourceApi) source(config NewsSourceConfig) NewsSource {
+	if config.Kind == NewsSourceRss {
+		return NewRssNewsSource(config.Name, config.Title, config.Url)
+	}
+	newsSourcesMu.RLock()
+	defer newsSourcesMu.RUnlock()
+	if entry, ok := newsSources[config.Name]; ok {
+		return entry.source
+	}
+	return nil
+}
+
+// newsCursor 本次拉取到的最新资讯时间,没有更新的资讯时保持原游标
+func newsCursor(since time.Time, news []models.Telegraph) time.Time {
+	times := lo.FilterMap(news, func(item models.Telegraph, _ int) (time.Time, bool) {
+		if item.DataTime == nil {
+			return time.Time{}, false
+		}
+		return *item.DataTime, true
+	})
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].After(times[j])
+	})
+	if len(times) == 0 || !times[0].After(since) {
+		return since
+	}
+	return times[0]
+}
+
+// saveSourceNews 按标题(无标题时按内容)去重后入库并关联股票,返回是否为新资讯
+func saveSourceNews(telegraph *models.Telegraph) bool {
+	cnt := int64(0)
+	if telegraph.Title == "" {
+		db.Dao.Model(&models.Telegraph{}).Where("content=?", telegraph.Content).Count(&cnt)
+	} else {
+		db.Dao.Model(&models.Telegraph{}).Where("title=?", telegraph.Title).Count(&cnt)
+	}
+	if cnt > 0 {
+		return false
+	}
+	if telegraph.SentimentResult == "" && segLoaded() {
+		telegraph.SentimentResult = AnalyzeSentiment(telegraph.Title + " " + telegraph.Content).Description
+	}
+	if err := db.Dao.Model(&models.Telegraph{}).Create(telegraph).Error; err != nil {
+		logger.SugaredLogger.Errorf("save news error:%s", err.Error())
+		return false
+	}
+	NewNewsEntityApi().LinkTelegraph(telegraph)
+	return true
+}
+
+// reutersNews 路透社最新报道
+func reutersNews(since time.Time) *[]models.Telegraph {
+	news := &[]models.Telegraph{}
+	for _, article := range NewMarketNewsApi().ReutersNew().Result.Articles {
+		dataTime := article.PublishedTime.Local()
+		if article.Title == "" || dataTime.Before(since) {
+			continue
+		}
+		telegraph := &models.Telegraph{
+			Title:    article.Title,
+			Content:  article.Description,
+			DataTime: &dataTime,
+			Time:     dataTime.Format("15:04:05"),
+			Source:   "外媒",
+			Url:      "https://www.reuters.com" + article.CanonicalUrl,
+		}
+		if saveSourceNews(telegraph) {
+			*news = append(*news, *telegraph)
+		}
+	}
+	return news
+}
+
+// goStockNews go-stock 资讯服务,首次拉取最近24小时
+func goStockNews(since time.Time) *[]models.Telegraph {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.SugaredLogger.Errorf("goStockNews panic:%v", r)
+		}
+	}()
+	news := &[]models.Telegraph{}
+	if since.IsZero() || time.Since(since) > 24*time.Hour {
+		since = time.Now().Add(-24 * time.Hour)
+	}
+	url := fmt.Sprintf("http://go-stock.sparkmemory.top:16666/FinancialNews/json?since=%d", since.Unix())
+	logger.SugaredLogger.Infof("syncNews:%s", url)
+	resp, err := resty.New().R().SetDoNotParseResponse(true).Get(url)
+	if err != nil {
+		logger.SugaredLogger.Errorf("syncNews error:%s", err.Error())
+		return news
+	}
+	body := resp.RawBody()
+	defer body.Close()
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		item := &models.NtfyNews{}
+		if err := json.Unmarshal(scanner.Bytes(), item); err != nil {
+			return news
+		}
+		if !lo.Some(item.Tags, []string{"外媒资讯", "财联社电报", "新浪财经", "外媒简讯", "外媒"}) {
+			continue
+		}
+		dataTime := time.UnixMilli(int64(item.Time * 1000))
+		telegraph := &models.Telegraph{
+			Title:    item.Title,
+			Content:  item.Message,
+			DataTime: &dataTime,
+			IsRed:    slices.Contains(item.Tags, "rotating_light"),
+			Time:     dataTime.Format("15:04:05"),
+			Source:   goStockNewsSource(item.Tags),
+		}
+		if !saveSourceNews(telegraph) {
+			continue
+		}
+		*news = append(*news, *telegraph)
+		for _, subject := range item.Tags {
+			if subject == "rotating_light" || subject == "loudspeaker" {
+				continue
+			}
+			tag := &models.Tags{
+				Name: subject,
+				Type: "subject",
+			}
+			db.Dao.Model(tag).Where("name=? and type=?", subject, "subject").FirstOrCreate(&tag)
+			db.Dao.Model(models.TelegraphTags{}).Where("telegraph_id=? and tag_id=?", telegraph.ID, tag.ID).FirstOrCreate(&models.TelegraphTags{
+				TelegraphId: telegraph.ID,
+				TagId:       tag.ID,
+			})
+		}
+	}
+	return news
+}
+
+func goStockNewsSource(tags []string) string {
+	if lo.Some(tags, []string{"外媒简讯", "外媒资讯", "外媒"}) {
+		return "外媒"
+	}
+	if slices.Contains(tags, "财联社电报") {
+		return "财联社电报"
+	}
+	if slices.Contains(tags, "新浪财经") {
+		return "新浪财经"
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/10/14 14:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+type fakeNewsSource struct {
+	since []time.Time
+	news  []models.Telegraph
+}
+
+func (f *fakeNewsSource) Name() string {
+	return "fake"
+}
+
+func (f *fakeNewsSource) Fetch(since time.Time) []models.Telegraph {
+	f.since = append(f.since, since)
+	return f.news
+}
+
+func TestParseFeed(t *testing.T) {
+	rss := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel><title>示例</title>
+<item><title>央行降准</title><link>https://example.com/1</link>
+<description><![CDATA[<p>央行宣布<b>下调</b>存款准备金率</p>]]></description>
+<pubDate>Tue, 14 Oct 2025 08:30:00 +0800</pubDate></item>
+<item><title>无日期</title><dc:date>2025-10-14T09:00:00+08:00</dc:date></item>
+</channel></rss>`
+	news, err := parseFeed([]byte(rss), "示例")
+	if err != nil || len(news) != 2 {
+		t.Fatalf("parse rss error:%v %+v", err, news)
+	}
+	if news[0].Content != "央行宣布下调存款准备金率" || news[0].Url != "https://example.com/1" || news[0].Source != "示例" {
+		t.Errorf("unexpected item %+v", news[0])
+	}
+	want := time.Date(2025, 10, 14, 8, 30, 0, 0, time.FixedZone("", 8*3600))
+	if !news[0].DataTime.Equal(want) || !news[1].DataTime.Equal(want.Add(30*time.Minute)) {
+		t.Errorf("unexpected time %v %v", news[0].DataTime, news[1].DataTime)
+	}
+
+	atom := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title>
+<entry><title>Fed holds rates</title>
+<link rel="self" href="https://example.com/self"/><link rel="alternate" href="https://example.com/fed"/>
+<summary>The Fed kept rates unchanged.</summary><updated>2025-10-14T01:00:00Z</updated></entry>
+</feed>`
+	news, err = parseFeed([]byte(atom), "Atom")
+	if err != nil || len(news) != 1 {
+		t.Fatalf("parse atom error:%v %+v", err, news)
+	}
+	if news[0].Url != "https://example.com/fed" || news[0].Content != "The Fed kept rates unchanged." {
+		t.Errorf("unexpected entry %+v", news[0])
+	}
+	if _, err = parseFeed([]byte("not xml"), ""); err == nil {
+		t.Errorf("invalid feed should fail")
+	}
+	//美东时区缩写按 -0500 解析
+	if got := parseFeedTime("Tue, 14 Oct 2025 08:30:00 EST"); !got.Equal(time.Date(2025, 10, 14, 13, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected EST time %v", got)
+	}
+}
+
+func TestNewsSourceApi(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "source.db"))
+	db.Dao.AutoMigrate(&NewsSourceConfig{})
+	fake := &fakeNewsSource{}
+	RegisterNewsSource(fake, NewsSourceConfig{Title: "测试", Enabled: true})
+	defer func() {
+		newsSourcesMu.Lock()
+		delete(newsSources, fake.Name())
+		newsSourcesMu.Unlock()
+	}()
+
+	api := NewNewsSourceApi()
+	configs := api.GetNewsSources()
+	config, ok := api.GetNewsSource("fake")
+	if !ok || !config.Enabled || config.Kind != NewsSourceBuiltin || len(api.GetNewsSources()) != len(configs) {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	if msg := api.UpdateNewsSource("fake", false, 60); msg != "保存成功" {
+		t.Errorf("update:%s", msg)
+	}
+	//再次读取不会覆盖用户的设置
+	api.GetNewsSources()
+	if config, _ = api.GetNewsSource("fake"); config.Enabled || config.Interval != 60 {
+		t.Errorf("settings overwritten %+v", config)
+	}
+	if msg := api.DeleteNewsSource("fake"); msg != "内置来源不能删除" {
+		t.Errorf("delete builtin:%s", msg)
+	}
+
+	first := time.Now().Add(-time.Hour)
+	fake.news = []models.Telegraph{{Title: "a", DataTime: &first}}
+	api.Fetch("fake")
+	api.Fetch("fake")
+	if len(fake.since) != 2 || !fake.since[0].IsZero() || !fake.since[1].Equal(first) {
+		t.Errorf("cursor not advanced %v", fake.since)
+	}
+	if config, _ = api.GetNewsSource("fake"); config.FetchedAt == nil {
+		t.Errorf("fetched time not saved")
+	}
+
+	rss, msg := api.AddRssSource("示例", "https://example.com/rss", 0)
+	if msg != "添加成功" || rss.Interval != defaultRssInterval || rss.Kind != NewsSourceRss {
+		t.Fatalf("add rss:%s %+v", msg, rss)
+	}
+	if _, msg = api.AddRssSource("示例", "https://example.com/rss", 0); msg != "订阅已存在" {
+		t.Errorf("duplicate rss:%s", msg)
+	}
+	if _, ok = api.source(rss).(*RssNewsSource); !ok {
+		t.Errorf("rss config should use rss source")
+	}
+	if msg = api.DeleteNewsSource(rss.Name); msg != "删除成功" {
+		t.Errorf("delete rss:%s", msg)
+	}
+	if _, ok = api.GetNewsSource(rss.Name); ok {
+		t.Errorf("rss source should be deleted")
+	}
+}
@@ -0,0 +1,306 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/9/24 9:30
+// @Desc 消息推送渠道:钉钉、飞书、企业微信、Telegram、邮件、ntfy、通用Webhook
+// 每个渠道一条配置,按消息类型路由,报警和快讯推送时分发到对应渠道
+// -----------------------------------------------------------------------------------
+
+// 渠道类型
+const (
+	NotifyChannelDingDing = "dingding"
+	NotifyChannelFeishu   = "feishu"
+	NotifyChannelWeCom    = "wecom"
+	NotifyChannelTelegram = "telegram"
+	NotifyChannelEmail    = "email"
+	NotifyChannelNtfy     = "ntfy"
+	NotifyChannelWebhook  = "webhook"
+)
+
+// NotifyMsgTypeNews 快讯推送,报警类型 1-4 见 App.SendDingDingMessageByType
+const NotifyMsgTypeNews = 10
+
+// Notification 推送消息,Content 为 Markdown 文本
+type Notification struct {
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	MsgType   int       `json:"msgType"`
+	StockCode string    `json:"stockCode"`
+	Time      time.Time `json:"time"`
+	// Raw 原始钉钉消息体,钉钉渠道优先原样发送
+	Raw string `json:"-"`
+}
+
+// Notifier 推送渠道
+type Notifier interface {
+	Type() string
+	Send(n Notification) error
+}
+
+// NotifyChannel 推送渠道配置,各渠道字段含义:
+// dingding/feishu/wecom: Url 机器人地址,Secret 加签密钥
+// telegram: Secret 机器人Token,Target 会话ID,Url 可选的API地址
+// email: Url SMTP服务器,Port 端口,Username 账号,Secret 密码,Target 收件人(逗号分隔)
+// ntfy: Url 服务地址,Target 主题,Secret 可选的访问令牌
+// webhook: Url 地址,Secret 可选的签名密钥
+type NotifyChannel struct {
+	gorm.Model
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Enable   bool   `json:"enable"`
+	Url      string `json:"url"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Secret   string `json:"secret"`
+	Target   string `json:"target"`
+	MsgTypes string `json:"msgTypes"` //接收的消息类型,逗号分隔,为空时接收所有报警消息(不含快讯)
+}
+
+func (NotifyChannel) TableName() string {
+	return "notify_channel"
+}
+
+// Routes 渠道是否接收该类型消息
+func (c NotifyChannel) Routes(msgType int) bool {
+	if strutil.Trim(c.MsgTypes) == "" {
+		return msgType != NotifyMsgTypeNews
+	}
+	return slice.Contain(strutil.SplitAndTrim(c.MsgTypes, ","), convertor.ToString(msgType))
+}
+
+// NotifyResult 单个渠道的发送结果
+type NotifyResult struct {
+	Channel string `json:"channel"`
+	Type    string `json:"type"`
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+var (
+	notifierFactories   = map[string]func(channel NotifyChannel) Notifier{}
+	notifierFactoriesMu sync.RWMutex
+)
+
+// RegisterNotifier 注册渠道实现
+func RegisterNotifier(channelType string, factory func(channel NotifyChannel) Notifier) {
+	notifierFactoriesMu.Lock()
+	defer notifierFactoriesMu.Unlock()
+	notifierFactories[channelType] = factory
+}
+
+// NewNotifier 按渠道配置创建推送实现
+func NewNotifier(channel NotifyChannel) (Notifier, error) {
+	notifierFactoriesMu.RLock()
+	factory, ok := notifierFactories[channel.Type]
+	notifierFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的推送渠道:%s", channel.Type)
+	}
+	return factory(channel), nil
+}
+
+type NotifyApi struct {
+}
+
+func NewNotifyApi() *NotifyApi {
+	return &NotifyApi{}
+}
+
+func (n NotifyApi) SaveChannel(channel NotifyChannel) string {
+	if _, err := NewNotifier(channel); err != nil {
+		return err.Error()
+	}
+	var err error
+	if channel.ID > 0 {
+		err = db.Dao.Model(&NotifyChannel{}).Where("id = ?", channel.ID).Updates(map[string]any{
+			"name":      channel.Name,
+			"type":      channel.Type,
+			"enable":    channel.Enable,
+			"url":       channel.Url,
+			"port":      channel.Port,
+			"username":  channel.Username,
+			"secret":    channel.Secret,
+			"target":    channel.Target,
+			"msg_types": channel.MsgTypes,
+		}).Error
+	} else {
+		err = db.Dao.Create(&channel).Error
+	}
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+		return "保存失败"
+	}
+	return "保存成功"
+}
+
+func (n NotifyApi) DeleteChannel(id uint) string {
+	err := db.Dao.Delete(&NotifyChannel{}, id).Error
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+		return "删除失败"
+	}
+	return "删除成功"
+}
+
+func (n NotifyApi) GetChannels() []NotifyChannel {
+	var channels []NotifyChannel
+	db.Dao.Model(&NotifyChannel{}).Order("id asc").Find(&channels)
+	return channels
+}
+
+// TestChannel 向指定渠道发送测试消息
+func (n NotifyApi) TestChannel(id uint) string {
+	channel := NotifyChannel{}
+	db.Dao.Model(&NotifyChannel{}).Where("id = ?", id).First(&channel)
+	if channel.ID == 0 {
+		return "推送渠道不存在"
+	}
+	notifier, err := NewNotifier(channel)
+	if err != nil {
+		return err.Error()
+	}
+	err = notifier.Send(Notification{
+		Title:   "lumos-stock 测试消息",
+		Content: "### lumos-stock 测试消息\n- 推送渠道:" + channel.Name + "\n- 时间:" + time.Now().Format(time.DateTime),
+		Time:    time.Now(),
+	})
+	if err != nil {
+		return "发送失败:" + err.Error()
+	}
+	return "发送成功"
+}
+
+// Dispatch 发送到所有启用并接收该类型消息的渠道
+func (n NotifyApi) Dispatch(notification Notification) []NotifyResult {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+	channels := slice.Filter(n.GetChannels(), func(i int, c NotifyChannel) bool {
+		return c.Enable && c.Routes(notification.MsgType)
+	})
+	channels = append(channels, legacyDingDingChannel(channels, notification.MsgType)...)
+
+	results := make([]NotifyResult, len(channels))
+	var wg sync.WaitGroup
+	for i, channel := range channels {
+		wg.Add(1)
+		go func(i int, channel NotifyChannel) {
+			defer wg.Done()
+			result := NotifyResult{Channel: channel.Name, Type: channel.Type}
+			notifier, err := NewNotifier(channel)
+			if err == nil {
+				err = notifier.Send(notification)
+			}
+			if err != nil {
+				logger.SugaredLogger.Errorf("notify %s(%s) error:%s", channel.Name, channel.Type, err.Error())
+				result.Error = err.Error()
+			} else {
+				result.Success = true
+			}
+			results[i] = result
+		}(i, channel)
+	}
+	wg.Wait()
+	return results
+}
+
+// legacyDingDingChannel 设置中的钉钉机器人作为默认钉钉渠道,已单独配置相同地址时不重复发送
+func legacyDingDingChannel(channels []NotifyChannel, msgType int) []NotifyChannel {
+	if msgType == NotifyMsgTypeNews {
+		return nil
+	}
+	config := GetSettingConfig()
+	if !config.DingPushEnable || config.DingRobot == "" {
+		return nil
+	}
+	if slice.ContainBy(channels, func(c NotifyChannel) bool {
+		return c.Type == NotifyChannelDingDing && c.Url == config.DingRobot
+	}) {
+		return nil
+	}
+	return []NotifyChannel{{Name: "钉钉", Type: NotifyChannelDingDing, Enable: true, Url: config.DingRobot}}
+}
+
+// NotifyResultMessage 发送结果汇总
+func NotifyResultMessage(results []NotifyResult) string {
+	if len(results) == 0 {
+		return "未配置推送渠道"
+	}
+	var success, failed []string
+	for _, result := range results {
+		if result.Success {
+			success = append(success, result.Channel)
+		} else {
+			failed = append(failed, result.Channel)
+		}
+	}
+	var msg strings.Builder
+	if len(success) > 0 {
+		msg.WriteString("发送成功:" + strings.Join(success, ","))
+	}
+	if len(failed) > 0 {
+		if msg.Len() > 0 {
+			msg.WriteString(" ")
+		}
+		msg.WriteString("发送失败:" + strings.Join(failed, ","))
+	}
+	return msg.String()
+}
+
+// ParseDingDingMessage 将钉钉消息体转为通用消息,非钉钉格式时按纯文本处理
+func ParseDingDingMessage(message, stockCode string, msgType int) Notification {
+	notification := Notification{Content: message, MsgType: msgType, StockCode: stockCode, Raw: message, Time: time.Now()}
+	msg := struct {
+		Msgtype  string   `json:"msgtype"`
+		Markdown Markdown `json:"markdown"`
+		Text     struct {
+			Content string `json:"content"`
+		} `json:"text"`
+	}{}
+	if err := json.Unmarshal([]byte(message), &msg); err != nil {
+		notification.Raw = ""
+		return notification
+	}
+	switch msg.Msgtype {
+	case "markdown":
+		notification.Title = msg.Markdown.Title
+		notification.Content = msg.Markdown.Text
+	case "text":
+		notification.Content = msg.Text.Content
+	}
+	return notification
+}
+
+// NewsNotification 快讯推送消息
+func NewsNotification(title, content, source, url string) Notification {
+	var md strings.Builder
+	if title != "" {
+		md.WriteString("### " + title + "\n\n")
+	}
+	md.WriteString(content + "\n")
+	if source != "" {
+		md.WriteString("\n> " + source + "\n")
+	}
+	if url != "" {
+		md.WriteString("\n[查看原文](" + url + ")\n")
+	}
+	if title == "" {
+		title = strutil.Substring(content, 0, 30)
+	}
+	return Notification{Title: title, Content: md.String(), MsgType: NotifyMsgTypeNews, Time: time.Now()}
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// @Author spark
+// @Date 2025/9/24 15:20
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestNotifyChannelRoutes(t *testing.T) {
+	all := NotifyChannel{}
+	if !all.Routes(1) || !all.Routes(4) || all.Routes(NotifyMsgTypeNews) {
+		t.Error("empty msgTypes should receive alerts but not news")
+	}
+	news := NotifyChannel{MsgTypes: "2, 10"}
+	if news.Routes(1) || !news.Routes(2) || !news.Routes(NotifyMsgTypeNews) {
+		t.Error("unexpected routes")
+	}
+}
+
+func TestParseDingDingMessage(t *testing.T) {
+	msg := `{"msgtype":"markdown","markdown":{"title":"股价报警","text":"### 浦发银行"},"at":{"isAtAll":true}}`
+	n := ParseDingDingMessage(msg, "sh600000", 2)
+	if n.Title != "股价报警" || n.Content != "### 浦发银行" || n.Raw != msg {
+		t.Errorf("unexpected notification %+v", n)
+	}
+	n = ParseDingDingMessage("plain text", "sh600000", 1)
+	if n.Content != "plain text" || n.Raw != "" {
+		t.Errorf("plain text should not be sent raw %+v", n)
+	}
+}
+
+func TestNotifierSend(t *testing.T) {
+	var gotSign, gotQuery string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSign = r.Header.Get("X-Lumos-Signature")
+		gotQuery = r.URL.RawQuery
+		w.Write([]byte(`{"errcode":0,"code":0,"ok":true}`))
+	}))
+	defer server.Close()
+
+	notification := Notification{Title: "测试", Content: "内容", MsgType: 1}
+	for _, channelType := range []string{NotifyChannelDingDing, NotifyChannelFeishu, NotifyChannelWeCom, NotifyChannelNtfy, NotifyChannelWebhook} {
+		notifier, err := NewNotifier(NotifyChannel{Type: channelType, Url: server.URL, Secret: "secret", Target: "topic"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = notifier.Send(notification); err != nil {
+			t.Errorf("%s send error:%s", channelType, err.Error())
+		}
+		if !strings.Contains(string(gotBody), "内容") {
+			t.Errorf("%s unexpected body %s", channelType, gotBody)
+		}
+		switch channelType {
+		case NotifyChannelDingDing:
+			if !strings.Contains(gotQuery, "timestamp=") || !strings.Contains(gotQuery, "sign=") {
+				t.Errorf("dingding should be signed %s", gotQuery)
+			}
+		case NotifyChannelFeishu:
+			body := map[string]any{}
+			_ = json.Unmarshal(gotBody, &body)
+			if body["sign"] == nil || body["timestamp"] == nil {
+				t.Errorf("feishu should be signed %s", gotBody)
+			}
+		case NotifyChannelWebhook:
+			mac := hmac.New(sha256.New, []byte("secret"))
+			mac.Write(gotBody)
+			if gotSign != hex.EncodeToString(mac.Sum(nil)) {
+				t.Errorf("unexpected webhook signature %s", gotSign)
+			}
+		}
+	}
+	if _, err := NewNotifier(NotifyChannel{Type: "unknown"}); err == nil {
+		t.Error("unknown channel type should fail")
+	}
+}
+
+func TestNotifyResultMessage(t *testing.T) {
+	msg := NotifyResultMessage([]NotifyResult{{Channel: "钉钉", Success: true}, {Channel: "飞书"}})
+	if msg != "发送成功:钉钉 发送失败:飞书" {
+		t.Errorf("unexpected message %s", msg)
+	}
+}
@@ -0,0 +1,348 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/go-resty/resty/v2"
+)
+
+// @Author spark
+// @Date 2025/9/24 10:20
+// @Desc 推送渠道实现
+// -----------------------------------------------------------------------------------
+
+func init() {
+	RegisterNotifier(NotifyChannelDingDing, func(c NotifyChannel) Notifier { return &DingDingNotifier{channel: c} })
+	RegisterNotifier(NotifyChannelFeishu, func(c NotifyChannel) Notifier { return &FeishuNotifier{channel: c} })
+	RegisterNotifier(NotifyChannelWeCom, func(c NotifyChannel) Notifier { return &WeComNotifier{channel: c} })
+	RegisterNotifier(NotifyChannelTelegram, func(c NotifyChannel) Notifier { return &TelegramNotifier{channel: c} })
+	RegisterNotifier(NotifyChannelEmail, func(c NotifyChannel) Notifier { return &EmailNotifier{channel: c} })
+	RegisterNotifier(NotifyChannelNtfy, func(c NotifyChannel) Notifier { return &NtfyNotifier{channel: c} })
+	RegisterNotifier(NotifyChannelWebhook, func(c NotifyChannel) Notifier { return &WebhookNotifier{channel: c} })
+}
+
+func notifyClient() *resty.Client {
+	return resty.New().SetTimeout(15 * time.Second)
+}
+
+// postJSON 发送JSON请求,HTTP状态码异常时返回错误
+func postJSON(request *resty.Request, url string, body any) (*resty.Response, error) {
+	resp, err := request.SetHeader("Content-Type", "application/json").SetBody(body).Post(url)
+	if err != nil {
+		return resp, err
+	}
+	if resp.IsError() {
+		return resp, fmt.Errorf("status:%d %s", resp.StatusCode(), resp.String())
+	}
+	return resp, nil
+}
+
+func hmacSHA256(key, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// DingDingNotifier 钉钉机器人,配置了加签密钥时在地址上附加 timestamp 和 sign
+type DingDingNotifier struct {
+	channel NotifyChannel
+}
+
+func (d DingDingNotifier) Type() string {
+	return NotifyChannelDingDing
+}
+
+// DingDingSign 钉钉加签:base64(HmacSHA256(secret, timestamp+"\n"+secret))
+func DingDingSign(secret string, timestamp int64) string {
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
+	return base64.StdEncoding.EncodeToString(hmacSHA256([]byte(secret), []byte(stringToSign)))
+}
+
+func (d DingDingNotifier) Send(n Notification) error {
+	webhook := d.channel.Url
+	if d.channel.Secret != "" {
+		timestamp := time.Now().UnixMilli()
+		sep := "?"
+		if strings.Contains(webhook, "?") {
+			sep = "&"
+		}
+		webhook += fmt.Sprintf("%stimestamp=%d&sign=%s", sep, timestamp, url.QueryEscape(DingDingSign(d.channel.Secret, timestamp)))
+	}
+	var body any = n.Raw
+	if n.Raw == "" {
+		body = &Message{
+			Msgtype:  "markdown",
+			Markdown: Markdown{Title: n.Title, Text: n.Content},
+			At:       At{IsAtAll: true},
+		}
+	}
+	resp, err := postJSON(notifyClient().R(), webhook, body)
+	if err != nil {
+		return err
+	}
+	res := struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}{}
+	_ = json.Unmarshal(resp.Body(), &res)
+	if res.ErrCode != 0 {
+		return fmt.Errorf("dingding errcode:%d %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// FeishuNotifier 飞书/Lark 自定义机器人,使用消息卡片发送 Markdown
+type FeishuNotifier struct {
+	channel NotifyChannel
+}
+
+func (f FeishuNotifier) Type() string {
+	return NotifyChannelFeishu
+}
+
+// FeishuSign 飞书加签:base64(HmacSHA256(key=timestamp+"\n"+secret, 空消息))
+func FeishuSign(secret string, timestamp int64) string {
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
+	return base64.StdEncoding.EncodeToString(hmacSHA256([]byte(stringToSign), nil))
+}
+
+func (f FeishuNotifier) Send(n Notification) error {
+	body := map[string]any{
+		"msg_type": "interactive",
+		"card": map[string]any{
+			"header": map[string]any{
+				"title": map[string]any{"tag": "plain_text", "content": n.Title},
+			},
+			"elements": []any{
+				map[string]any{"tag": "markdown", "content": n.Content},
+			},
+		},
+	}
+	if f.channel.Secret != "" {
+		timestamp := time.Now().Unix()
+		body["timestamp"] = convertor.ToString(timestamp)
+		body["sign"] = FeishuSign(f.channel.Secret, timestamp)
+	}
+	resp, err := postJSON(notifyClient().R(), f.channel.Url, body)
+	if err != nil {
+		return err
+	}
+	res := struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}{}
+	_ = json.Unmarshal(resp.Body(), &res)
+	if res.Code != 0 {
+		return fmt.Errorf("feishu code:%d %s", res.Code, res.Msg)
+	}
+	return nil
+}
+
+// WeComNotifier 企业微信群机器人
+type WeComNotifier struct {
+	channel NotifyChannel
+}
+
+func (w WeComNotifier) Type() string {
+	return NotifyChannelWeCom
+}
+
+func (w WeComNotifier) Send(n Notification) error {
+	content := n.Content
+	//企业微信 markdown 内容最长4096字节
+	if len(content) > 4000 {
+		content = strutil.Substring(content, 0, 1300) + "..."
+	}
+	resp, err := postJSON(notifyClient().R(), w.channel.Url, map[string]any{
+		"msgtype":  "markdown",
+		"markdown": map[string]any{"content": content},
+	})
+	if err != nil {
+		return err
+	}
+	res := struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}{}
+	_ = json.Unmarshal(resp.Body(), &res)
+	if res.ErrCode != 0 {
+		return fmt.Errorf("wecom errcode:%d %s", res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// TelegramNotifier Telegram 机器人,以纯文本发送避免 Markdown 转义问题
+type TelegramNotifier struct {
+	channel NotifyChannel
+}
+
+func (t TelegramNotifier) Type() string {
+	return NotifyChannelTelegram
+}
+
+func (t TelegramNotifier) Send(n Notification) error {
+	api := strings.TrimSuffix(t.channel.Url, "/")
+	if api == "" {
+		api = "https://api.telegram.org"
+	}
+	text := n.Content
+	if n.Title != "" && !strings.Contains(n.Content, n.Title) {
+		text = n.Title + "\n\n" + n.Content
+	}
+	client := notifyClient()
+	config := GetSettingConfig()
+	if config.HttpProxyEnabled && config.HttpProxy != "" {
+		client.SetProxy(config.HttpProxy)
+	}
+	resp, err := postJSON(client.R(), fmt.Sprintf("%s/bot%s/sendMessage", api, t.channel.Secret), map[string]any{
+		"chat_id":                  t.channel.Target,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	res := struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}{}
+	_ = json.Unmarshal(resp.Body(), &res)
+	if !res.Ok {
+		return fmt.Errorf("telegram %s", res.Description)
+	}
+	return nil
+}
+
+// EmailNotifier SMTP 邮件,465端口使用SSL,其他端口在服务器支持时使用STARTTLS
+type EmailNotifier struct {
+	channel NotifyChannel
+}
+
+func (e EmailNotifier) Type() string {
+	return NotifyChannelEmail
+}
+
+func (e EmailNotifier) Send(n Notification) error {
+	port := e.channel.Port
+	if port <= 0 {
+		port = 465
+	}
+	to := strutil.SplitAndTrim(e.channel.Target, ",")
+	if len(to) == 0 {
+		return fmt.Errorf("未配置收件人")
+	}
+	subject := n.Title
+	if subject == "" {
+		subject = "lumos-stock 消息通知"
+	}
+	var msg strings.Builder
+	msg.WriteString("From: " + e.channel.Username + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ",") + "\r\n")
+	msg.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	msg.WriteString("Date: " + n.Time.Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	msg.WriteString(base64.StdEncoding.EncodeToString([]byte(n.Content)))
+
+	addr := net.JoinHostPort(e.channel.Url, convertor.ToString(port))
+	auth := smtp.PlainAuth("", e.channel.Username, e.channel.Secret, e.channel.Url)
+	if port != 465 {
+		return smtp.SendMail(addr, auth, e.channel.Username, to, []byte(msg.String()))
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 15 * time.Second}, "tcp", addr, &tls.Config{ServerName: e.channel.Url})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, e.channel.Url)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err = client.Auth(auth); err != nil {
+		return err
+	}
+	if err = client.Mail(e.channel.Username); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err = client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// NtfyNotifier ntfy 推送,使用 JSON 方式发布以支持中文标题
+type NtfyNotifier struct {
+	channel NotifyChannel
+}
+
+func (t NtfyNotifier) Type() string {
+	return NotifyChannelNtfy
+}
+
+func (t NtfyNotifier) Send(n Notification) error {
+	server := strings.TrimSuffix(t.channel.Url, "/")
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+	request := notifyClient().R()
+	if t.channel.Secret != "" {
+		request.SetAuthToken(t.channel.Secret)
+	}
+	_, err := postJSON(request, server, map[string]any{
+		"topic":    t.channel.Target,
+		"title":    n.Title,
+		"message":  n.Content,
+		"markdown": true,
+		"tags":     []string{"chart_with_upwards_trend"},
+	})
+	return err
+}
+
+// WebhookNotifier 通用 JSON Webhook,配置密钥时在 X-Lumos-Signature 头中携带 HmacSHA256 签名(hex)
+type WebhookNotifier struct {
+	channel NotifyChannel
+}
+
+func (w WebhookNotifier) Type() string {
+	return NotifyChannelWebhook
+}
+
+func (w WebhookNotifier) Send(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	request := notifyClient().R()
+	if w.channel.Secret != "" {
+		request.SetHeader("X-Lumos-Signature", hex.EncodeToString(hmacSHA256([]byte(w.channel.Secret), body)))
+	}
+	_, err = postJSON(request, w.channel.Url, body)
+	return err
+}
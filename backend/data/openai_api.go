@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"lumos-stock/backend/db"
+	"lumos-stock/backend/events"
 	"lumos-stock/backend/logger"
 	"lumos-stock/backend/models"
 	"lumos-stock/backend/util"
@@ -23,7 +24,6 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/samber/lo"
 	"github.com/tidwall/gjson"
-	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // @Author spark
@@ -43,6 +43,8 @@ type OpenAi struct {
 	CrawlTimeOut     int64   `json:"crawl_time_out"`
 	KDays            int64   `json:"kDays"`
 	BrowserPath      string  `json:"browser_path"`
+	session          *ChatSession
+	recorder         *chatRecorder
 }
 
 func (o OpenAi) String() string {
@@ -601,10 +603,12 @@ func (o *OpenAi) NewChatStream(stock, stockCode, userQuestion string, sysPromptI
 		defer close(ch)
 
 		sysPrompt := ""
+		var contextSources []string
 		if sysPromptId == nil || *sysPromptId == 0 {
 			sysPrompt = o.Prompt
 		} else {
 			sysPrompt = NewPromptTemplateApi().GetPromptTemplateByID(*sysPromptId)
+			contextSources = NewPromptTemplateApi().GetPromptTemplateContextSources(*sysPromptId)
 		}
 		if sysPrompt == "" {
 			sysPrompt = o.Prompt
@@ -637,17 +641,6 @@ func (o *OpenAi) NewChatStream(stock, stockCode, userQuestion string, sysPromptI
 			"stockCode":     RemoveAllBlankChar(stockCode),
 		}
 		followedStock := NewStockDataApi().GetFollowedStockByStockCode(stockCode)
-		stockData, err := NewStockDataApi().GetStockCodeRealTimeData(stockCode)
-		if err == nil && len(*stockData) > 0 {
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": fmt.Sprintf("当前%s[%s]价格是多少？", stock, stockCode),
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": fmt.Sprintf("截止到%s,当前%s[%s]价格是%s", (*stockData)[0].Date+" "+(*stockData)[0].Time, stock, stockCode, (*stockData)[0].Price),
-			})
-		}
 		if followedStock.CostPrice > 0 {
 			replaceTemplates["{{costPrice}}"] = convertor.ToString(followedStock.CostPrice)
 			replaceTemplates["{costPrice}"] = convertor.ToString(followedStock.CostPrice)
@@ -665,288 +658,26 @@ func (o *OpenAi) NewChatStream(stock, stockCode, userQuestion string, sysPromptI
 		logger.SugaredLogger.Infof("NewChatStream stock:%s stockCode:%s", stock, stockCode)
 		logger.SugaredLogger.Infof("Prompt：%s", sysPrompt)
 		logger.SugaredLogger.Infof("final question:%s", question)
-		wg := &sync.WaitGroup{}
-		wg.Add(8)
-
-		go func() {
-			defer wg.Done()
-			datas := NewMarketNewsApi().InteractiveAnswer(1, 100, stock)
-			content := util.MarkdownTableWithTitle("当前最新投资者互动数据", datas.Results)
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": "投资者互动数据",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":              "assistant",
-				"reasoning_content": "使用工具查询",
-				"content":           content,
-			})
-		}()
-
-		go func() {
-			defer wg.Done()
-			var market strings.Builder
-			res := NewMarketNewsApi().GetGDP()
-			md := util.MarkdownTableWithTitle("国内生产总值(GDP)", res.GDPResult.Data)
-			market.WriteString(md)
-			res2 := NewMarketNewsApi().GetCPI()
-			md2 := util.MarkdownTableWithTitle("居民消费价格指数(CPI)", res2.CPIResult.Data)
-			market.WriteString(md2)
-			res3 := NewMarketNewsApi().GetPPI()
-			md3 := util.MarkdownTableWithTitle("工业品出厂价格指数(PPI)", res3.PPIResult.Data)
-			market.WriteString(md3)
-			res4 := NewMarketNewsApi().GetPMI()
-			md4 := util.MarkdownTableWithTitle("采购经理人指数(PMI)", res4.PMIResult.Data)
-			market.WriteString(md4)
-
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": "国内宏观经济数据",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":              "assistant",
-				"reasoning_content": "使用工具查询",
-				"content":           "\n# 国内宏观经济数据：\n" + market.String(),
-			})
-		}()
-
-		//go func() {
-		//	defer wg.Done()
-		//	var market strings.Builder
-		//	market.WriteString(GetZSInfo("上证指数", "sh000001", 5) + "\n")
-		//	market.WriteString(GetZSInfo("深证成指", "sz399001", 5) + "\n")
-		//	market.WriteString(GetZSInfo("创业板指数", "sz399006", 5) + "\n")
-		//	market.WriteString(GetZSInfo("科创50", "sh000688", 5) + "\n")
-		//	market.WriteString(GetZSInfo("沪深300指数", "sh000300", 5) + "\n")
-		//	market.WriteString(GetZSInfo("中证银行", "sz399986", 5) + "\n")
-		//	//market.WriteString(GetZSInfo("科创芯片", "sh000685", 30) + "\n")
-		//	//market.WriteString(GetZSInfo("上证医药", "sh000037", 30) + "\n")
-		//	//market.WriteString(GetZSInfo("证券龙头", "sz399437", 30) + "\n")
-		//	//market.WriteString(GetZSInfo("中证白酒", "sz399997", 30) + "\n")
-		//	//logger.SugaredLogger.Infof("NewChatStream getZSInfo=\n%s", market.String())
-		//	msg = append(msg, map[string]interface{}{
-		//		"role":    "user",
-		//		"content": "当前市场/大盘/行业/指数行情",
-		//	})
-		//	msg = append(msg, map[string]interface{}{
-		//		"role":              "assistant",
-		//		"reasoning_content": "使用工具查询",
-		//		"content":           "当前市场/大盘/行业/指数行情如下：\n" + market.String(),
-		//	})
-		//}()
 
-		go func() {
-			defer wg.Done()
-			md := strings.Builder{}
-			res := NewMarketNewsApi().ClsCalendar()
-			for _, a := range res {
-				bytes, err := json.Marshal(a)
-				if err != nil {
-					continue
-				}
-				//logger.SugaredLogger.Debugf("value: %+v", string(bytes))
-				date := gjson.Get(string(bytes), "calendar_day")
-				md.WriteString("\n### 事件/会议日期：" + date.String())
-				list := gjson.Get(string(bytes), "items")
-				//logger.SugaredLogger.Debugf("value: %+v,list: %+v", date.String(), list)
-				list.ForEach(func(key, value gjson.Result) bool {
-					logger.SugaredLogger.Debugf("key: %+v,value: %+v", key.String(), gjson.Get(value.String(), "title"))
-					md.WriteString("\n- " + gjson.Get(value.String(), "title").String())
-					return true
-				})
-			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": "近期重大事件/会议",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":              "assistant",
-				"reasoning_content": "使用工具查询",
-				"content":           "近期重大事件/会议如下：\n" + md.String(),
-			})
-
-		}()
-
-		go func() {
-			defer wg.Done()
-			//endDate := time.Now().Format("20060102")
-			//startDate := time.Now().Add(-time.Hour * time.Duration(24*o.KDays)).Format("20060102")
-			//code := stockCode
-			//if strutil.HasPrefixAny(stockCode, []string{"hk"}) {
-			//	code = ConvertStockCodeToTushareCode(stockCode)
-			//	K := NewTushareApi(GetConfig()).GetDaily(code, startDate, endDate, o.CrawlTimeOut)
-			//	msg = append(msg, map[string]interface{}{
-			//		"role":    "user",
-			//		"content": stock + "日K数据",
-			//	})
-			//	msg = append(msg, map[string]interface{}{
-			//		"role":    "assistant",
-			//		"content": stock + "日K数据如下：\n" + K,
-			//	})
-			//}
-
-			logger.SugaredLogger.Infof("NewChatStream getKLineData stock:%s stockCode:%s", stock, stockCode)
-			if strutil.HasPrefixAny(stockCode, []string{"sz", "sh", "hk", "us", "gb_"}) {
-				K := &[]KLineData{}
-				logger.SugaredLogger.Infof("NewChatStream getKLineData stock:%s stockCode:%s", stock, stockCode)
-				if strutil.HasPrefixAny(stockCode, []string{"sz", "sh"}) {
-					K = NewStockDataApi().GetKLineData(stockCode, "240", o.KDays)
-				}
-				if strutil.HasPrefixAny(stockCode, []string{"hk", "us", "gb_"}) {
-					K = NewStockDataApi().GetHK_KLineData(stockCode, "day", o.KDays)
-				}
-				Kmap := &[]map[string]any{}
-				for _, kline := range *K {
-					mapk := make(map[string]any, 6)
-					mapk["日期"] = kline.Day
-					mapk["开盘价"] = kline.Open
-					mapk["最高价"] = kline.High
-					mapk["最低价"] = kline.Low
-					mapk["收盘价"] = kline.Close
-					Volume, _ := convertor.ToFloat(kline.Volume)
-					mapk["成交量(万手)"] = Volume / 10000.00 / 100.00
-					*Kmap = append(*Kmap, mapk)
-				}
-				jsonData, _ := json.Marshal(Kmap)
-				markdownTable, _ := JSONToMarkdownTable(jsonData)
-				msg = append(msg, map[string]interface{}{
-					"role":    "user",
-					"content": stock + "日K数据",
-				})
-				msg = append(msg, map[string]interface{}{
-					"role":    "assistant",
-					"content": "## " + stock + "日K数据如下：\n" + markdownTable,
-				})
-				logger.SugaredLogger.Infof("getKLineData=\n%s", markdownTable)
-			}
-
-		}()
-
-		go func() {
-			defer wg.Done()
-			messages := SearchStockPriceInfo(stock, stockCode, o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取股票价格失败")
-				//ch <- "***❗获取股票价格失败,分析结果可能不准确***<hr>"
-				ch <- map[string]any{
-					"code":         1,
-					"question":     question,
-					"extraContent": "***❗获取股票价格失败,分析结果可能不准确***<hr>",
-				}
-				go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股票价格失败,分析结果可能不准确")
-				return
+		//各来源并发获取,按声明顺序合并,提示词模板未指定来源时使用全部来源
+		contextRequest := ChatContextRequest{Stock: stock, StockCode: stockCode, KDays: o.KDays, CrawlTimeOut: o.CrawlTimeOut}
+		results := NewChatContextBuilder().Build(contextRequest, contextSources)
+		for _, result := range results {
+			if result.Err == nil || result.Source.Warning == "" {
+				continue
 			}
-			price := ""
-			for _, message := range *messages {
-				price += message + ";"
+			ch <- map[string]any{
+				"code":         1,
+				"question":     question,
+				"extraContent": "***" + result.Source.Warning + "***<hr>",
 			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": stock + "股价数据",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": "\n## " + stock + "股价数据：\n" + price,
-			})
-			logger.SugaredLogger.Infof("SearchStockPriceInfo stock:%s stockCode:%s", stock, stockCode)
-			logger.SugaredLogger.Infof("SearchStockPriceInfo assistant:%s", "\n## "+stock+"股价数据：\n"+price)
-		}()
-
-		go func() {
-			defer wg.Done()
-
-			if checkIsIndexBasic(stock) {
-				return
-			}
-			messages := GetFinancialReportsByXUEQIU(stockCode, o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取股票财报失败")
-				// "***❗获取股票财报失败,分析结果可能不准确***<hr>"
-				ch <- map[string]any{
-					"code":         1,
-					"question":     question,
-					"extraContent": "***❗获取股票财报失败,分析结果可能不准确***<hr>",
-				}
-				go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股票财报失败,分析结果可能不准确")
-				return
-			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": stock + "财报数据",
-			})
-			for _, message := range *messages {
-				msg = append(msg, map[string]interface{}{
-					"role":    "assistant",
-					"content": stock + message,
-				})
-			}
-		}()
-
-		go func() {
-			defer wg.Done()
-			messages := NewMarketNewsApi().GetNews24HoursList("", random.RandInt(200, 1000))
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取市场资讯失败")
-				//ch <- "***❗获取市场资讯失败,分析结果可能不准确***<hr>"
-				//go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取市场资讯失败,分析结果可能不准确")
-				return
-			}
-			var messageText strings.Builder
-			for _, telegraph := range *messages {
-				messageText.WriteString("## " + telegraph.Time + ":" + "\n")
-				messageText.WriteString("### " + telegraph.Content + "\n")
-			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": "市场资讯",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": messageText.String(),
-			})
-		}()
-
-		//go func() {
-		//	defer wg.Done()
-		//	messages := SearchStockInfo(stock, "depth", o.CrawlTimeOut)
-		//	if messages == nil || len(*messages) == 0 {
-		//		logger.SugaredLogger.Error("获取股票资讯失败")
-		//		//ch <- "***❗获取股票资讯失败,分析结果可能不准确***<hr>"
-		//		//go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股票资讯失败,分析结果可能不准确")
-		//		return
-		//	}
-		//	for _, message := range *messages {
-		//		msg = append(msg, map[string]interface{}{
-		//			"role":    "user",
-		//			"content": message,
-		//		})
-		//	}
-		//}()
-		go func() {
-			defer wg.Done()
-			messages := SearchStockInfo(stock, "telegram", o.CrawlTimeOut)
-			if messages == nil || len(*messages) == 0 {
-				logger.SugaredLogger.Error("获取股票电报资讯失败")
-				//ch <- "***❗获取股票电报资讯失败,分析结果可能不准确***<hr>"
-				//go runtime.EventsEmit(o.ctx, "warnMsg", "❗获取股票电报资讯失败,分析结果可能不准确")
-				return
-			}
-			var newsText strings.Builder
-			for _, message := range *messages {
-				newsText.WriteString(message + "\n")
-			}
-			msg = append(msg, map[string]interface{}{
-				"role":    "user",
-				"content": stock + "相关新闻资讯",
-			})
-			msg = append(msg, map[string]interface{}{
-				"role":    "assistant",
-				"content": newsText.String(),
-			})
-		}()
-
-		wg.Wait()
+			TopicWarnMsg.Publish(events.FromContext(o.ctx), result.Source.Warning)
+		}
+		msg = append(msg, ChatContextMessages(contextRequest, results)...)
 
+		if o.session != nil {
+			msg = append(msg, ChatHistoryMessages(NewChatSessionApi().History(o.session.ID, ChatHistoryTokenBudget), len(tools) > 0)...)
+		}
 		msg = append(msg, map[string]interface{}{
 			"role":    "user",
 			"content": question,
@@ -955,9 +686,12 @@ func (o *OpenAi) NewChatStream(stock, stockCode, userQuestion string, sysPromptI
 		//reqJson, _ := json.Marshal(msg)
 		//logger.SugaredLogger.Errorf("Stream request: \n%s\n", reqJson)
 		if tools != nil && len(tools) > 0 {
-			AskAiWithTools(o, err, msg, ch, question, tools, thinking)
+			AskAiWithTools(o, nil, msg, ch, question, tools, thinking)
 		} else {
-			AskAi(o, err, msg, ch, question, thinking)
+			AskAi(o, nil, msg, ch, question, thinking)
+		}
+		if messages := o.recorder.result(); o.session != nil && messages != nil {
+			_ = NewChatSessionApi().AppendMessages(o.session.ID, append([]ChatMessage{{Role: ChatRoleUser, Content: question}}, messages...)...)
 		}
 	}()
 	return ch
@@ -1042,6 +776,7 @@ func AskAi(o *OpenAi, err error, messages []map[string]interface{}, ch chan map[
 					if content := choice.Delta.Content; content != "" {
 						//ch <- content
 						if content == "###" || content == "##" || content == "#" {
+							o.recorder.writeContent("\r\n" + content)
 							ch <- map[string]any{
 								"code":     1,
 								"question": question,
@@ -1051,6 +786,7 @@ func AskAi(o *OpenAi, err error, messages []map[string]interface{}, ch chan map[
 								"time":     time.Now().Format(time.DateTime),
 							}
 						} else {
+							o.recorder.writeContent(content)
 							ch <- map[string]any{
 								"code":     1,
 								"question": question,
@@ -1064,6 +800,7 @@ func AskAi(o *OpenAi, err error, messages []map[string]interface{}, ch chan map[
 						//logger.SugaredLogger.Infof("Content data: %s", content)
 					}
 					if reasoningContent := choice.Delta.ReasoningContent; reasoningContent != "" {
+						o.recorder.writeReasoning(reasoningContent)
 						//ch <- reasoningContent
 						ch <- map[string]any{
 							"code":     1,
@@ -1122,6 +859,8 @@ func AskAi(o *OpenAi, err error, messages []map[string]interface{}, ch chan map[
 	}
 }
 func AskAiWithTools(o *OpenAi, err error, messages []map[string]interface{}, ch chan map[string]any, question string, tools []Tool, thinkingMode bool) {
+	//本次请求之后追加的工具调用和结果
+	start := len(messages)
 	bytes, _ := json.Marshal(messages)
 	logger.SugaredLogger.Debugf("Stream request: \n%s\n", string(bytes))
 
@@ -1225,6 +964,7 @@ func AskAiWithTools(o *OpenAi, err error, messages []map[string]interface{}, ch
 
 						if content == "###" || content == "##" || content == "#" {
 							currentAIContent.WriteString("\r\n" + content)
+							o.recorder.writeContent("\r\n" + content)
 							ch <- map[string]any{
 								"code":     1,
 								"question": question,
@@ -1235,6 +975,7 @@ func AskAiWithTools(o *OpenAi, err error, messages []map[string]interface{}, ch
 							}
 						} else {
 							currentAIContent.WriteString(content)
+							o.recorder.writeContent(content)
 							ch <- map[string]any{
 								"code":     1,
 								"question": question,
@@ -1248,6 +989,7 @@ func AskAiWithTools(o *OpenAi, err error, messages []map[string]interface{}, ch
 					}
 					if reasoningContent := choice.Delta.ReasoningContent; reasoningContent != "" {
 						reasoningContentText.WriteString(reasoningContent)
+						o.recorder.writeReasoning(reasoningContent)
 						//ch <- reasoningContent
 						ch <- map[string]any{
 							"code":     1,
@@ -1384,13 +1126,7 @@ func AskAiWithTools(o *OpenAi, err error, messages []map[string]interface{}, ch
 								}
 
 								if strutil.HasPrefixAny(stockCode, []string{"sz", "sh", "hk", "us", "gb_"}) {
-									K := &[]KLineData{}
-									if strutil.HasPrefixAny(stockCode, []string{"sz", "sh"}) {
-										K = NewStockDataApi().GetKLineData(stockCode, "240", o.KDays)
-									}
-									if strutil.HasPrefixAny(stockCode, []string{"hk", "us", "gb_"}) {
-										K = NewStockDataApi().GetHK_KLineData(stockCode, "day", o.KDays)
-									}
+									K := NewKLineStoreApi().GetKLine(stockCode, KLinePeriodDay, o.KDays)
 									Kmap := &[]map[string]any{}
 									for _, kline := range *K {
 										mapk := make(map[string]any, 6)
@@ -1739,6 +1475,7 @@ func AskAiWithTools(o *OpenAi, err error, messages []map[string]interface{}, ch
 							}
 
 						}
+						o.recorder.writeToolMessages(messages[start:])
 						AskAiWithTools(o, err, messages, ch, question, tools, thinkingMode)
 					}
 
@@ -0,0 +1,412 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/mathutil"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/9/22 9:50
+// @Desc 持仓每日快照及组合收益分析:时间加权收益、资金加权收益、最大回撤、波动率、基准对比
+// -----------------------------------------------------------------------------------
+
+const (
+	DefaultBenchmarkCode = "sh000300"
+	tradingDaysPerYear   = 252
+)
+
+// PortfolioSnapshot 每个交易日收盘后的持仓快照,(day, stock_code) 唯一
+type PortfolioSnapshot struct {
+	gorm.Model
+	Day         string  `json:"day" gorm:"uniqueIndex:idx_portfolio_snapshot,priority:1"`
+	StockCode   string  `json:"stockCode" gorm:"uniqueIndex:idx_portfolio_snapshot,priority:2"`
+	StockName   string  `json:"stockName"`
+	Currency    string  `json:"currency"`
+	FxRate      float64 `json:"fxRate"` //1单位交易货币兑人民币
+	Price       float64 `json:"price"`
+	Volume      int64   `json:"volume"`
+	MarketValue float64 `json:"marketValue"`
+	CostAmount  float64 `json:"costAmount"`
+	Flow        float64 `json:"flow"` //当日净投入:买入金额减卖出金额和分红
+}
+
+func (PortfolioSnapshot) TableName() string {
+	return "portfolio_snapshot"
+}
+
+// PortfolioDailyValue 组合每日市值(基础货币)
+type PortfolioDailyValue struct {
+	Day         string  `json:"day" md:"日期"`
+	MarketValue float64 `json:"marketValue" md:"市值"`
+	CostAmount  float64 `json:"costAmount" md:"成本"`
+	Flow        float64 `json:"flow" md:"净投入"`
+	DailyReturn float64 `json:"dailyReturn" md:"日收益率(%)"`
+	CumReturn   float64 `json:"cumReturn" md:"累计收益率(%)"`
+	Drawdown    float64 `json:"drawdown" md:"回撤(%)"`
+	Benchmark   float64 `json:"benchmark" md:"基准累计收益率(%)"`
+}
+
+// PortfolioPerformance 组合收益分析,收益率单位均为%
+type PortfolioPerformance struct {
+	BaseCurrency        string                `json:"baseCurrency"`
+	StartDay            string                `json:"startDay"`
+	EndDay              string                `json:"endDay"`
+	MarketValue         float64               `json:"marketValue"`
+	CostAmount          float64               `json:"costAmount"`
+	TimeWeightedReturn  float64               `json:"timeWeightedReturn"`
+	MoneyWeightedReturn float64               `json:"moneyWeightedReturn"` //年化内部收益率
+	MaxDrawdown         float64               `json:"maxDrawdown"`
+	Volatility          float64               `json:"volatility"` //年化波动率
+	BenchmarkCode       string                `json:"benchmarkCode"`
+	BenchmarkReturn     float64               `json:"benchmarkReturn"`
+	ExcessReturn        float64               `json:"excessReturn"`
+	Daily               []PortfolioDailyValue `json:"daily"`
+}
+
+type PortfolioApi struct {
+}
+
+func NewPortfolioApi() *PortfolioApi {
+	return &PortfolioApi{}
+}
+
+// TakeSnapshot 记录当日所有持仓(含当日清仓)的快照,返回记录的数量;
+// 美股此时尚未开盘,先按上一交易日收盘价记录,美股收盘后由 TakeUSSnapshot 更新
+func (p PortfolioApi) TakeSnapshot() (int, error) {
+	return p.takeSnapshot(time.Now().Format(time.DateOnly), func(code string) bool {
+		return true
+	})
+}
+
+// TakeUSSnapshot 美股收盘后记录美股持仓的快照,day 为美东日期
+func (p PortfolioApi) TakeUSSnapshot(day string) (int, error) {
+	return p.takeSnapshot(day, func(code string) bool {
+		return CurrencyOf(code) == CurrencyUSD
+	})
+}
+
+func (p PortfolioApi) takeSnapshot(day string, include func(code string) bool) (int, error) {
+	var codes []string
+	db.Dao.Model(&StockTrade{}).Distinct("stock_code").Pluck("stock_code", &codes)
+	codes = lo.Filter(codes, func(code string, _ int) bool {
+		return include(code)
+	})
+	if len(codes) == 0 {
+		return 0, nil
+	}
+	ledger := NewTradeLedgerApi()
+	fx := NewFxRateApi()
+	names := map[string]string{}
+	var follows []FollowedStock
+	db.Dao.Model(&FollowedStock{}).Find(&follows)
+	for _, follow := range follows {
+		names[follow.StockCode] = follow.Name
+	}
+
+	snapshots := make([]PortfolioSnapshot, 0, len(codes))
+	holdings := make([]string, 0, len(codes))
+	for _, code := range codes {
+		trades := ledger.GetTrades(code)
+		position := CalcPosition(trades, CostMethodAverage)
+		flow := DailyFlow(trades, day)
+		if position.Volume == 0 && flow == 0 {
+			continue
+		}
+		currency := CurrencyOf(code)
+		snapshots = append(snapshots, PortfolioSnapshot{
+			Day:        day,
+			StockCode:  code,
+			StockName:  names[code],
+			Currency:   currency,
+			FxRate:     fx.GetRate(currency, CurrencyCNY),
+			Volume:     position.Volume,
+			CostAmount: position.CostAmount,
+			Flow:       flow,
+		})
+		if position.Volume > 0 {
+			holdings = append(holdings, code)
+		}
+	}
+	snapshots = p.applyPrices(snapshots, p.closePrices(holdings))
+	if len(snapshots) == 0 {
+		return 0, nil
+	}
+	err := db.Dao.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}, {Name: "stock_code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"stock_name", "currency", "fx_rate", "price", "volume", "market_value", "cost_amount", "flow", "updated_at"}),
+	}).Create(&snapshots).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("save portfolio snapshot error:%s", err.Error())
+		return 0, err
+	}
+	logger.SugaredLogger.Infof("portfolio snapshot %s holdings:%d", day, len(snapshots))
+	return len(snapshots), nil
+}
+
+// applyPrices 按收盘价计算市值;取不到收盘价时沿用之前最近一次快照的价格,仍没有价格的持仓不记录,避免市值记为 0
+func (p PortfolioApi) applyPrices(snapshots []PortfolioSnapshot, prices map[string]float64) []PortfolioSnapshot {
+	res := make([]PortfolioSnapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		s.Price = prices[s.StockCode]
+		if s.Price <= 0 && s.Volume > 0 {
+			var last PortfolioSnapshot
+			db.Dao.Model(&PortfolioSnapshot{}).Where("stock_code = ? and day < ? and price > 0", s.StockCode, s.Day).
+				Order("day desc").Limit(1).Find(&last)
+			s.Price = last.Price
+		}
+		if s.Price <= 0 && s.Volume > 0 {
+			logger.SugaredLogger.Warnf("portfolio snapshot %s %s 没有收盘价,不记录", s.Day, s.StockCode)
+			continue
+		}
+		s.MarketValue = mathutil.RoundToFloat(s.Price*float64(s.Volume), 2)
+		res = append(res, s)
+	}
+	return res
+}
+
+// closePrices 收盘价,实时行情取不到时使用本地日K线最后收盘价
+func (p PortfolioApi) closePrices(codes []string) map[string]float64 {
+	prices := make(map[string]float64, len(codes))
+	if len(codes) == 0 {
+		return prices
+	}
+	infos, _ := NewStockDataApi().GetStockCodeRealTimeData(codes...)
+	if infos != nil {
+		for _, info := range *infos {
+			price, _ := convertor.ToFloat(info.Price)
+			if price == 0 {
+				price, _ = convertor.ToFloat(info.PreClose)
+			}
+			for _, code := range codes {
+				if quoteCodeEqual(code, info.Code) && price > 0 {
+					prices[code] = price
+				}
+			}
+		}
+	}
+	for _, code := range codes {
+		if prices[code] > 0 {
+			continue
+		}
+		K := NewKLineStoreApi().QueryKLine(code, KLinePeriodDay, 1)
+		if len(*K) > 0 {
+			prices[code], _ = convertor.ToFloat((*K)[0].Close)
+		}
+	}
+	return prices
+}
+
+func (p PortfolioApi) GetSnapshots(startDay, endDay string) []PortfolioSnapshot {
+	var snapshots []PortfolioSnapshot
+	query := db.Dao.Model(&PortfolioSnapshot{})
+	if startDay != "" {
+		query = query.Where("day >= ?", startDay)
+	}
+	if endDay != "" {
+		query = query.Where("day <= ?", endDay)
+	}
+	query.Order("day asc, stock_code asc").Find(&snapshots)
+	return snapshots
+}
+
+// GetPerformance 最近 days 个自然日的组合收益分析,benchmark 为空时使用沪深300
+func (p PortfolioApi) GetPerformance(days int, benchmark string) *PortfolioPerformance {
+	if days <= 0 {
+		days = 365
+	}
+	if benchmark == "" {
+		benchmark = DefaultBenchmarkCode
+	}
+	startDay := time.Now().AddDate(0, 0, -days).Format(time.DateOnly)
+	baseCurrency := GetBaseCurrency()
+	baseRate := NewFxRateApi().GetRate(baseCurrency, CurrencyCNY)
+	daily := AggregateSnapshots(p.GetSnapshots(startDay, ""), baseRate)
+
+	var benchmarkCloses map[string]float64
+	if len(daily) > 0 {
+		K := NewKLineStoreApi().GetKLine(benchmark, KLinePeriodDay, int64(days))
+		benchmarkCloses = make(map[string]float64, len(*K))
+		for _, k := range *K {
+			benchmarkCloses[k.Day], _ = convertor.ToFloat(k.Close)
+		}
+	}
+	performance := CalcPerformance(daily, benchmarkCloses)
+	performance.BaseCurrency = baseCurrency
+	performance.BenchmarkCode = benchmark
+	return performance
+}
+
+// DailyFlow 指定日期的净投入,买入为正,卖出和分红为负
+func DailyFlow(trades []StockTrade, day string) float64 {
+	flow := 0.0
+	for _, trade := range trades {
+		if trade.TradeTime.Format(time.DateOnly) != day {
+			continue
+		}
+		switch trade.TradeType {
+		case TradeTypeBuy:
+			flow += trade.Price*float64(trade.Volume) + trade.Fee
+		case TradeTypeSell:
+			flow -= trade.Price*float64(trade.Volume) - trade.Fee
+		case TradeTypeDividend:
+			flow -= trade.Amount - trade.Fee
+		}
+	}
+	return mathutil.RoundToFloat(flow, 2)
+}
+
+// AggregateSnapshots 按日期汇总快照,金额换算为基础货币,baseRate 为1单位基础货币兑人民币
+func AggregateSnapshots(snapshots []PortfolioSnapshot, baseRate float64) []PortfolioDailyValue {
+	if baseRate <= 0 {
+		baseRate = 1
+	}
+	byDay := map[string]*PortfolioDailyValue{}
+	for _, s := range snapshots {
+		v, ok := byDay[s.Day]
+		if !ok {
+			v = &PortfolioDailyValue{Day: s.Day}
+			byDay[s.Day] = v
+		}
+		rate := s.FxRate
+		if rate <= 0 {
+			rate = 1
+		}
+		rate /= baseRate
+		v.MarketValue += s.MarketValue * rate
+		v.CostAmount += s.CostAmount * rate
+		v.Flow += s.Flow * rate
+	}
+	daily := make([]PortfolioDailyValue, 0, len(byDay))
+	for _, v := range byDay {
+		v.MarketValue = mathutil.RoundToFloat(v.MarketValue, 2)
+		v.CostAmount = mathutil.RoundToFloat(v.CostAmount, 2)
+		v.Flow = mathutil.RoundToFloat(v.Flow, 2)
+		daily = append(daily, *v)
+	}
+	sort.Slice(daily, func(i, j int) bool {
+		return daily[i].Day < daily[j].Day
+	})
+	return daily
+}
+
+// CalcPerformance 计算收益指标,benchmarkCloses 为基准指数每日收盘价
+func CalcPerformance(daily []PortfolioDailyValue, benchmarkCloses map[string]float64) *PortfolioPerformance {
+	performance := &PortfolioPerformance{Daily: daily}
+	if len(daily) == 0 {
+		return performance
+	}
+	performance.StartDay = daily[0].Day
+	performance.EndDay = daily[len(daily)-1].Day
+	performance.MarketValue = daily[len(daily)-1].MarketValue
+	performance.CostAmount = daily[len(daily)-1].CostAmount
+
+	//时间加权收益:剔除当日资金进出后的日收益率连乘
+	returns := make([]float64, 0, len(daily))
+	growth, peak := 1.0, 1.0
+	benchmarkStart := benchmarkCloses[daily[0].Day]
+	for i := range daily {
+		if i > 0 && daily[i-1].MarketValue > 0 {
+			r := (daily[i].MarketValue-daily[i].Flow)/daily[i-1].MarketValue - 1
+			returns = append(returns, r)
+			growth *= 1 + r
+			daily[i].DailyReturn = mathutil.RoundToFloat(r*100, 3)
+		}
+		peak = math.Max(peak, growth)
+		daily[i].CumReturn = mathutil.RoundToFloat((growth-1)*100, 3)
+		daily[i].Drawdown = mathutil.RoundToFloat((peak-growth)/peak*100, 3)
+		performance.MaxDrawdown = math.Max(performance.MaxDrawdown, daily[i].Drawdown)
+		if closePrice := benchmarkCloses[daily[i].Day]; benchmarkStart > 0 && closePrice > 0 {
+			daily[i].Benchmark = mathutil.RoundToFloat((closePrice/benchmarkStart-1)*100, 3)
+			performance.BenchmarkReturn = daily[i].Benchmark
+		}
+	}
+	performance.TimeWeightedReturn = mathutil.RoundToFloat((growth-1)*100, 3)
+	performance.ExcessReturn = mathutil.RoundToFloat(performance.TimeWeightedReturn-performance.BenchmarkReturn, 3)
+	performance.Volatility = mathutil.RoundToFloat(stdDev(returns)*math.Sqrt(tradingDaysPerYear)*100, 3)
+
+	//资金加权收益:期初市值和每日净投入视为投入,期末市值视为收回
+	dates := make([]time.Time, 0, len(daily)+1)
+	flows := make([]float64, 0, len(daily)+1)
+	for i, v := range daily {
+		day, err := time.Parse(time.DateOnly, v.Day)
+		if err != nil {
+			continue
+		}
+		flow := -v.Flow
+		if i == 0 {
+			flow = -v.MarketValue
+		}
+		if i == len(daily)-1 {
+			flow += v.MarketValue
+		}
+		dates = append(dates, day)
+		flows = append(flows, flow)
+	}
+	if irr, ok := XIRR(dates, flows); ok {
+		performance.MoneyWeightedReturn = mathutil.RoundToFloat(irr*100, 3)
+	}
+	return performance
+}
+
+// XIRR 不定期现金流的年化内部收益率,二分法求解
+func XIRR(dates []time.Time, flows []float64) (float64, bool) {
+	if len(dates) < 2 || len(dates) != len(flows) {
+		return 0, false
+	}
+	npv := func(rate float64) float64 {
+		sum := 0.0
+		for i, flow := range flows {
+			years := dates[i].Sub(dates[0]).Hours() / 24 / 365
+			sum += flow / math.Pow(1+rate, years)
+		}
+		return sum
+	}
+	low, high := -0.9999, 10.0
+	fLow, fHigh := npv(low), npv(high)
+	//区间较短时年化收益可能很大,逐步扩大上界
+	for fLow*fHigh > 0 && high < 1e9 {
+		high *= 10
+		fHigh = npv(high)
+	}
+	if math.IsNaN(fLow) || math.IsNaN(fHigh) || fLow*fHigh > 0 {
+		return 0, false
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		fMid := npv(mid)
+		if math.Abs(fMid) < 1e-7 {
+			return mid, true
+		}
+		if fLow*fMid < 0 {
+			high = mid
+		} else {
+			low, fLow = mid, fMid
+		}
+	}
+	return (low + high) / 2, true
+}
+
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)-1))
+}
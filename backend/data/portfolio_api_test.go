@@ -0,0 +1,90 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/22 15:00
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestDailyFlow(t *testing.T) {
+	day := time.Date(2025, 9, 1, 10, 0, 0, 0, time.Local)
+	trades := []StockTrade{
+		{TradeType: TradeTypeBuy, TradeTime: day, Price: 10, Volume: 100, Fee: 5},
+		{TradeType: TradeTypeSell, TradeTime: day, Price: 11, Volume: 50, Fee: 5},
+		{TradeType: TradeTypeDividend, TradeTime: day, Amount: 20},
+		{TradeType: TradeTypeBuy, TradeTime: day.AddDate(0, 0, 1), Price: 10, Volume: 100},
+	}
+	if flow := DailyFlow(trades, "2025-09-01"); flow != 1005-545-20 {
+		t.Errorf("unexpected flow %v", flow)
+	}
+}
+
+func TestCalcPerformance(t *testing.T) {
+	snapshots := []PortfolioSnapshot{
+		{Day: "2025-09-01", StockCode: "sh600000", FxRate: 1, MarketValue: 10000, Flow: 10000},
+		{Day: "2025-09-01", StockCode: "hk00700", FxRate: 0.9, MarketValue: 0},
+		{Day: "2025-09-02", StockCode: "sh600000", FxRate: 1, MarketValue: 11000},
+		// 追加投入 10000 后下跌
+		{Day: "2025-09-03", StockCode: "sh600000", FxRate: 1, MarketValue: 19800, Flow: 10000},
+		{Day: "2025-09-04", StockCode: "sh600000", FxRate: 1, MarketValue: 20790},
+	}
+	daily := AggregateSnapshots(snapshots, 1)
+	if len(daily) != 4 {
+		t.Fatalf("unexpected daily %+v", daily)
+	}
+	perf := CalcPerformance(daily, map[string]float64{"2025-09-01": 4000, "2025-09-04": 4200})
+	// 1.1 * (9800/11000) * 1.05 - 1
+	want := (1.1*(9800.0/11000)*1.05 - 1) * 100
+	if math.Abs(perf.TimeWeightedReturn-want) > 0.001 {
+		t.Errorf("twr got %v want %v", perf.TimeWeightedReturn, want)
+	}
+	if perf.BenchmarkReturn != 5 || math.Abs(perf.ExcessReturn-(want-5)) > 0.001 {
+		t.Errorf("unexpected benchmark %+v", perf)
+	}
+	if math.Abs(perf.MaxDrawdown-(1-9800.0/11000)*100) > 0.001 {
+		t.Errorf("unexpected drawdown %v", perf.MaxDrawdown)
+	}
+	if perf.MoneyWeightedReturn <= 0 || perf.Volatility <= 0 {
+		t.Errorf("unexpected mwr/volatility %+v", perf)
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	irr, ok := XIRR([]time.Time{start, start.AddDate(0, 0, 365)}, []float64{-1000, 1100})
+	if !ok || math.Abs(irr-0.1) > 1e-5 {
+		t.Errorf("unexpected irr %v %v", irr, ok)
+	}
+}
+
+func TestApplySnapshotPrices(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "portfolio.db"))
+	db.Dao.AutoMigrate(&PortfolioSnapshot{})
+	db.Dao.Create(&PortfolioSnapshot{Day: "2025-09-01", StockCode: "hk00700", Price: 600, Volume: 100})
+
+	snapshots := NewPortfolioApi().applyPrices([]PortfolioSnapshot{
+		{Day: "2025-09-02", StockCode: "sh600000", Volume: 1000},
+		{Day: "2025-09-02", StockCode: "hk00700", Volume: 100},
+		{Day: "2025-09-02", StockCode: "gb_aapl", Volume: 10},
+		{Day: "2025-09-02", StockCode: "sz000001", Volume: 0, Flow: -1000},
+	}, map[string]float64{"sh600000": 10.5})
+	if len(snapshots) != 3 {
+		t.Fatalf("holding without any price should be skipped %+v", snapshots)
+	}
+	if snapshots[0].MarketValue != 10500 {
+		t.Errorf("unexpected market value %+v", snapshots[0])
+	}
+	if snapshots[1].Price != 600 || snapshots[1].MarketValue != 60000 {
+		t.Errorf("previous snapshot price should be carried forward %+v", snapshots[1])
+	}
+	if snapshots[2].StockCode != "sz000001" || snapshots[2].MarketValue != 0 {
+		t.Errorf("closed position should be kept %+v", snapshots[2])
+	}
+}
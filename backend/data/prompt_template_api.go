@@ -31,9 +31,10 @@ func (t PromptTemplateApi) AddPrompt(template models.PromptTemplate) string {
 	db.Dao.Model(&models.PromptTemplate{}).Where("id=?", template.ID).First(&tmp)
 	if tmp.ID == 0 {
 		err := db.Dao.Model(&models.PromptTemplate{}).Create(&models.PromptTemplate{
-			Content: template.Content,
-			Name:    template.Name,
-			Type:    template.Type,
+			Content:        template.Content,
+			Name:           template.Name,
+			Type:           template.Type,
+			ContextSources: template.ContextSources,
 		}).Error
 		if err != nil {
 			return "添加失败"
@@ -41,7 +42,8 @@ func (t PromptTemplateApi) AddPrompt(template models.PromptTemplate) string {
 			return "添加成功"
 		}
 	} else {
-		err := db.Dao.Model(&models.PromptTemplate{}).Where("id=?", template.ID).Updates(template).Error
+		//Updates 不更新零值,清空上下文来源需要单独更新
+		err := db.Dao.Model(&models.PromptTemplate{}).Where("id=?", template.ID).Updates(template).Update("context_sources", template.ContextSources).Error
 		if err != nil {
 			return "更新失败"
 		} else {
@@ -70,6 +72,18 @@ func (t PromptTemplateApi) GetPromptTemplateByID(id int) string {
 	logger.SugaredLogger.Infof("GetPromptTemplateByID:%d %s", id, prompt.Content)
 	return prompt.Content
 }
+
+// GetPromptTemplateContextSources 提示词模板选择的上下文来源,未选择时返回 nil 表示使用全部来源
+func (t PromptTemplateApi) GetPromptTemplateContextSources(id int) []string {
+	prompt := &models.PromptTemplate{}
+	db.Dao.Model(&models.PromptTemplate{}).Where("id=?", id).First(prompt)
+	sources := ParseChatContextSources(prompt.ContextSources)
+	if len(sources) == 0 {
+		return nil
+	}
+	return sources
+}
+
 func NewPromptTemplateApi() *PromptTemplateApi {
 	return &PromptTemplateApi{}
 }
@@ -0,0 +1,312 @@
+package data
+
+import (
+	"fmt"
+	"lumos-stock/backend/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/go-resty/resty/v2"
+)
+
+// @Author spark
+// @Date 2025/9/4 14:20
+// @Desc 实时行情数据源,按市场优先级获取行情,数据源异常时自动切换
+// -----------------------------------------------------------------------------------
+
+const (
+	QuoteMarketA     = "a"
+	QuoteMarketHK    = "hk"
+	QuoteMarketUS    = "us"
+	QuoteMarketOther = "other"
+
+	QuoteProviderSina    = "sina"
+	QuoteProviderTencent = "tencent"
+)
+
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0"
+
+// QuoteProvider 实时行情数据源
+type QuoteProvider interface {
+	Name() string
+	// Supports 是否支持该市场
+	Supports(market string) bool
+	// Fetch 批量获取行情,stockCodes 为关注列表中的代码(sh/sz/hk/us 开头)
+	Fetch(client *resty.Client, stockCodes []string) ([]StockInfo, error)
+}
+
+// SinaQuoteProvider 新浪行情 hq.sinajs.cn
+type SinaQuoteProvider struct {
+}
+
+func (p SinaQuoteProvider) Name() string {
+	return QuoteProviderSina
+}
+
+func (p SinaQuoteProvider) Supports(market string) bool {
+	return true
+}
+
+func (p SinaQuoteProvider) Fetch(client *resty.Client, stockCodes []string) ([]StockInfo, error) {
+	codes := slice.JoinFunc(stockCodes, ",", func(s string) string {
+		if strings.HasPrefix(s, "us") {
+			s = strings.Replace(s, "us", "gb_", 1)
+		}
+		if strings.HasPrefix(s, "US") {
+			s = strings.Replace(s, "US", "gb_", 1)
+		}
+		return strings.ToLower(s)
+	})
+	url := fmt.Sprintf(sinaStockUrl, time.Now().Unix(), codes)
+	resp, err := client.R().
+		SetHeader("Host", "hq.sinajs.cn").
+		SetHeader("Referer", "https://finance.sina.com.cn/").
+		SetHeader("User-Agent", userAgent).
+		Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("sina quote status:%d", resp.StatusCode())
+	}
+	str := GB18030ToUTF8(resp.Body())
+	stockInfos := make([]StockInfo, 0)
+	for _, data := range strutil.SplitEx(str, "\n", true) {
+		stockData, err := ParseFullSingleStockData(data)
+		if err != nil {
+			logger.SugaredLogger.Error(err.Error())
+			continue
+		}
+		if stockData == nil || stockData.Code == "" {
+			continue
+		}
+		stockInfos = append(stockInfos, *stockData)
+	}
+	return stockInfos, nil
+}
+
+// TencentQuoteProvider 腾讯行情 qt.gtimg.cn,支持A股和港股
+type TencentQuoteProvider struct {
+}
+
+func (p TencentQuoteProvider) Name() string {
+	return QuoteProviderTencent
+}
+
+func (p TencentQuoteProvider) Supports(market string) bool {
+	return market == QuoteMarketA || market == QuoteMarketHK
+}
+
+func (p TencentQuoteProvider) Fetch(client *resty.Client, stockCodes []string) ([]StockInfo, error) {
+	codes := slice.JoinFunc(stockCodes, ",", func(s string) string {
+		if strutil.HasPrefixAny(s, []string{"hk", "HK"}) {
+			return "r_" + strings.ToLower(s)
+		}
+		return strings.ToLower(s)
+	})
+	url := fmt.Sprintf(txStockUrl, time.Now().Unix(), codes)
+	resp, err := client.R().
+		SetHeader("Host", "qt.gtimg.cn").
+		SetHeader("Referer", "https://gu.qq.com/").
+		SetHeader("User-Agent", userAgent).
+		Get(url)
+	logger.SugaredLogger.Infof("GetStockCodeRealTimeData %s", url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("tencent quote status:%d", resp.StatusCode())
+	}
+	str := GB18030ToUTF8(resp.Body())
+	stockInfos := make([]StockInfo, 0)
+	for _, data := range strutil.SplitAndTrim(strings.Trim(str, "\n"), ";") {
+		stockData, err := ParseTxStockData(data)
+		if err != nil {
+			logger.SugaredLogger.Error(err.Error())
+			continue
+		}
+		if stockData == nil || stockData.Code == "" {
+			continue
+		}
+		stockInfos = append(stockInfos, *stockData)
+	}
+	return stockInfos, nil
+}
+
+// quoteProviderHealth 数据源健康状态,连续失败后在一段时间内降低优先级
+type quoteProviderHealth struct {
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"lastError"`
+	LastFailure time.Time `json:"lastFailure"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	DownUntil   time.Time `json:"downUntil"`
+}
+
+type QuoteProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]QuoteProvider
+	priority  map[string][]string
+	health    map[string]*quoteProviderHealth
+}
+
+var quoteProviders = NewQuoteProviderRegistry()
+
+func NewQuoteProviderRegistry() *QuoteProviderRegistry {
+	r := &QuoteProviderRegistry{
+		providers: map[string]QuoteProvider{},
+		health:    map[string]*quoteProviderHealth{},
+		priority: map[string][]string{
+			QuoteMarketA:     {QuoteProviderTencent, QuoteProviderSina},
+			QuoteMarketHK:    {QuoteProviderTencent, QuoteProviderSina},
+			QuoteMarketUS:    {QuoteProviderSina},
+			QuoteMarketOther: {QuoteProviderSina},
+		},
+	}
+	r.Register(SinaQuoteProvider{})
+	r.Register(TencentQuoteProvider{})
+	return r
+}
+
+func GetQuoteProviderRegistry() *QuoteProviderRegistry {
+	return quoteProviders
+}
+
+func (r *QuoteProviderRegistry) Register(provider QuoteProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.Name()] = provider
+	if _, ok := r.health[provider.Name()]; !ok {
+		r.health[provider.Name()] = &quoteProviderHealth{}
+	}
+}
+
+// SetPriority 设置市场的数据源优先级
+func (r *QuoteProviderRegistry) SetPriority(market string, names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.priority[market] = names
+}
+
+// Status 数据源健康状态
+func (r *QuoteProviderRegistry) Status() map[string]quoteProviderHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make(map[string]quoteProviderHealth, len(r.health))
+	for name, h := range r.health {
+		res[name] = *h
+	}
+	return res
+}
+
+// ordered 返回市场可用的数据源,健康的排在前面,全部异常时仍按优先级返回
+func (r *QuoteProviderRegistry) ordered(market string, now time.Time) []QuoteProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var healthy, down []QuoteProvider
+	for _, name := range r.priority[market] {
+		provider, ok := r.providers[name]
+		if !ok || !provider.Supports(market) {
+			continue
+		}
+		if h := r.health[name]; h != nil && now.Before(h.DownUntil) {
+			down = append(down, provider)
+			continue
+		}
+		healthy = append(healthy, provider)
+	}
+	return append(healthy, down...)
+}
+
+func (r *QuoteProviderRegistry) markSuccess(name string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.health[name]
+	h.Failures = 0
+	h.LastSuccess = now
+	h.DownUntil = time.Time{}
+}
+
+// markFailure 连续失败次数越多,降级时间越长,最长5分钟
+func (r *QuoteProviderRegistry) markFailure(name string, err error, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.health[name]
+	h.Failures++
+	h.LastFailure = now
+	h.LastError = err.Error()
+	backoff := time.Duration(h.Failures*30) * time.Second
+	if backoff > 5*time.Minute {
+		backoff = 5 * time.Minute
+	}
+	h.DownUntil = now.Add(backoff)
+}
+
+// Fetch 按市场分组获取行情,数据源失败或缺失的代码交由下一个数据源补充
+func (r *QuoteProviderRegistry) Fetch(client *resty.Client, stockCodes []string) ([]StockInfo, error) {
+	stockInfos := make([]StockInfo, 0, len(stockCodes))
+	var lastErr error
+	for _, market := range []string{QuoteMarketA, QuoteMarketHK, QuoteMarketUS, QuoteMarketOther} {
+		remaining := slice.Filter(stockCodes, func(i int, s string) bool {
+			return GetQuoteMarket(s) == market
+		})
+		for _, provider := range r.ordered(market, time.Now()) {
+			if len(remaining) == 0 {
+				break
+			}
+			infos, err := provider.Fetch(client, remaining)
+			if err == nil && len(infos) == 0 {
+				err = fmt.Errorf("%s 未返回行情数据", provider.Name())
+			}
+			if err != nil {
+				logger.SugaredLogger.Errorf("quote provider %s error:%s", provider.Name(), err.Error())
+				r.markFailure(provider.Name(), err, time.Now())
+				lastErr = err
+				continue
+			}
+			r.markSuccess(provider.Name(), time.Now())
+			stockInfos = append(stockInfos, infos...)
+			remaining = slice.Filter(remaining, func(i int, code string) bool {
+				return !slice.ContainBy(infos, func(info StockInfo) bool {
+					return quoteCodeEqual(code, info.Code)
+				})
+			})
+		}
+	}
+	for i := range stockInfos {
+		stockInfos[i].Currency = CurrencyOf(stockInfos[i].Code)
+	}
+	if len(stockInfos) == 0 && lastErr != nil {
+		return stockInfos, lastErr
+	}
+	return stockInfos, nil
+}
+
+// GetQuoteMarket 根据股票代码判断所属市场
+func GetQuoteMarket(stockCode string) string {
+	code := strings.ToLower(stockCode)
+	switch {
+	case strutil.HasPrefixAny(code, []string{"sh", "sz"}):
+		return QuoteMarketA
+	case strings.HasPrefix(code, "hk"):
+		return QuoteMarketHK
+	case strutil.HasPrefixAny(code, []string{"us", "gb_"}):
+		return QuoteMarketUS
+	default:
+		return QuoteMarketOther
+	}
+}
+
+// quoteCodeEqual 关注列表代码与行情返回代码比较(us 与 gb_ 视为同一代码)
+func quoteCodeEqual(stockCode, quoteCode string) bool {
+	normalize := func(s string) string {
+		s = strings.ToLower(s)
+		if strings.HasPrefix(s, "us") {
+			s = strings.Replace(s, "us", "gb_", 1)
+		}
+		return s
+	}
+	return normalize(stockCode) == normalize(quoteCode)
+}
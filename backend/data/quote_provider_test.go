@@ -0,0 +1,93 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// @Author spark
+// @Date 2025/9/4 16:02
+// @Desc
+//-----------------------------------------------------------------------------------
+
+type fakeQuoteProvider struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (f *fakeQuoteProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeQuoteProvider) Supports(market string) bool {
+	return true
+}
+
+func (f *fakeQuoteProvider) Fetch(client *resty.Client, stockCodes []string) ([]StockInfo, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	infos := make([]StockInfo, 0, len(stockCodes))
+	for _, code := range stockCodes {
+		infos = append(infos, StockInfo{Code: code, Name: f.name})
+	}
+	return infos, nil
+}
+
+func TestQuoteProviderFailover(t *testing.T) {
+	primary := &fakeQuoteProvider{name: "primary", err: errors.New("timeout")}
+	backup := &fakeQuoteProvider{name: "backup"}
+	registry := &QuoteProviderRegistry{
+		providers: map[string]QuoteProvider{},
+		health:    map[string]*quoteProviderHealth{},
+		priority:  map[string][]string{QuoteMarketA: {"primary", "backup"}},
+	}
+	registry.Register(primary)
+	registry.Register(backup)
+
+	infos, err := registry.Fetch(nil, []string{"sh600000", "sz000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Name != "backup" {
+		t.Fatalf("expected backup quotes, got %+v", infos)
+	}
+	if registry.Status()["primary"].Failures != 1 {
+		t.Errorf("primary failure should be recorded")
+	}
+
+	// 降级期间优先使用健康的数据源
+	primary.err = nil
+	registry.Fetch(nil, []string{"sh600000"})
+	if primary.calls != 1 {
+		t.Errorf("primary should be skipped while down, calls=%d", primary.calls)
+	}
+	order := registry.ordered(QuoteMarketA, time.Now().Add(time.Hour))
+	if order[0].Name() != "primary" {
+		t.Errorf("primary should recover after backoff")
+	}
+}
+
+func TestGetQuoteMarket(t *testing.T) {
+	cases := map[string]string{
+		"sh600000": QuoteMarketA,
+		"SZ000001": QuoteMarketA,
+		"hk00700":  QuoteMarketHK,
+		"usAAPL":   QuoteMarketUS,
+		"gb_aapl":  QuoteMarketUS,
+		"bj430047": QuoteMarketOther,
+	}
+	for code, want := range cases {
+		if got := GetQuoteMarket(code); got != want {
+			t.Errorf("%s: got %s want %s", code, got, want)
+		}
+	}
+	if !quoteCodeEqual("usAAPL", "gb_aapl") {
+		t.Error("us and gb_ codes should be equal")
+	}
+}
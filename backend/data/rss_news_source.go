@@ -0,0 +1,201 @@
+package data
+
+import (
+	"bytes"
+	"encoding/xml"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/models"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/net/html/charset"
+)
+
+// @Author spark
+// @Date 2025/10/14 10:30
+// @Desc 通用 RSS/Atom 资讯来源,兼容 RSS 2.0、RSS 1.0(RDF) 和 Atom
+// -----------------------------------------------------------------------------------
+
+// rssFeed RSS 2.0 的条目在 channel 下,RSS 1.0 的条目在根节点下,Atom 为 entry
+type rssFeed struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+var rssTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.DateTime,
+}
+
+// feedZoneOffsets RSS 常见的时区缩写,Go 解析未知缩写时按 UTC 处理,需要修正偏移
+var feedZoneOffsets = map[string]int{
+	"EST": -5 * 3600,
+	"EDT": -4 * 3600,
+	"CST": -6 * 3600,
+	"CDT": -5 * 3600,
+	"MST": -7 * 3600,
+	"MDT": -6 * 3600,
+	"PST": -8 * 3600,
+	"PDT": -7 * 3600,
+}
+
+type RssNewsSource struct {
+	name  string
+	title string
+	url   string
+}
+
+func NewRssNewsSource(name, title, url string) *RssNewsSource {
+	return &RssNewsSource{name: name, title: title, url: url}
+}
+
+func (r RssNewsSource) Name() string {
+	return r.name
+}
+
+// Fetch 拉取订阅,跳过发布时间早于 since 的条目
+func (r RssNewsSource) Fetch(since time.Time) []models.Telegraph {
+	client := resty.New()
+	config := GetSettingConfig()
+	if config.HttpProxyEnabled && config.HttpProxy != "" {
+		client.SetProxy(config.HttpProxy)
+	}
+	resp, err := client.SetTimeout(time.Duration(config.CrawlTimeOut)*time.Second).R().
+		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:140.0) Gecko/20100101 Firefox/140.0").
+		Get(r.url)
+	if err != nil {
+		logger.SugaredLogger.Errorf("fetch rss %s error:%s", r.url, err.Error())
+		return nil
+	}
+	items, err := parseFeed(resp.Body(), r.title)
+	if err != nil {
+		logger.SugaredLogger.Errorf("parse rss %s error:%s", r.url, err.Error())
+		return nil
+	}
+	var news []models.Telegraph
+	for _, item := range items {
+		if !since.IsZero() && !item.DataTime.After(since) {
+			continue
+		}
+		if saveSourceNews(&item) {
+			news = append(news, item)
+		}
+	}
+	return news
+}
+
+// parseFeed 解析 RSS/Atom 为资讯,正文去除 HTML 标签,没有发布时间的条目使用当前时间
+func parseFeed(body []byte, source string) ([]models.Telegraph, error) {
+	feed := rssFeed{}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	if err := decoder.Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	var news []models.Telegraph
+	add := func(title, link, content, published string) {
+		title, content = strings.TrimSpace(htmlText(title)), strings.TrimSpace(htmlText(content))
+		if title == "" && content == "" {
+			return
+		}
+		dataTime := parseFeedTime(published)
+		news = append(news, models.Telegraph{
+			Title:    title,
+			Content:  content,
+			Url:      strings.TrimSpace(link),
+			DataTime: &dataTime,
+			Time:     dataTime.Format("15:04:05"),
+			Source:   source,
+		})
+	}
+	for _, item := range append(feed.Channel.Items, feed.Items...) {
+		content := item.Description
+		if content == "" {
+			content = item.Content
+		}
+		published := item.PubDate
+		if published == "" {
+			published = item.Date
+		}
+		add(item.Title, item.Link, content, published)
+	}
+	for _, entry := range feed.Entries {
+		link := ""
+		for _, l := range entry.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+		content := entry.Summary
+		if content == "" {
+			content = entry.Content
+		}
+		published := entry.Published
+		if published == "" {
+			published = entry.Updated
+		}
+		add(entry.Title, link, content, published)
+	}
+	return news, nil
+}
+
+func parseFeedTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range rssTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			if name, offset := t.Zone(); offset == 0 {
+				if fixed, ok := feedZoneOffsets[name]; ok {
+					t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone(name, fixed))
+				}
+			}
+			return t.Local()
+		}
+	}
+	return time.Now()
+}
+
+// htmlText 提取 HTML 片段中的文本
+func htmlText(value string) string {
+	if !strings.Contains(value, "<") {
+		return value
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(value))
+	if err != nil {
+		return value
+	}
+	return doc.Text()
+}
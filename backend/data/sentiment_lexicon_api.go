@@ -0,0 +1,297 @@
+package data
+
+import (
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// @Author spark
+// @Date 2025/10/15 9:30
+// @Desc 情感词表:中英文的正面词、负面词、否定词、程度副词和转折词保存在数据库,支持编辑、导入导出,修改后立即生效
+// -----------------------------------------------------------------------------------
+
+const (
+	SentimentLangZh = "zh"
+	SentimentLangEn = "en"
+
+	SentimentPositive   = "positive"
+	SentimentNegative   = "negative"
+	SentimentNegation   = "negation"
+	SentimentDegree     = "degree"
+	SentimentTransition = "transition"
+)
+
+var sentimentKinds = []string{SentimentPositive, SentimentNegative, SentimentNegation, SentimentDegree, SentimentTransition}
+
+// SentimentWord 情感词,(kind, word) 唯一;否定词和转折词不使用权重
+type SentimentWord struct {
+	gorm.Model
+	Kind   string  `json:"kind" gorm:"uniqueIndex:idx_sentiment_word,priority:1"`
+	Word   string  `json:"word" gorm:"uniqueIndex:idx_sentiment_word,priority:2"`
+	Weight float64 `json:"weight"`
+	Lang   string  `json:"lang" gorm:"default:zh"` //zh 中文 en 英文,按词自动识别
+}
+
+func (SentimentWord) TableName() string {
+	return "sentiment_lexicon"
+}
+
+// sentimentLexicon 情感分析使用的词表,重新加载时整体替换
+type sentimentLexicon struct {
+	positive   map[string]float64
+	negative   map[string]float64
+	degree     map[string]float64
+	negation   map[string]struct{}
+	transition map[string]struct{}
+}
+
+var activeSentimentLexicon atomic.Pointer[sentimentLexicon]
+
+func currentSentimentLexicon() *sentimentLexicon {
+	if lexicon := activeSentimentLexicon.Load(); lexicon != nil {
+		return lexicon
+	}
+	//词表未加载时使用内置词典
+	activeSentimentLexicon.CompareAndSwap(nil, newSentimentLexicon(defaultSentimentWords()))
+	return activeSentimentLexicon.Load()
+}
+
+func newSentimentLexicon(words []SentimentWord) *sentimentLexicon {
+	lexicon := &sentimentLexicon{
+		positive:   map[string]float64{},
+		negative:   map[string]float64{},
+		degree:     map[string]float64{},
+		negation:   map[string]struct{}{},
+		transition: map[string]struct{}{},
+	}
+	for _, word := range words {
+		switch word.Kind {
+		case SentimentPositive:
+			lexicon.positive[word.Word] = word.Weight
+		case SentimentNegative:
+			lexicon.negative[word.Word] = word.Weight
+		case SentimentDegree:
+			lexicon.degree[word.Word] = word.Weight
+		case SentimentNegation:
+			lexicon.negation[word.Word] = struct{}{}
+		case SentimentTransition:
+			lexicon.transition[word.Word] = struct{}{}
+		}
+	}
+	return lexicon
+}
+
+// defaultSentimentWords 内置中英文词典,用于初始化情感词表
+func defaultSentimentWords() []SentimentWord {
+	var words []SentimentWord
+	weighted := func(kind, lang string, values map[string]float64) {
+		for word, weight := range values {
+			words = append(words, SentimentWord{Kind: kind, Word: word, Weight: weight, Lang: lang})
+		}
+	}
+	plain := func(kind, lang string, values map[string]struct{}) {
+		for word := range values {
+			words = append(words, SentimentWord{Kind: kind, Word: word, Weight: 1, Lang: lang})
+		}
+	}
+	weighted(SentimentPositive, SentimentLangZh, defaultPositiveFinanceWords)
+	weighted(SentimentNegative, SentimentLangZh, defaultNegativeFinanceWords)
+	weighted(SentimentDegree, SentimentLangZh, defaultDegreeWords)
+	plain(SentimentNegation, SentimentLangZh, defaultNegationWords)
+	plain(SentimentTransition, SentimentLangZh, defaultTransitionWords)
+	weighted(SentimentPositive, SentimentLangEn, defaultEnglishPositiveWords)
+	weighted(SentimentNegative, SentimentLangEn, defaultEnglishNegativeWords)
+	weighted(SentimentDegree, SentimentLangEn, defaultEnglishDegreeWords)
+	plain(SentimentNegation, SentimentLangEn, defaultEnglishNegationWords)
+	plain(SentimentTransition, SentimentLangEn, defaultEnglishTransitionWords)
+	return words
+}
+
+type SentimentLexiconApi struct {
+}
+
+func NewSentimentLexiconApi() *SentimentLexiconApi {
+	return &SentimentLexiconApi{}
+}
+
+// InitSentimentLexicon 创建情感词表,某种语言还没有情感词时写入该语言的内置词典,然后加载到情感分析
+func InitSentimentLexicon() {
+	if err := db.Dao.AutoMigrate(&SentimentWord{}); err != nil {
+		logger.SugaredLogger.Errorf("migrate sentiment lexicon error:%s", err.Error())
+		return
+	}
+	for lang, words := range lo.GroupBy(defaultSentimentWords(), func(word SentimentWord) string {
+		return word.Lang
+	}) {
+		cnt := int64(0)
+		db.Dao.Model(&SentimentWord{}).Where("lang = ?", lang).Count(&cnt)
+		if cnt > 0 {
+			continue
+		}
+		if err := db.Dao.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&words, 200).Error; err != nil {
+			logger.SugaredLogger.Errorf("seed sentiment lexicon error:%s", err.Error())
+		}
+	}
+	NewSentimentLexiconApi().Reload()
+}
+
+// Reload 从数据库重新加载情感词表,分词词典中没有的中文词一并加入
+func (s SentimentLexiconApi) Reload() int {
+	var words []SentimentWord
+	db.Dao.Model(&SentimentWord{}).Find(&words)
+	segMu.Lock()
+	if seg.Dict != nil {
+		added := false
+		for _, word := range words {
+			if word.Lang == SentimentLangEn {
+				continue
+			}
+			if _, _, ok := seg.Find(word.Word); !ok {
+				_ = seg.AddToken(word.Word, basefreq+100)
+				added = true
+			}
+		}
+		if added {
+			seg.CalcToken()
+		}
+	}
+	segMu.Unlock()
+	activeSentimentLexicon.Store(newSentimentLexicon(words))
+	logger.SugaredLogger.Infof("加载情感词%d个", len(words))
+	return len(words)
+}
+
+// GetWords 情感词列表,kind 为空时返回全部
+func (s SentimentLexiconApi) GetWords(kind string) *[]SentimentWord {
+	words := &[]SentimentWord{}
+	tx := db.Dao.Model(&SentimentWord{})
+	if kind != "" {
+		tx = tx.Where("kind = ?", kind)
+	}
+	tx.Order("kind, weight desc, word").Find(words)
+	return words
+}
+
+// SaveWord 新增或修改情感词,保存后立即生效
+func (s SentimentLexiconApi) SaveWord(kind, word string, weight float64) string {
+	if err := s.upsert([]SentimentWord{{Kind: kind, Word: word, Weight: weight}}); err != nil {
+		return err.Error()
+	}
+	s.Reload()
+	return "保存成功"
+}
+
+// DeleteWord 删除情感词,删除后立即生效
+func (s SentimentLexiconApi) DeleteWord(kind, word string) string {
+	res := db.Dao.Unscoped().Where("kind = ? and word = ?", kind, word).Delete(&SentimentWord{})
+	if res.Error != nil {
+		logger.SugaredLogger.Errorf("delete sentiment word error:%s", res.Error.Error())
+		return "删除失败"
+	}
+	if res.RowsAffected == 0 {
+		return "情感词不存在"
+	}
+	s.Reload()
+	return "删除成功"
+}
+
+// Export 导出情感词表,每行 "类型 词 权重",# 开头为注释
+func (s SentimentLexiconApi) Export() string {
+	var sb strings.Builder
+	sb.WriteString("# 类型 词 权重\n# 类型: " + strings.Join(sentimentKinds, ",") + "\n")
+	words := *s.GetWords("")
+	sort.SliceStable(words, func(i, j int) bool {
+		return lo.IndexOf(sentimentKinds, words[i].Kind) < lo.IndexOf(sentimentKinds, words[j].Kind)
+	})
+	for _, word := range words {
+		sb.WriteString(fmt.Sprintf("%s %s %s\n", word.Kind, word.Word, convertor.ToString(word.Weight)))
+	}
+	return sb.String()
+}
+
+// Import 导入情感词表,已存在的词更新权重,返回导入的数量
+func (s SentimentLexiconApi) Import(content string) (int, error) {
+	words, err := parseSentimentLexicon(content)
+	if err != nil {
+		return 0, err
+	}
+	if err = s.upsert(words); err != nil {
+		return 0, err
+	}
+	s.Reload()
+	return len(words), nil
+}
+
+func (s SentimentLexiconApi) upsert(words []SentimentWord) error {
+	for i := range words {
+		if err := validateSentimentWord(&words[i]); err != nil {
+			return err
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	err := db.Dao.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "word"}},
+		DoUpdates: clause.AssignmentColumns([]string{"weight", "lang", "updated_at"}),
+	}).CreateInBatches(&words, 200).Error
+	if err != nil {
+		logger.SugaredLogger.Errorf("save sentiment word error:%s", err.Error())
+		return fmt.Errorf("保存失败")
+	}
+	return nil
+}
+
+// parseSentimentLexicon 解析导入文件,权重缺省为 1
+func parseSentimentLexicon(content string) ([]SentimentWord, error) {
+	var words []SentimentWord
+	for i, line := range strings.Split(content, "\n") {
+		line = strutil.Trim(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("第%d行格式错误:%s", i+1, line)
+		}
+		word := SentimentWord{Kind: fields[0], Word: fields[1], Weight: 1}
+		if len(fields) == 3 {
+			weight, err := convertor.ToFloat(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("第%d行权重错误:%s", i+1, line)
+			}
+			word.Weight = weight
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
+
+func validateSentimentWord(word *SentimentWord) error {
+	word.Kind, word.Word = strutil.Trim(word.Kind), strutil.Trim(word.Word)
+	//英文词统一小写,与英文分词结果一致
+	word.Lang = SentimentLangZh
+	if isEnglishText(word.Word) {
+		word.Word, word.Lang = strings.ToLower(word.Word), SentimentLangEn
+	}
+	if !lo.Contains(sentimentKinds, word.Kind) {
+		return fmt.Errorf("未知的情感词类型:%s", word.Kind)
+	}
+	if word.Word == "" {
+		return fmt.Errorf("情感词不能为空")
+	}
+	if word.Weight <= 0 {
+		return fmt.Errorf("%s 的权重必须大于0", word.Word)
+	}
+	return nil
+}
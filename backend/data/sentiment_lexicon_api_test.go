@@ -0,0 +1,128 @@
+package data
+
+import (
+	"fmt"
+	"lumos-stock/backend/db"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-ego/gse"
+)
+
+// @Author spark
+// @Date 2025/10/15 14:30
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestParseSentimentLexicon(t *testing.T) {
+	words, err := parseSentimentLexicon("# 注释\npositive 扩产 2.5\n\nnegation 不\n")
+	if err != nil || len(words) != 2 {
+		t.Fatalf("parse error:%v %+v", err, words)
+	}
+	if words[0].Word != "扩产" || words[0].Weight != 2.5 || words[1].Weight != 1 {
+		t.Errorf("unexpected words %+v", words)
+	}
+	if _, err = parseSentimentLexicon("positive 扩产 2.5 多余"); err == nil {
+		t.Errorf("invalid line should fail")
+	}
+	if _, err = parseSentimentLexicon("positive 扩产 abc"); err == nil {
+		t.Errorf("invalid weight should fail")
+	}
+}
+
+func TestSentimentLexicon(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "lexicon.db"))
+	InitSentimentLexicon()
+	defer activeSentimentLexicon.Store(nil)
+
+	api := NewSentimentLexiconApi()
+	if n := len(*api.GetWords("")); n != len(defaultSentimentWords()) {
+		t.Fatalf("lexicon should be seeded, got %d", n)
+	}
+	//再次初始化不会重复写入
+	InitSentimentLexicon()
+	if n := len(*api.GetWords("")); n != len(defaultSentimentWords()) {
+		t.Errorf("lexicon seeded twice, got %d", n)
+	}
+
+	words := []string{"公司", "扩产"}
+	if score, _, _ := currentSentimentLexicon().calculateScore(words); score != 0 {
+		t.Errorf("unknown word should not score %f", score)
+	}
+	if msg := api.SaveWord(SentimentPositive, "扩产", 2); msg != "保存成功" {
+		t.Fatalf("save:%s", msg)
+	}
+	if score, pos, _ := currentSentimentLexicon().calculateScore(words); score != 2 || pos != 1 {
+		t.Errorf("saved word should take effect, score %f", score)
+	}
+	if msg := api.SaveWord("unknown", "扩产", 2); !strings.Contains(msg, "未知") {
+		t.Errorf("unknown kind:%s", msg)
+	}
+
+	n, err := api.Import("positive 扩产 3\ndegree 大举 2\n")
+	if err != nil || n != 2 {
+		t.Fatalf("import error:%v %d", err, n)
+	}
+	if score, _, _ := currentSentimentLexicon().calculateScore(words); score != 3 || currentSentimentLexicon().degree["大举"] != 2 {
+		t.Errorf("imported weight should take effect, score %f", score)
+	}
+	if !strings.Contains(api.Export(), "positive 扩产 3\n") {
+		t.Errorf("export should contain imported word")
+	}
+
+	if msg := api.DeleteWord(SentimentPositive, "扩产"); msg != "删除成功" {
+		t.Errorf("delete:%s", msg)
+	}
+	if score, _, _ := currentSentimentLexicon().calculateScore(words); score != 0 {
+		t.Errorf("deleted word should not score %f", score)
+	}
+}
+
+func TestSentimentLexiconReloadConcurrentCut(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "lexicon.db"))
+	segMu.Lock()
+	err := seg.LoadDictStr("股票 100 n\n上涨 100 v")
+	segMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		segMu.Lock()
+		seg = gse.Segmenter{}
+		segMu.Unlock()
+		activeSentimentLexicon.Store(nil)
+	}()
+	InitSentimentLexicon()
+
+	//保存新词会重新加载并加入分词词典,同时持续分词
+	var saving, cutting sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		saving.Add(1)
+		go func(i int) {
+			defer saving.Done()
+			NewSentimentLexiconApi().SaveWord(SentimentPositive, fmt.Sprintf("扩产%d期", i), 2)
+		}(i)
+		cutting.Add(1)
+		go func() {
+			defer cutting.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					splitWords("股票扩产1期大幅上涨")
+					newsTokens("股票扩产1期大幅上涨")
+				}
+			}
+		}()
+	}
+	saving.Wait()
+	close(done)
+	cutting.Wait()
+	if words, ok := segCut("股票扩产1期"); !ok || !strings.Contains(strings.Join(words, "|"), "扩产1期") {
+		t.Errorf("lexicon words should be added to the segmenter %v", words)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"lumos-stock/backend/db"
 	"lumos-stock/backend/logger"
+	"strings"
 	"time"
 
 	"github.com/samber/lo"
@@ -38,6 +39,8 @@ type Settings struct {
 	HttpProxyEnabled       bool   `json:"httpProxyEnabled"`
 	EnableAgent            bool   `json:"enableAgent"`
 	QgqpBId                string `json:"qgqpBId" gorm:"column:qgqp_b_id"`
+	BaseCurrency           string `json:"baseCurrency"`
+	SentimentAiConfigId    int    `json:"sentimentAiConfigId"` //资讯情绪分类使用的模型,0 使用情感词典
 }
 
 func (receiver Settings) TableName() string {
@@ -85,7 +88,7 @@ func UpdateConfig(s *SettingConfig) string {
 	count := int64(0)
 	db.Dao.Model(&Settings{}).Count(&count)
 	if count > 0 {
-		db.Dao.Model(&Settings{}).Where("id=?", s.ID).Updates(map[string]any{
+		updates := map[string]any{
 			"local_push_enable":          s.LocalPushEnable,
 			"ding_push_enable":           s.DingPushEnable,
 			"ding_robot":                 s.DingRobot,
@@ -110,7 +113,13 @@ func UpdateConfig(s *SettingConfig) string {
 			"http_proxy_enabled":         s.HttpProxyEnabled,
 			"enable_agent":               s.EnableAgent,
 			"qgqp_b_id":                  s.QgqpBId,
-		})
+			"sentiment_ai_config_id":     s.SentimentAiConfigId,
+		}
+		//未传汇总货币时保留原设置
+		if s.BaseCurrency != "" {
+			updates["base_currency"] = strings.ToUpper(s.BaseCurrency)
+		}
+		db.Dao.Model(&Settings{}).Where("id=?", s.ID).Updates(updates)
 
 		//更新AiConfig
 		err := updateAiConfigs(s.AiConfigs)
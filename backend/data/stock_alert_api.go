@@ -0,0 +1,433 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/mathutil"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/9/2 10:12
+// @Desc 股票报警规则引擎
+// -----------------------------------------------------------------------------------
+
+// 报警规则类型
+const (
+	AlertRulePriceAbove = "price_above" //股价高于
+	AlertRulePriceBelow = "price_below" //股价低于
+	AlertRuleChangeBand = "change_band" //涨跌幅超出区间(绝对值)
+	AlertRuleBreakHigh  = "break_high"  //盘中创新高
+	AlertRuleBreakLow   = "break_low"   //盘中创新低
+)
+
+// 报警消息类型,与 App.SendDingDingMessageByType 的 msgType 保持一致
+const (
+	alertMsgTypeChange = 1 //涨跌报警
+	alertMsgTypePrice  = 2 //股价报警
+	alertMsgTypeBreak  = 4 //新高新低报警
+
+	defaultAlertCoolDown = 60 * 5 //默认冷却时间(秒)
+)
+
+// AlertRule 报警规则
+type AlertRule struct {
+	gorm.Model
+	StockCode  string  `json:"stockCode" gorm:"index"`
+	RuleType   string  `json:"ruleType"`
+	Threshold  float64 `json:"threshold"`
+	CoolDown   int64   `json:"coolDown"`   //冷却时间(秒),0 使用默认值
+	OncePerDay bool    `json:"oncePerDay"` //每个交易日只提醒一次
+	Enable     bool    `json:"enable"`
+	Remark     string  `json:"remark"`
+}
+
+func (AlertRule) TableName() string {
+	return "stock_alert_rule"
+}
+
+// AlertHistory 报警记录
+type AlertHistory struct {
+	gorm.Model
+	StockCode     string  `json:"stockCode" gorm:"index"`
+	StockName     string  `json:"stockName"`
+	RuleId        uint    `json:"ruleId"`
+	RuleKey       string  `json:"ruleKey" gorm:"index"`
+	RuleType      string  `json:"ruleType"`
+	Threshold     float64 `json:"threshold"`
+	Price         float64 `json:"price"`
+	ChangePercent float64 `json:"changePercent"`
+	TradeDay      string  `json:"tradeDay" gorm:"index"`
+	MsgType       int     `json:"msgType"`
+	Message       string  `json:"message"`
+}
+
+func (AlertHistory) TableName() string {
+	return "stock_alert_history"
+}
+
+// Key 规则唯一标识,关注股票上的报警设置没有ID,使用规则类型区分
+func (r AlertRule) Key() string {
+	if r.ID == 0 {
+		return fmt.Sprintf("%s:follow:%s", r.StockCode, r.RuleType)
+	}
+	return fmt.Sprintf("%s:%d", r.StockCode, r.ID)
+}
+
+func (r AlertRule) coolDown() time.Duration {
+	if r.CoolDown <= 0 {
+		return defaultAlertCoolDown * time.Second
+	}
+	return time.Duration(r.CoolDown) * time.Second
+}
+
+func (r AlertRule) MsgType() int {
+	switch r.RuleType {
+	case AlertRuleChangeBand:
+		return alertMsgTypeChange
+	case AlertRuleBreakHigh, AlertRuleBreakLow:
+		return alertMsgTypeBreak
+	default:
+		return alertMsgTypePrice
+	}
+}
+
+func (r AlertRule) Name() string {
+	switch r.RuleType {
+	case AlertRulePriceAbove:
+		return "股价高于"
+	case AlertRulePriceBelow:
+		return "股价低于"
+	case AlertRuleChangeBand:
+		return "涨跌幅超过"
+	case AlertRuleBreakHigh:
+		return "盘中创新高"
+	case AlertRuleBreakLow:
+		return "盘中创新低"
+	default:
+		return r.RuleType
+	}
+}
+
+// alertQuote 规则计算需要的行情快照
+type alertQuote struct {
+	Price         float64
+	High          float64
+	Low           float64
+	ChangePercent float64
+}
+
+// alertEngineState 冷却时间、每日一次以及盘中高低点的内存状态,各市场交易日不同,按股票记录当前交易日
+type alertEngineState struct {
+	mu        sync.Mutex
+	days      map[string]string
+	lastFired map[string]time.Time
+	firedDay  map[string]string
+	highs     map[string]float64
+	lows      map[string]float64
+	loaded    map[string]bool
+}
+
+var alertState = newAlertEngineState()
+
+func newAlertEngineState() *alertEngineState {
+	return &alertEngineState{
+		days:      map[string]string{},
+		lastFired: map[string]time.Time{},
+		firedDay:  map[string]string{},
+		highs:     map[string]float64{},
+		lows:      map[string]float64{},
+		loaded:    map[string]bool{},
+	}
+}
+
+// resetIfNewDay 股票的交易日切换后清空该股票的盘中高低点和上一交易日的恢复标记
+func (s *alertEngineState) resetIfNewDay(stockCode, day string) {
+	last, ok := s.days[stockCode]
+	if ok && last == day {
+		return
+	}
+	s.days[stockCode] = day
+	if ok {
+		delete(s.highs, stockCode)
+		delete(s.lows, stockCode)
+		delete(s.loaded, stockCode+"@"+last)
+	}
+}
+
+// allow 判断规则是否处于冷却或当日已触发
+func (s *alertEngineState) allow(rule AlertRule, day string, now time.Time) bool {
+	key := rule.Key()
+	if rule.OncePerDay && s.firedDay[key] == day {
+		return false
+	}
+	if last, ok := s.lastFired[key]; ok && now.Sub(last) < rule.coolDown() {
+		return false
+	}
+	return true
+}
+
+func (s *alertEngineState) fired(rule AlertRule, day string, now time.Time) {
+	key := rule.Key()
+	s.lastFired[key] = now
+	s.firedDay[key] = day
+}
+
+// check 计算单条规则是否触发,返回触发说明
+func (s *alertEngineState) check(rule AlertRule, quote alertQuote) (bool, string) {
+	if quote.Price <= 0 {
+		return false, ""
+	}
+	switch rule.RuleType {
+	case AlertRulePriceAbove:
+		if rule.Threshold > 0 && quote.Price >= rule.Threshold {
+			return true, fmt.Sprintf("当前价格%.2f 高于报警价格%.2f", quote.Price, rule.Threshold)
+		}
+	case AlertRulePriceBelow:
+		if rule.Threshold > 0 && quote.Price <= rule.Threshold {
+			return true, fmt.Sprintf("当前价格%.2f 低于报警价格%.2f", quote.Price, rule.Threshold)
+		}
+	case AlertRuleChangeBand:
+		if rule.Threshold > 0 && mathutil.Abs(quote.ChangePercent) >= rule.Threshold {
+			return true, fmt.Sprintf("当前涨跌幅%.2f%% 超过报警值±%.2f%%", quote.ChangePercent, rule.Threshold)
+		}
+	case AlertRuleBreakHigh:
+		prev, ok := s.highs[rule.StockCode]
+		if ok && quote.High > prev && quote.Price >= quote.High {
+			return true, fmt.Sprintf("当前价格%.2f 突破盘中高点%.2f", quote.Price, prev)
+		}
+	case AlertRuleBreakLow:
+		prev, ok := s.lows[rule.StockCode]
+		if ok && quote.Low > 0 && quote.Low < prev && quote.Price <= quote.Low {
+			return true, fmt.Sprintf("当前价格%.2f 跌破盘中低点%.2f", quote.Price, prev)
+		}
+	}
+	return false, ""
+}
+
+// observe 记录本次行情的盘中高低点,供下一次突破判断
+func (s *alertEngineState) observe(stockCode string, quote alertQuote) {
+	if quote.High > 0 && quote.High > s.highs[stockCode] {
+		s.highs[stockCode] = quote.High
+	}
+	if low, ok := s.lows[stockCode]; quote.Low > 0 && (!ok || quote.Low < low) {
+		s.lows[stockCode] = quote.Low
+	}
+}
+
+type StockAlertApi struct {
+}
+
+func NewStockAlertApi() *StockAlertApi {
+	return &StockAlertApi{}
+}
+
+func (receiver StockAlertApi) AddAlertRule(rule AlertRule) string {
+	rule.StockCode = normalizeAlertStockCode(rule.StockCode)
+	switch rule.RuleType {
+	case AlertRulePriceAbove, AlertRulePriceBelow, AlertRuleChangeBand, AlertRuleBreakHigh, AlertRuleBreakLow:
+	default:
+		return "不支持的报警类型"
+	}
+	var err error
+	if rule.ID > 0 {
+		err = db.Dao.Model(&AlertRule{}).Where("id = ?", rule.ID).Updates(map[string]any{
+			"rule_type":    rule.RuleType,
+			"threshold":    rule.Threshold,
+			"cool_down":    rule.CoolDown,
+			"once_per_day": rule.OncePerDay,
+			"enable":       rule.Enable,
+			"remark":       rule.Remark,
+		}).Error
+	} else {
+		err = db.Dao.Create(&rule).Error
+	}
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+		return "保存失败"
+	}
+	return "保存成功"
+}
+
+func (receiver StockAlertApi) DeleteAlertRule(id uint) string {
+	err := db.Dao.Delete(&AlertRule{}, id).Error
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+		return "删除失败"
+	}
+	return "删除成功"
+}
+
+func (receiver StockAlertApi) GetAlertRules(stockCode string) []AlertRule {
+	var rules []AlertRule
+	query := db.Dao.Model(&AlertRule{})
+	if stockCode != "" {
+		query = query.Where("stock_code = ?", normalizeAlertStockCode(stockCode))
+	}
+	query.Order("id asc").Find(&rules)
+	return rules
+}
+
+func (receiver StockAlertApi) GetAlertHistory(stockCode string, limit int) []AlertHistory {
+	if limit <= 0 {
+		limit = 100
+	}
+	var list []AlertHistory
+	query := db.Dao.Model(&AlertHistory{})
+	if stockCode != "" {
+		query = query.Where("stock_code = ?", normalizeAlertStockCode(stockCode))
+	}
+	query.Order("id desc").Limit(limit).Find(&list)
+	return list
+}
+
+// Evaluate 使用关注股票上的报警设置(已由 addStockFollowData 写入 stockInfo)和自定义规则检查当前行情,
+// 返回本次触发的报警(已保存到报警记录)
+func (receiver StockAlertApi) Evaluate(stockInfo *StockInfo) []AlertHistory {
+	stockCode := normalizeAlertStockCode(stockInfo.Code)
+	rules := followAlertRules(stockCode, stockInfo)
+	var custom []AlertRule
+	db.Dao.Model(&AlertRule{}).Where("stock_code = ? and enable = ?", stockCode, true).Find(&custom)
+	rules = append(rules, custom...)
+
+	quote := toAlertQuote(stockInfo)
+	day := stockInfo.Date
+	if day == "" {
+		day = time.Now().Format(time.DateOnly)
+	}
+	now := time.Now()
+
+	alertState.mu.Lock()
+	defer alertState.mu.Unlock()
+	alertState.resetIfNewDay(stockCode, day)
+	alertState.loadToday(stockCode, day)
+
+	var triggered []AlertHistory
+	for _, rule := range rules {
+		if !alertState.allow(rule, day, now) {
+			continue
+		}
+		ok, reason := alertState.check(rule, quote)
+		if !ok {
+			continue
+		}
+		alertState.fired(rule, day, now)
+		history := AlertHistory{
+			StockCode:     stockCode,
+			StockName:     stockInfo.Name,
+			RuleId:        rule.ID,
+			RuleKey:       rule.Key(),
+			RuleType:      rule.RuleType,
+			Threshold:     rule.Threshold,
+			Price:         quote.Price,
+			ChangePercent: quote.ChangePercent,
+			TradeDay:      day,
+			MsgType:       rule.MsgType(),
+			Message:       fmt.Sprintf("[%s] %s(%s) %s", rule.Name(), stockInfo.Name, stockInfo.Code, reason),
+		}
+		if err := db.Dao.Create(&history).Error; err != nil {
+			logger.SugaredLogger.Errorf("save alert history error:%s", err.Error())
+		}
+		triggered = append(triggered, history)
+	}
+	alertState.observe(stockCode, quote)
+	return triggered
+}
+
+// loadToday 程序重启后从报警记录恢复当日触发状态,保证每日一次不被重复提醒
+func (s *alertEngineState) loadToday(stockCode, day string) {
+	loadKey := stockCode + "@" + day
+	if s.loaded[loadKey] {
+		return
+	}
+	s.loaded[loadKey] = true
+	var list []AlertHistory
+	db.Dao.Model(&AlertHistory{}).Where("stock_code = ? and trade_day = ?", stockCode, day).Order("id asc").Find(&list)
+	for _, item := range list {
+		s.lastFired[item.RuleKey] = item.CreatedAt
+		s.firedDay[item.RuleKey] = day
+	}
+}
+
+// followAlertRules 关注股票上的报警价格和涨跌幅报警转换为规则
+func followAlertRules(stockCode string, stockInfo *StockInfo) []AlertRule {
+	var rules []AlertRule
+	if stockInfo.AlarmPrice > 0 {
+		rules = append(rules, AlertRule{
+			StockCode:  stockCode,
+			RuleType:   AlertRulePriceAbove,
+			Threshold:  stockInfo.AlarmPrice,
+			CoolDown:   60 * 30,
+			OncePerDay: true,
+			Enable:     true,
+		})
+	}
+	if stockInfo.AlarmChangePercent > 0 {
+		rules = append(rules, AlertRule{
+			StockCode: stockCode,
+			RuleType:  AlertRuleChangeBand,
+			Threshold: stockInfo.AlarmChangePercent,
+			CoolDown:  60 * 5,
+			Enable:    true,
+		})
+	}
+	return rules
+}
+
+func toAlertQuote(stockInfo *StockInfo) alertQuote {
+	price, _ := convertor.ToFloat(stockInfo.Price)
+	high, _ := convertor.ToFloat(stockInfo.High)
+	low, _ := convertor.ToFloat(stockInfo.Low)
+	return alertQuote{
+		Price:         price,
+		High:          high,
+		Low:           low,
+		ChangePercent: stockInfo.ChangePercent,
+	}
+}
+
+func normalizeAlertStockCode(stockCode string) string {
+	stockCode = strings.ToLower(stockCode)
+	if strings.HasPrefix(stockCode, "gb_") {
+		stockCode = strings.Replace(stockCode, "gb_", "us", 1)
+	}
+	return stockCode
+}
+
+// GenAlertDingDingMessage 生成钉钉markdown消息
+func GenAlertDingDingMessage(history AlertHistory, stockInfo *StockInfo) string {
+	ruleName := AlertRule{RuleType: history.RuleType}.Name()
+	var md strings.Builder
+	md.WriteString(fmt.Sprintf("### lumos-stock [%s]\n\n", ruleName))
+	md.WriteString(fmt.Sprintf("### %s(%s)\n", stockInfo.Name, stockInfo.Code))
+	md.WriteString(fmt.Sprintf("- %s\n", history.Message))
+	md.WriteString(fmt.Sprintf("- 当前价格: %s  %.2f%%\n", stockInfo.Price, stockInfo.ChangePercent))
+	md.WriteString(fmt.Sprintf("- 最高价: %s  %.2f\n", stockInfo.High, stockInfo.HighRate))
+	md.WriteString(fmt.Sprintf("- 最低价: %s  %.2f\n", stockInfo.Low, stockInfo.LowRate))
+	md.WriteString(fmt.Sprintf("- 昨收价: %s\n", stockInfo.PreClose))
+	md.WriteString(fmt.Sprintf("- 今开价: %s\n", stockInfo.Open))
+	md.WriteString(fmt.Sprintf("- 日期: %s  %s\n", stockInfo.Date, stockInfo.Time))
+	msg := map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]any{
+			"title": fmt.Sprintf("[%s]%s(%s) %s", ruleName, stockInfo.Name, stockInfo.Code, stockInfo.Price),
+			"text":  md.String(),
+		},
+		"at": map[string]any{
+			"isAtAll": true,
+		},
+	}
+	bytes, err := json.Marshal(msg)
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+		return ""
+	}
+	return string(bytes)
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/2 11:05
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func TestAlertEngineCheck(t *testing.T) {
+	state := newAlertEngineState()
+	quote := alertQuote{Price: 10.5, High: 10.6, Low: 9.8, ChangePercent: 5.2}
+
+	tests := []struct {
+		rule AlertRule
+		want bool
+	}{
+		{AlertRule{StockCode: "sh600000", RuleType: AlertRulePriceAbove, Threshold: 10}, true},
+		{AlertRule{StockCode: "sh600000", RuleType: AlertRulePriceAbove, Threshold: 11}, false},
+		{AlertRule{StockCode: "sh600000", RuleType: AlertRulePriceBelow, Threshold: 10}, false},
+		{AlertRule{StockCode: "sh600000", RuleType: AlertRulePriceBelow, Threshold: 11}, true},
+		{AlertRule{StockCode: "sh600000", RuleType: AlertRuleChangeBand, Threshold: 5}, true},
+		{AlertRule{StockCode: "sh600000", RuleType: AlertRuleChangeBand, Threshold: 6}, false},
+	}
+	for _, tt := range tests {
+		got, reason := state.check(tt.rule, quote)
+		if got != tt.want {
+			t.Errorf("%s threshold %.2f: got %v want %v", tt.rule.RuleType, tt.rule.Threshold, got, tt.want)
+		}
+		t.Log(reason)
+	}
+
+	negative := alertQuote{Price: 9, ChangePercent: -5.5}
+	if ok, _ := state.check(AlertRule{RuleType: AlertRuleChangeBand, Threshold: 5}, negative); !ok {
+		t.Error("change band should trigger on negative change")
+	}
+}
+
+func TestAlertEngineBreakHighLow(t *testing.T) {
+	state := newAlertEngineState()
+	high := AlertRule{StockCode: "sz000001", RuleType: AlertRuleBreakHigh}
+	low := AlertRule{StockCode: "sz000001", RuleType: AlertRuleBreakLow}
+
+	first := alertQuote{Price: 10, High: 10.2, Low: 9.9}
+	if ok, _ := state.check(high, first); ok {
+		t.Error("first quote of the day should only be observed")
+	}
+	state.observe("sz000001", first)
+
+	up := alertQuote{Price: 10.3, High: 10.3, Low: 9.9}
+	if ok, _ := state.check(high, up); !ok {
+		t.Error("new intraday high should trigger")
+	}
+	state.observe("sz000001", up)
+
+	back := alertQuote{Price: 10.1, High: 10.3, Low: 9.9}
+	if ok, _ := state.check(high, back); ok {
+		t.Error("unchanged high should not trigger")
+	}
+
+	down := alertQuote{Price: 9.8, High: 10.3, Low: 9.8}
+	if ok, _ := state.check(low, down); !ok {
+		t.Error("new intraday low should trigger")
+	}
+
+	state.observe("hk00700", down)
+	state.resetIfNewDay("sz000001", "2025-09-02")
+	state.resetIfNewDay("hk00700", "2025-09-03")
+	if ok, _ := state.check(low, down); !ok {
+		t.Error("new day of another market should not clear the state")
+	}
+	state.loaded["sz000001@2025-09-02"] = true
+	state.resetIfNewDay("sz000001", "2025-09-03")
+	if ok, _ := state.check(low, down); ok {
+		t.Error("state should be cleared on a new day")
+	}
+	if len(state.loaded) != 0 {
+		t.Errorf("loaded marks of previous day should be pruned %v", state.loaded)
+	}
+}
+
+func TestAlertEngineCoolDown(t *testing.T) {
+	state := newAlertEngineState()
+	now := time.Now()
+	rule := AlertRule{StockCode: "hk00700", RuleType: AlertRuleChangeBand, Threshold: 3, CoolDown: 60}
+	if !state.allow(rule, "2025-09-02", now) {
+		t.Fatal("rule should be allowed before first trigger")
+	}
+	state.fired(rule, "2025-09-02", now)
+	if state.allow(rule, "2025-09-02", now.Add(30*time.Second)) {
+		t.Error("rule should be cooling down")
+	}
+	if !state.allow(rule, "2025-09-02", now.Add(61*time.Second)) {
+		t.Error("rule should be allowed after cool down")
+	}
+
+	once := AlertRule{StockCode: "hk00700", RuleType: AlertRulePriceAbove, Threshold: 500, CoolDown: 1, OncePerDay: true}
+	state.fired(once, "2025-09-02", now)
+	if state.allow(once, "2025-09-02", now.Add(time.Hour)) {
+		t.Error("once per day rule should not fire twice on the same day")
+	}
+	if !state.allow(once, "2025-09-03", now.Add(24*time.Hour)) {
+		t.Error("once per day rule should fire again on the next day")
+	}
+}
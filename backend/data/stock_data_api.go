@@ -77,6 +77,7 @@ type StockInfo struct {
 	A5P      string  `json:"卖五报价"`
 	A5V      string  `json:"卖五申报"`
 	Market   string  `json:"市场"`
+	Currency string  `json:"currency"` //交易货币
 	BA       string  `json:"盘前盘后"`
 	BAChange string  `json:"盘前盘后涨跌幅"`
 
@@ -90,6 +91,7 @@ type StockInfo struct {
 	Profit            float64 `json:"profit"`            //总盈亏率
 	ProfitAmount      float64 `json:"profitAmount"`      //总盈亏金额
 	ProfitAmountToday float64 `json:"profitAmountToday"` //今日盈亏金额
+	RealizedProfit    float64 `json:"realizedProfit"`    //已实现盈亏(含分红)
 
 	Sort               int64   `json:"sort"` //排序
 	AlarmChangePercent float64 `json:"alarmChangePercent"`
@@ -295,108 +297,24 @@ func (receiver StockDataApi) GetStockBaseInfo() {
 }
 
 func (receiver StockDataApi) GetStockCodeRealTimeData(StockCodes ...string) (*[]StockInfo, error) {
-	stockInfos := make([]StockInfo, 0)
-
-	hkcodes := slice.Filter(StockCodes, func(i int, s string) bool {
-		return strutil.HasPrefixAny(s, []string{"hk", "HK", "sh", "sz"})
-	})
-
-	if hkcodes != nil && len(hkcodes) > 0 {
-		hkcodesStr := slice.JoinFunc(hkcodes, ",", func(s string) string {
-			if strutil.HasPrefixAny(s, []string{"hk", "HK"}) {
-				return "r_" + strings.ToLower(s)
-			} else {
-				return strings.ToLower(s)
-			}
-		})
-		url := fmt.Sprintf(txStockUrl, time.Now().Unix(), hkcodesStr)
-		resp, err := receiver.client.R().
-			SetHeader("Host", "qt.gtimg.cn").
-			SetHeader("Referer", "https://gu.qq.com/").
-			SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
-			Get(url)
-		logger.SugaredLogger.Infof("GetStockCodeRealTimeData %s", url)
-		if err != nil {
-			logger.SugaredLogger.Error(err.Error())
-			return &[]StockInfo{}, err
-		}
-		str := GB18030ToUTF8(resp.Body())
-		dataStr := strutil.SplitAndTrim(strings.Trim(str, "\n"), ";")
-
-		for _, data := range dataStr {
-			stockData, err := ParseTxStockData(data)
-			if err != nil {
-				logger.SugaredLogger.Error(err.Error())
-				continue
-			}
-			stockInfos = append(stockInfos, *stockData)
-			go func() {
-				var count int64
-				db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Count(&count)
-				if count == 0 {
-					db.Dao.Model(&StockInfo{}).Create(stockData)
-				} else {
-					db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Updates(stockData)
-				}
-			}()
-		}
-	}
-
-	szzsusCodes := slice.Filter(StockCodes, func(i int, s string) bool {
-		return !strutil.HasPrefixAny(s, []string{"hk", "HK", "sh", "sz"})
-	})
-
-	codes := slice.JoinFunc(szzsusCodes, ",", func(s string) string {
-		if strings.HasPrefix(s, "us") {
-			s = strings.Replace(s, "us", "gb_", 1)
-		}
-		if strings.HasPrefix(s, "US") {
-			s = strings.Replace(s, "US", "gb_", 1)
-		}
-		return strings.ToLower(s)
-	})
-
-	url := fmt.Sprintf(sinaStockUrl, time.Now().Unix(), codes)
-	//logger.SugaredLogger.Infof("GetStockCodeRealTimeData %s", url)
-	resp, err := receiver.client.R().
-		SetHeader("Host", "hq.sinajs.cn").
-		SetHeader("Referer", "https://finance.sina.com.cn/").
-		SetHeader("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 Edg/119.0.0.0").
-		Get(url)
+	stockInfos, err := GetQuoteProviderRegistry().Fetch(receiver.client, StockCodes)
 	if err != nil {
 		logger.SugaredLogger.Error(err.Error())
 		return &[]StockInfo{}, err
 	}
-
-	str := GB18030ToUTF8(resp.Body())
-	dataStr := strutil.SplitEx(str, "\n", true)
-
-	for _, data := range dataStr {
-		//logger.SugaredLogger.Info(data)
-		stockData, err := ParseFullSingleStockData(data)
-		//logger.SugaredLogger.Infof("GetStockCodeRealTimeData %v", stockData)
-		if err != nil {
-			logger.SugaredLogger.Error(err.Error())
-			continue
-		}
-		if stockData == nil {
-			continue
-		}
-		stockInfos = append(stockInfos, *stockData)
-
+	for _, stockInfo := range stockInfos {
+		stockData := stockInfo
 		go func() {
 			var count int64
 			db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Count(&count)
 			if count == 0 {
-				db.Dao.Model(&StockInfo{}).Create(stockData)
+				db.Dao.Model(&StockInfo{}).Create(&stockData)
 			} else {
-				db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Updates(stockData)
+				db.Dao.Model(&StockInfo{}).Where("code = ?", stockData.Code).Updates(&stockData)
 			}
 		}()
-
 	}
-
-	return &stockInfos, err
+	return &stockInfos, nil
 }
 
 func (receiver StockDataApi) Follow(stockCode string) string {
@@ -460,18 +378,18 @@ func (receiver StockDataApi) UnFollow(stockCode string) string {
 	return "取消关注成功"
 }
 
+// SetCostPriceAndVolume 成本价和持仓量由交易流水计算,这里转为期初持仓或持仓调整流水
 func (receiver StockDataApi) SetCostPriceAndVolume(price float64, volume int64, stockCode string) string {
 	if strutil.HasPrefixAny(stockCode, []string{"gb_"}) {
 		stockCode = strings.ToUpper(stockCode)
 		stockCode = strings.Replace(stockCode, "gb_", "us", 1)
 		stockCode = strings.Replace(stockCode, "GB_", "us", 1)
 	}
-	err := db.Dao.Model(&FollowedStock{}).Where("stock_code = ?", strings.ToLower(stockCode)).Update("cost_price", price).Update("volume", volume).Error
-	if err != nil {
-		logger.SugaredLogger.Error(err.Error())
-		return "设置失败"
+	res := NewTradeLedgerApi().SetOpeningPosition(strings.ToLower(stockCode), price, volume)
+	if res == "保存成功" {
+		return "设置成功"
 	}
-	return "设置成功"
+	return res
 }
 
 func (receiver StockDataApi) SetAlarmChangePercent(val, alarmPrice float64, stockCode string) string {
@@ -1746,8 +1664,8 @@ func (receiver StockDataApi) GetCommonKLineData(stockCode string, kLineType stri
 }
 
 // GetStockHistoryMoneyData 获取股票历史资金流向数据
-func (receiver StockDataApi) GetStockHistoryMoneyData() {
-
+func (receiver StockDataApi) GetStockHistoryMoneyData(stockCode string, days int) *[]MoneyFlowDaily {
+	return NewMoneyFlowApi().GetMoneyFlow(stockCode, days)
 }
 
 // JSONToMarkdownTable 将JSON数据转换为Markdown表格
@@ -0,0 +1,44 @@
+//go:build linux
+// +build linux
+
+package data
+
+import (
+	"os"
+	"os/exec"
+)
+
+// CheckChrome 检查 Linux 是否安装了 Chrome/Chromium 浏览器
+func CheckChrome() (string, bool) {
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, true
+		}
+	}
+	// snap、flatpak 等安装方式不一定在 PATH 中
+	locations := []string{
+		"/opt/google/chrome/chrome",
+		"/snap/bin/chromium",
+		"/var/lib/flatpak/exports/bin/com.google.Chrome",
+		"/var/lib/flatpak/exports/bin/org.chromium.Chromium",
+	}
+	for _, location := range locations {
+		if _, err := os.Stat(location); err == nil {
+			return location, true
+		}
+	}
+	return "", false
+}
+
+// CheckBrowser 检查 Linux 是否安装了浏览器，并返回安装路径
+func CheckBrowser() (string, bool) {
+	if path, ok := CheckChrome(); ok {
+		return path, true
+	}
+	for _, name := range []string{"microsoft-edge", "microsoft-edge-stable"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
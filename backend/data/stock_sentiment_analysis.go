@@ -11,7 +11,9 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/duke-git/lancet/v2/convertor"
 	"github.com/duke-git/lancet/v2/fileutil"
@@ -21,12 +23,14 @@ import (
 
 const basefreq float64 = 100
 
-// 金融情感词典，包含股票市场相关的专业词汇
+// 金融情感词典，包含股票市场相关的专业词汇，作为情感词表的初始数据
 var (
 	seg gse.Segmenter
+	//加载词典和加词时写锁,分词时读锁
+	segMu sync.RWMutex
 
 	// 正面金融词汇及其权重
-	positiveFinanceWords = map[string]float64{
+	defaultPositiveFinanceWords = map[string]float64{
 		"涨": 1.0, "上涨": 2.0, "涨停": 3.0, "牛市": 3.0, "反弹": 2.0, "新高": 2.5,
 		"利好": 2.5, "增持": 2.0, "买入": 2.0, "推荐": 1.5, "看多": 2.0,
 		"盈利": 2.0, "增长": 2.0, "超预期": 2.5, "强劲": 1.5, "回升": 1.5,
@@ -37,7 +41,7 @@ var (
 	}
 
 	// 负面金融词汇及其权重
-	negativeFinanceWords = map[string]float64{
+	defaultNegativeFinanceWords = map[string]float64{
 		"跌": 2.0, "下跌": 2.0, "跌停": 3.0, "熊市": 3.0, "回调": 2.5, "新低": 2.5,
 		"利空": 2.5, "减持": 2.0, "卖出": 2.0, "看空": 2.0, "亏损": 2.5,
 		"下滑": 2.0, "萎缩": 2.0, "不及预期": 2.5, "疲软": 1.5, "恶化": 2.0,
@@ -49,19 +53,19 @@ var (
 	}
 
 	// 否定词，用于反转情感极性
-	negationWords = map[string]struct{}{
+	defaultNegationWords = map[string]struct{}{
 		"不": {}, "没": {}, "无": {}, "非": {}, "未": {}, "别": {}, "勿": {},
 	}
 
 	// 程度副词，用于调整情感强度
-	degreeWords = map[string]float64{
+	defaultDegreeWords = map[string]float64{
 		"非常": 1.8, "极其": 2.2, "太": 1.8, "很": 1.5,
 		"比较": 0.8, "稍微": 0.6, "有点": 0.7, "显著": 1.5,
 		"大幅": 1.8, "急剧": 2.0, "轻微": 0.6, "小幅": 0.7, "逾": 1.8, "超": 1.8,
 	}
 
 	// 转折词，用于识别情感转折
-	transitionWords = map[string]struct{}{
+	defaultTransitionWords = map[string]struct{}{
 		"但是": {}, "然而": {}, "不过": {}, "却": {}, "可是": {},
 	}
 )
@@ -78,6 +82,8 @@ func InitAnalyzeSentiment() {
 			logger.SugaredLogger.Error(fmt.Sprintf("panic: %v", r))
 		}
 	}()
+	segMu.Lock()
+	defer segMu.Unlock()
 	// 加载简体中文词典
 	//err := seg.LoadDict("zh_s")
 	//if err != nil {
@@ -123,14 +129,20 @@ func InitAnalyzeSentiment() {
 		}
 	}
 	logger.SugaredLogger.Info("加载港股名称词典成功")
-	//stockus := &[]models.StockInfoUS{}
-	//db.Dao.Model(&models.StockInfoUS{}).Where("trim(name) != ?", "").Find(stockus)
-	//for _, stock := range *stockus {
-	//	err := seg.AddToken(stock.Name, 500)
-	//	if err != nil {
-	//		logger.SugaredLogger.Errorf("添加%s失败:%s", stock.Name, err.Error())
-	//	}
-	//}
+	//美股只加载中文名称,用于资讯实体识别
+	stockus := &[]models.StockInfoUS{}
+	db.Dao.Model(&models.StockInfoUS{}).Where("trim(name) != ?", "").Find(stockus)
+	for _, stock := range *stockus {
+		name := strutil.Trim(stock.Name)
+		if utf8.RuneCountInString(name) < 2 || !strings.ContainsFunc(name, func(r rune) bool { return unicode.Is(unicode.Han, r) }) {
+			continue
+		}
+		err := seg.AddToken(name, basefreq+100, "n")
+		if err != nil {
+			logger.SugaredLogger.Errorf("添加%s失败:%s", stock.Name, err.Error())
+		}
+	}
+	logger.SugaredLogger.Info("加载美股名称词典成功")
 	tags := &[]models.Tags{}
 	db.Dao.Model(&models.Tags{}).Where("type = ?", "subject").Find(tags)
 	for _, tag := range *tags {
@@ -202,8 +214,9 @@ func InitAnalyzeSentiment() {
 // getWordWeight 获取词汇权重
 func getWordWeight(word string) float64 {
 	// 从分词器获取词汇权重
-
+	segMu.RLock()
 	freq, pos, ok := seg.Dictionary().Find([]byte(word))
+	segMu.RUnlock()
 	if ok {
 		logger.SugaredLogger.Infof("获取%s的权重:%f,pos:%s,ok:%v", word, freq, pos, ok)
 		return freq
@@ -336,12 +349,13 @@ func AnalyzeSentiment(text string) models.SentimentResult {
 
 	// 分词（简单按单个字符分割）
 	words := splitWords(text)
+	lexicon := currentSentimentLexicon()
 
 	// 检查文本是否包含转折词，并分割成两部分
 	var transitionIndex int
 	var hasTransition bool
 	for i, word := range words {
-		if _, ok := transitionWords[word]; ok {
+		if _, ok := lexicon.transition[word]; ok {
 			transitionIndex = i
 			hasTransition = true
 			break
@@ -352,11 +366,11 @@ func AnalyzeSentiment(text string) models.SentimentResult {
 	if hasTransition {
 		// 转折前的部分
 		preTransitionWords := words[:transitionIndex]
-		preScore, prePos, preNeg := calculateScore(preTransitionWords)
+		preScore, prePos, preNeg := lexicon.calculateScore(preTransitionWords)
 
 		// 转折后的部分，权重加倍
 		postTransitionWords := words[transitionIndex+1:]
-		postScore, postPos, postNeg := calculateScore(postTransitionWords)
+		postScore, postPos, postNeg := lexicon.calculateScore(postTransitionWords)
 		postScore *= 1.5 // 转折后的情感更重要
 
 		score = preScore + postScore
@@ -364,7 +378,7 @@ func AnalyzeSentiment(text string) models.SentimentResult {
 		negativeCount = preNeg + postNeg
 	} else {
 		// 没有转折的文本
-		score, positiveCount, negativeCount = calculateScore(words)
+		score, positiveCount, negativeCount = lexicon.calculateScore(words)
 	}
 
 	// 确定情感类别
@@ -388,7 +402,7 @@ func AnalyzeSentiment(text string) models.SentimentResult {
 }
 
 // 计算情感得分
-func calculateScore(words []string) (float64, int, int) {
+func (l *sentimentLexicon) calculateScore(words []string) (float64, int, int) {
 	score := 0.0
 	positiveCount := 0
 	negativeCount := 0
@@ -396,23 +410,23 @@ func calculateScore(words []string) (float64, int, int) {
 	// 遍历每个词，计算情感得分
 	for i, word := range words {
 		// 首先检查是否为程度副词
-		degree, isDegree := degreeWords[word]
+		degree, isDegree := l.degree[word]
 
 		// 检查是否为否定词
-		_, isNegation := negationWords[word]
+		_, isNegation := l.negation[word]
 
 		// 检查是否为金融正面词
-		if posScore, isPositive := positiveFinanceWords[word]; isPositive {
+		if posScore, isPositive := l.positive[word]; isPositive {
 			// 检查前一个词是否为否定词或程度副词
 			if i > 0 {
 				prevWord := words[i-1]
-				if _, isNeg := negationWords[prevWord]; isNeg {
+				if _, isNeg := l.negation[prevWord]; isNeg {
 					score -= posScore
 					negativeCount++
 					continue
 				}
 
-				if deg, isDeg := degreeWords[prevWord]; isDeg {
+				if deg, isDeg := l.degree[prevWord]; isDeg {
 					score += posScore * deg
 					positiveCount++
 					continue
@@ -425,17 +439,17 @@ func calculateScore(words []string) (float64, int, int) {
 		}
 
 		// 检查是否为金融负面词
-		if negScore, isNegative := negativeFinanceWords[word]; isNegative {
+		if negScore, isNegative := l.negative[word]; isNegative {
 			// 检查前一个词是否为否定词或程度副词
 			if i > 0 {
 				prevWord := words[i-1]
-				if _, isNeg := negationWords[prevWord]; isNeg {
+				if _, isNeg := l.negation[prevWord]; isNeg {
 					score += negScore
 					positiveCount++
 					continue
 				}
 
-				if deg, isDeg := degreeWords[prevWord]; isDeg {
+				if deg, isDeg := l.degree[prevWord]; isDeg {
 					score -= negScore * deg
 					negativeCount++
 					continue
@@ -451,13 +465,13 @@ func calculateScore(words []string) (float64, int, int) {
 		if isDegree && i+1 < len(words) {
 			nextWord := words[i+1]
 
-			if posScore, isPositive := positiveFinanceWords[nextWord]; isPositive {
+			if posScore, isPositive := l.positive[nextWord]; isPositive {
 				score += posScore * degree
 				positiveCount++
 				continue
 			}
 
-			if negScore, isNegative := negativeFinanceWords[nextWord]; isNegative {
+			if negScore, isNegative := l.negative[nextWord]; isNegative {
 				score -= negScore * degree
 				negativeCount++
 				continue
@@ -468,13 +482,13 @@ func calculateScore(words []string) (float64, int, int) {
 		if isNegation && i+1 < len(words) {
 			nextWord := words[i+1]
 
-			if posScore, isPositive := positiveFinanceWords[nextWord]; isPositive {
+			if posScore, isPositive := l.positive[nextWord]; isPositive {
 				score -= posScore
 				negativeCount++
 				continue
 			}
 
-			if negScore, isNegative := negativeFinanceWords[nextWord]; isNegative {
+			if negScore, isNegative := l.negative[nextWord]; isNegative {
 				score += negScore
 				positiveCount++
 				continue
@@ -487,7 +501,41 @@ func calculateScore(words []string) (float64, int, int) {
 
 // 简单的分词函数，考虑了中文和英文
 func splitWords(text string) []string {
-	return seg.Cut(text, true)
+	if isEnglishText(text) {
+		return englishWords(text)
+	}
+	//分词词典未加载时退化为单字切分
+	if words, ok := segCut(text); ok {
+		return words
+	}
+	return unigramTokens(text)
+}
+
+// segLoaded 分词词典是否已加载
+func segLoaded() bool {
+	segMu.RLock()
+	defer segMu.RUnlock()
+	return seg.Dict != nil
+}
+
+// segCut 精确模式分词,词典未加载时 ok 为 false
+func segCut(text string) ([]string, bool) {
+	segMu.RLock()
+	defer segMu.RUnlock()
+	if seg.Dict == nil {
+		return nil, false
+	}
+	return seg.Cut(text, true), true
+}
+
+// segCutSearch 搜索模式分词,词典未加载时 ok 为 false
+func segCutSearch(text string) ([]string, bool) {
+	segMu.RLock()
+	defer segMu.RUnlock()
+	if seg.Dict == nil {
+		return nil, false
+	}
+	return seg.CutSearch(text, true), true
 }
 
 // GetSentimentDescription 获取情感类别的文本描述
@@ -0,0 +1,350 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"lumos-stock/backend/logger"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/duke-git/lancet/v2/mathutil"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/9/18 9:40
+// @Desc 交易流水:买入、卖出、分红、拆股(送转),持仓成本和已实现盈亏均由流水计算
+// -----------------------------------------------------------------------------------
+
+// 交易类型
+const (
+	TradeTypeBuy      = "buy"      //买入
+	TradeTypeSell     = "sell"     //卖出
+	TradeTypeDividend = "dividend" //现金分红,Amount 为到账金额
+	TradeTypeSplit    = "split"    //拆股/送转,Ratio 为每股变为多少股,如10送5为1.5
+)
+
+// openingTradeRemark 由旧的成本价/持仓量生成的期初持仓
+const openingTradeRemark = "期初持仓"
+
+// 持仓成本计算方式
+const (
+	CostMethodAverage = "average" //移动加权平均
+	CostMethodFIFO    = "fifo"    //先进先出
+)
+
+// StockTrade 交易流水,通过 StockCode 关联 FollowedStock
+type StockTrade struct {
+	gorm.Model
+	StockCode string    `json:"stockCode" gorm:"index"`
+	TradeType string    `json:"tradeType"`
+	TradeTime time.Time `json:"tradeTime" gorm:"index"`
+	Price     float64   `json:"price"`
+	Volume    int64     `json:"volume"`
+	Amount    float64   `json:"amount"`
+	Ratio     float64   `json:"ratio"`
+	Fee       float64   `json:"fee"` //佣金、印花税等费用合计
+	Remark    string    `json:"remark"`
+}
+
+func (StockTrade) TableName() string {
+	return "trade"
+}
+
+// PositionLot FIFO 持仓批次
+type PositionLot struct {
+	TradeTime time.Time `json:"tradeTime"`
+	Volume    int64     `json:"volume"`
+	Cost      float64   `json:"cost"` //每股成本(含费用)
+}
+
+// StockPosition 由交易流水计算的持仓
+type StockPosition struct {
+	StockCode      string        `json:"stockCode"`
+	Method         string        `json:"method"`
+	Volume         int64         `json:"volume"`
+	CostPrice      float64       `json:"costPrice"`
+	CostAmount     float64       `json:"costAmount"`
+	RealizedProfit float64       `json:"realizedProfit"` //已实现盈亏,含分红
+	Dividend       float64       `json:"dividend"`
+	TotalFee       float64       `json:"totalFee"`
+	Lots           []PositionLot `json:"lots"`
+}
+
+type TradeLedgerApi struct {
+}
+
+func NewTradeLedgerApi() *TradeLedgerApi {
+	return &TradeLedgerApi{}
+}
+
+func (t TradeLedgerApi) AddTrade(trade StockTrade) string {
+	trade.StockCode = normalizeAlertStockCode(trade.StockCode)
+	switch trade.TradeType {
+	case TradeTypeBuy, TradeTypeSell:
+		if trade.Price <= 0 || trade.Volume <= 0 {
+			return "价格和数量必须大于0"
+		}
+	case TradeTypeDividend:
+		if trade.Amount <= 0 {
+			return "分红金额必须大于0"
+		}
+	case TradeTypeSplit:
+		if trade.Ratio <= 0 {
+			return "拆股比例必须大于0"
+		}
+	default:
+		return "不支持的交易类型"
+	}
+	if trade.TradeTime.IsZero() {
+		trade.TradeTime = time.Now()
+	}
+	//补记或修改历史交易后,之后的卖出也不能超过当时的持仓
+	var trades []StockTrade
+	db.Dao.Model(&StockTrade{}).Where("stock_code = ? and id <> ?", trade.StockCode, trade.ID).Order("trade_time asc, id asc").Find(&trades)
+	if oversold(append(trades, trade)) {
+		return "卖出数量超过持仓数量"
+	}
+	var err error
+	if trade.ID > 0 {
+		err = db.Dao.Model(&StockTrade{}).Where("id = ?", trade.ID).Updates(map[string]any{
+			"trade_type": trade.TradeType,
+			"trade_time": trade.TradeTime,
+			"price":      trade.Price,
+			"volume":     trade.Volume,
+			"amount":     trade.Amount,
+			"ratio":      trade.Ratio,
+			"fee":        trade.Fee,
+			"remark":     trade.Remark,
+		}).Error
+	} else {
+		err = db.Dao.Create(&trade).Error
+	}
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+		return "保存失败"
+	}
+	t.syncFollowedStock(trade.StockCode)
+	return "保存成功"
+}
+
+func (t TradeLedgerApi) DeleteTrade(id uint) string {
+	trade := &StockTrade{}
+	db.Dao.Model(trade).Where("id = ?", id).First(trade)
+	if trade.ID == 0 {
+		return "交易记录不存在"
+	}
+	//删除买入后,之后的卖出不能超过当时的持仓
+	var trades []StockTrade
+	db.Dao.Model(&StockTrade{}).Where("stock_code = ? and id <> ?", trade.StockCode, id).Order("trade_time asc, id asc").Find(&trades)
+	if oversold(trades) {
+		return "删除后卖出数量将超过持仓数量,请先删除之后的卖出记录"
+	}
+	err := db.Dao.Delete(&StockTrade{}, id).Error
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+		return "删除失败"
+	}
+	t.syncFollowedStock(trade.StockCode)
+	return "删除成功"
+}
+
+func (t TradeLedgerApi) GetTrades(stockCode string) []StockTrade {
+	var trades []StockTrade
+	query := db.Dao.Model(&StockTrade{})
+	if stockCode != "" {
+		query = query.Where("stock_code = ?", normalizeAlertStockCode(stockCode))
+	}
+	query.Order("trade_time asc, id asc").Find(&trades)
+	return trades
+}
+
+// GetPosition 按交易流水计算持仓,method 为空时使用移动加权平均
+func (t TradeLedgerApi) GetPosition(stockCode, method string) StockPosition {
+	position := CalcPosition(t.GetTrades(stockCode), method)
+	position.StockCode = normalizeAlertStockCode(stockCode)
+	return position
+}
+
+// SetOpeningPosition 兼容旧的成本价/持仓量设置:
+// 流水中只有期初持仓时直接改写期初持仓,否则按数量差额补记买入或卖出
+func (t TradeLedgerApi) SetOpeningPosition(stockCode string, price float64, volume int64) string {
+	stockCode = normalizeAlertStockCode(stockCode)
+	trades := t.GetTrades(stockCode)
+	opening := true
+	for _, trade := range trades {
+		if trade.Remark != openingTradeRemark {
+			opening = false
+			break
+		}
+	}
+	if opening {
+		err := db.Dao.Where("stock_code = ?", stockCode).Delete(&StockTrade{}).Error
+		if err != nil {
+			logger.SugaredLogger.Error(err.Error())
+			return "设置失败"
+		}
+		if volume <= 0 || price <= 0 {
+			t.syncFollowedStock(stockCode)
+			return "设置成功"
+		}
+		return t.AddTrade(StockTrade{StockCode: stockCode, TradeType: TradeTypeBuy, Price: price, Volume: volume, Remark: openingTradeRemark})
+	}
+
+	position := CalcPosition(trades, CostMethodAverage)
+	diff := volume - position.Volume
+	switch {
+	case diff > 0:
+		//补记买入价使持仓成本等于设置的成本价
+		buyPrice := (price*float64(volume) - position.CostAmount) / float64(diff)
+		if buyPrice <= 0 {
+			return "成本价低于已有持仓成本,无法补记买入,请通过交易流水调整成本"
+		}
+		return t.AddTrade(StockTrade{StockCode: stockCode, TradeType: TradeTypeBuy, Price: mathutil.RoundToFloat(buyPrice, 4), Volume: diff, Remark: "持仓调整"})
+	case diff < 0:
+		return t.AddTrade(StockTrade{StockCode: stockCode, TradeType: TradeTypeSell, Price: price, Volume: -diff, Remark: "持仓调整"})
+	}
+	return "已有交易流水,请通过交易流水调整成本"
+}
+
+// syncFollowedStock 将流水计算的成本价和持仓量回写到关注股票,供旧的展示和提示词模板使用
+func (t TradeLedgerApi) syncFollowedStock(stockCode string) {
+	position := t.GetPosition(stockCode, CostMethodAverage)
+	err := db.Dao.Model(&FollowedStock{}).Where("stock_code = ?", stockCode).Updates(map[string]any{
+		"cost_price": position.CostPrice,
+		"volume":     position.Volume,
+	}).Error
+	if err != nil {
+		logger.SugaredLogger.Error(err.Error())
+	}
+}
+
+// MigrateLegacyPosition 将关注股票上旧的成本价和持仓量转为期初买入流水
+func (t TradeLedgerApi) MigrateLegacyPosition() {
+	var follows []FollowedStock
+	db.Dao.Model(&FollowedStock{}).Where("volume > 0 and cost_price > 0").Find(&follows)
+	for _, follow := range follows {
+		var count int64
+		db.Dao.Model(&StockTrade{}).Where("stock_code = ?", follow.StockCode).Count(&count)
+		if count > 0 {
+			continue
+		}
+		err := db.Dao.Create(&StockTrade{
+			StockCode: follow.StockCode,
+			TradeType: TradeTypeBuy,
+			TradeTime: follow.Time,
+			Price:     follow.CostPrice,
+			Volume:    follow.Volume,
+			Remark:    openingTradeRemark,
+		}).Error
+		if err != nil {
+			logger.SugaredLogger.Error(err.Error())
+		}
+	}
+}
+
+// CalcPosition 按时间顺序回放交易流水计算持仓、成本和已实现盈亏
+func CalcPosition(trades []StockTrade, method string) StockPosition {
+	if method != CostMethodFIFO {
+		method = CostMethodAverage
+	}
+	trades = append([]StockTrade(nil), trades...)
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].TradeTime.Before(trades[j].TradeTime)
+	})
+
+	position := StockPosition{Method: method}
+	costAmount := 0.0
+	var lots []PositionLot
+	for _, trade := range trades {
+		position.TotalFee += trade.Fee
+		switch trade.TradeType {
+		case TradeTypeBuy:
+			amount := trade.Price*float64(trade.Volume) + trade.Fee
+			costAmount += amount
+			position.Volume += trade.Volume
+			lots = append(lots, PositionLot{TradeTime: trade.TradeTime, Volume: trade.Volume, Cost: amount / float64(trade.Volume)})
+		case TradeTypeSell:
+			volume := min(trade.Volume, position.Volume)
+			if volume <= 0 {
+				continue
+			}
+			income := trade.Price*float64(volume) - trade.Fee
+			cost := 0.0
+			if method == CostMethodFIFO {
+				cost, lots = consumeLots(lots, volume)
+			} else {
+				cost = costAmount / float64(position.Volume) * float64(volume)
+				_, lots = consumeLots(lots, volume)
+			}
+			position.RealizedProfit += income - cost
+			costAmount -= cost
+			position.Volume -= volume
+		case TradeTypeDividend:
+			income := trade.Amount - trade.Fee
+			position.Dividend += income
+			position.RealizedProfit += income
+		case TradeTypeSplit:
+			if trade.Ratio <= 0 {
+				continue
+			}
+			position.Volume = 0
+			for i := range lots {
+				lots[i].Volume = int64(math.Round(float64(lots[i].Volume) * trade.Ratio))
+				lots[i].Cost /= trade.Ratio
+				position.Volume += lots[i].Volume
+			}
+		}
+		if position.Volume == 0 {
+			costAmount = 0
+			lots = nil
+		}
+	}
+	if method == CostMethodFIFO {
+		costAmount = 0
+		for _, lot := range lots {
+			costAmount += lot.Cost * float64(lot.Volume)
+		}
+	}
+	position.Lots = lots
+	position.CostAmount = mathutil.RoundToFloat(costAmount, 2)
+	if position.Volume > 0 {
+		position.CostPrice = mathutil.RoundToFloat(costAmount/float64(position.Volume), 4)
+	}
+	position.RealizedProfit = mathutil.RoundToFloat(position.RealizedProfit, 2)
+	position.Dividend = mathutil.RoundToFloat(position.Dividend, 2)
+	position.TotalFee = mathutil.RoundToFloat(position.TotalFee, 2)
+	return position
+}
+
+// oversold 按时间顺序回放流水,是否有卖出超过当时的持仓数量,同一时间的未保存流水排在最后
+func oversold(trades []StockTrade) bool {
+	trades = append([]StockTrade(nil), trades...)
+	sort.SliceStable(trades, func(i, j int) bool {
+		if !trades[i].TradeTime.Equal(trades[j].TradeTime) {
+			return trades[i].TradeTime.Before(trades[j].TradeTime)
+		}
+		return trades[j].ID == 0 && trades[i].ID != 0
+	})
+	for i, trade := range trades {
+		if trade.TradeType == TradeTypeSell && CalcPosition(trades[:i], CostMethodAverage).Volume < trade.Volume {
+			return true
+		}
+	}
+	return false
+}
+
+// consumeLots 先进先出扣减批次,返回扣减部分的成本
+func consumeLots(lots []PositionLot, volume int64) (float64, []PositionLot) {
+	cost := 0.0
+	for volume > 0 && len(lots) > 0 {
+		take := min(volume, lots[0].Volume)
+		cost += lots[0].Cost * float64(take)
+		lots[0].Volume -= take
+		volume -= take
+		if lots[0].Volume == 0 {
+			lots = lots[1:]
+		}
+	}
+	return cost, lots
+}
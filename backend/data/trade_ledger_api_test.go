@@ -0,0 +1,110 @@
+package data
+
+import (
+	"lumos-stock/backend/db"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/18 15:10
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func ledgerTrades() []StockTrade {
+	day := func(d int) time.Time {
+		return time.Date(2025, 9, d, 10, 0, 0, 0, time.Local)
+	}
+	return []StockTrade{
+		{StockCode: "sh600000", TradeType: TradeTypeBuy, TradeTime: day(1), Price: 10, Volume: 1000, Fee: 5},
+		{StockCode: "sh600000", TradeType: TradeTypeBuy, TradeTime: day(2), Price: 12, Volume: 1000, Fee: 5},
+		{StockCode: "sh600000", TradeType: TradeTypeSell, TradeTime: day(3), Price: 13, Volume: 1000, Fee: 10},
+		{StockCode: "sh600000", TradeType: TradeTypeDividend, TradeTime: day(4), Amount: 200},
+	}
+}
+
+func TestCalcPositionAverage(t *testing.T) {
+	position := CalcPosition(ledgerTrades(), CostMethodAverage)
+	if position.Volume != 1000 {
+		t.Fatalf("unexpected volume %d", position.Volume)
+	}
+	// 平均成本 (10000+5+12000+5)/2000 = 11.005
+	if position.CostPrice != 11.005 {
+		t.Errorf("unexpected cost price %v", position.CostPrice)
+	}
+	// 卖出 13000-10-11005 = 1985,加分红 200
+	if position.RealizedProfit != 2185 || position.Dividend != 200 {
+		t.Errorf("unexpected realized profit %+v", position)
+	}
+}
+
+func TestCalcPositionFIFO(t *testing.T) {
+	position := CalcPosition(ledgerTrades(), CostMethodFIFO)
+	if position.Volume != 1000 || position.CostPrice != 12.005 {
+		t.Errorf("fifo should keep the second lot %+v", position)
+	}
+	// 卖出 13000-10-10005 = 2985,加分红 200
+	if position.RealizedProfit != 3185 {
+		t.Errorf("unexpected realized profit %v", position.RealizedProfit)
+	}
+}
+
+func TestCalcPositionSplit(t *testing.T) {
+	trades := append(ledgerTrades(), StockTrade{
+		TradeType: TradeTypeSplit,
+		TradeTime: time.Date(2025, 9, 5, 10, 0, 0, 0, time.Local),
+		Ratio:     2,
+	})
+	position := CalcPosition(trades, CostMethodAverage)
+	if position.Volume != 2000 || position.CostPrice != 5.5025 || position.CostAmount != 11005 {
+		t.Errorf("split should double volume and halve cost %+v", position)
+	}
+	trades = append(trades, StockTrade{
+		TradeType: TradeTypeSell,
+		TradeTime: time.Date(2025, 9, 6, 10, 0, 0, 0, time.Local),
+		Price:     6,
+		Volume:    2000,
+	})
+	position = CalcPosition(trades, CostMethodFIFO)
+	if position.Volume != 0 || position.CostPrice != 0 || len(position.Lots) != 0 {
+		t.Errorf("position should be closed %+v", position)
+	}
+}
+
+func TestOversold(t *testing.T) {
+	trades := ledgerTrades()
+	if oversold(trades) {
+		t.Fatal("ledger should be valid")
+	}
+	// 在第3天卖出之前补记卖出,当时持仓够卖,但之后第3天的卖出超过持仓
+	backDated := StockTrade{TradeType: TradeTypeSell, TradeTime: time.Date(2025, 9, 2, 14, 0, 0, 0, time.Local), Price: 11, Volume: 1500}
+	if !oversold(append(trades, backDated)) {
+		t.Error("back-dated sell should make the later sell oversell")
+	}
+	backDated.Volume = 1000
+	if oversold(append(trades, backDated)) {
+		t.Error("later sell still fits after a small back-dated sell")
+	}
+}
+
+func TestDeleteTradeKeepsLaterSells(t *testing.T) {
+	db.Init(filepath.Join(t.TempDir(), "ledger.db"))
+	db.Dao.AutoMigrate(&StockTrade{}, &FollowedStock{})
+	for _, trade := range ledgerTrades() {
+		db.Dao.Create(&trade)
+	}
+	api := NewTradeLedgerApi()
+	trades := api.GetTrades("sh600000")
+	// 第3天卖出1000股,删除任一买入后仍够卖
+	if msg := api.DeleteTrade(trades[0].ID); msg != "删除成功" {
+		t.Fatalf("unexpected delete result %s", msg)
+	}
+	trades = api.GetTrades("sh600000")
+	if msg := api.DeleteTrade(trades[0].ID); msg == "删除成功" {
+		t.Errorf("deleting the buy a later sell depends on should be rejected")
+	}
+	if len(api.GetTrades("sh600000")) != 3 {
+		t.Errorf("trade should not be deleted")
+	}
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"context"
+	"lumos-stock/backend/logger"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/27 9:30
+// @Desc 进程内事件总线:按主题发布事件,订阅者(Wails前端、日志、SSE等)各自决定如何处理
+// 业务代码只依赖总线,不直接调用 runtime.EventsEmit,因此可以脱离 webview 测试和复用
+// -----------------------------------------------------------------------------------
+
+// Event 总线上的事件,Topic 即前端监听的事件名
+type Event struct {
+	Topic   string    `json:"topic"`
+	Payload any       `json:"payload"`
+	Time    time.Time `json:"time"`
+}
+
+// Subscriber 事件订阅者
+type Subscriber interface {
+	Handle(event Event)
+}
+
+// SubscriberFunc 函数形式的订阅者
+type SubscriberFunc func(event Event)
+
+func (f SubscriberFunc) Handle(event Event) {
+	f(event)
+}
+
+type subscription struct {
+	id         uint64
+	topics     []string
+	subscriber Subscriber
+}
+
+// Bus 事件总线,发布时同步调用订阅者,耗时的订阅者应使用 Async 包装
+type Bus struct {
+	mu            sync.RWMutex
+	nextID        uint64
+	subscriptions []subscription
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe 订阅指定主题,不传主题时订阅全部,返回的函数用于取消订阅
+func (b *Bus) Subscribe(subscriber Subscriber, topics ...string) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.subscriptions = append(b.subscriptions, subscription{id: id, topics: topics, subscriber: subscriber})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.subscriptions = slices.DeleteFunc(b.subscriptions, func(s subscription) bool {
+			return s.id == id
+		})
+	}
+}
+
+// Publish 发布事件,总线为 nil 时忽略
+func (b *Bus) Publish(topic string, payload any) {
+	if b == nil {
+		return
+	}
+	event := Event{Topic: topic, Payload: payload, Time: time.Now()}
+	b.mu.RLock()
+	subscriptions := slices.Clone(b.subscriptions)
+	b.mu.RUnlock()
+	for _, s := range subscriptions {
+		if len(s.topics) > 0 && !slices.Contains(s.topics, topic) {
+			continue
+		}
+		dispatch(s.subscriber, event)
+	}
+}
+
+// dispatch 单个订阅者出错不影响其他订阅者
+func dispatch(subscriber Subscriber, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.SugaredLogger.Errorf("event %s subscriber panic: %v\n%s", event.Topic, r, debug.Stack())
+		}
+	}()
+	subscriber.Handle(event)
+}
+
+// Topic 带类型的主题,保证同一事件的载荷类型一致
+type Topic[T any] struct {
+	name string
+}
+
+func NewTopic[T any](name string) Topic[T] {
+	return Topic[T]{name: name}
+}
+
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+func (t Topic[T]) Publish(bus *Bus, payload T) {
+	bus.Publish(t.name, payload)
+}
+
+// Subscribe 订阅该主题,载荷类型不符的事件忽略
+func (t Topic[T]) Subscribe(bus *Bus, handler func(payload T)) func() {
+	return bus.Subscribe(SubscriberFunc(func(event Event) {
+		if payload, ok := event.Payload.(T); ok {
+			handler(payload)
+		}
+	}), t.name)
+}
+
+// StreamDone 流式主题的结束标记,与前端约定一致
+const StreamDone = "DONE"
+
+// StreamTopic 流式输出的主题,如AI分析,逐块推送后以 StreamDone 结束
+type StreamTopic[T any] struct {
+	Topic[T]
+}
+
+func NewStreamTopic[T any](name string) StreamTopic[T] {
+	return StreamTopic[T]{Topic: NewTopic[T](name)}
+}
+
+// Done 推送结束标记
+func (t StreamTopic[T]) Done(bus *Bus) {
+	bus.Publish(t.name, StreamDone)
+}
+
+type busKey struct{}
+
+// WithBus 将总线放入上下文,供只持有 context 的代码(如 OpenAi)发布事件
+func WithBus(ctx context.Context, bus *Bus) context.Context {
+	return context.WithValue(ctx, busKey{}, bus)
+}
+
+// FromContext 上下文中的总线,没有时返回 nil,发布到 nil 总线的事件被忽略
+func FromContext(ctx context.Context) *Bus {
+	if ctx == nil {
+		return nil
+	}
+	bus, _ := ctx.Value(busKey{}).(*Bus)
+	return bus
+}
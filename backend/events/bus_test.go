@@ -0,0 +1,137 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/27 14:00
+// @Desc
+//-----------------------------------------------------------------------------------
+
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Handle(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) topics() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var topics []string
+	for _, event := range r.events {
+		topics = append(topics, event.Topic)
+	}
+	return topics
+}
+
+func TestBusSubscribe(t *testing.T) {
+	bus := NewBus()
+	all, prices := &recorder{}, &recorder{}
+	bus.Subscribe(all)
+	cancel := bus.Subscribe(prices, "stock_price")
+
+	bus.Publish("stock_price", 1)
+	bus.Publish("newsPush", 2)
+	cancel()
+	bus.Publish("stock_price", 3)
+
+	if got := all.topics(); len(got) != 3 {
+		t.Errorf("subscriber without topics should receive all events, got %v", got)
+	}
+	if got := prices.topics(); len(got) != 1 || prices.events[0].Payload != 1 {
+		t.Errorf("unexpected topic subscriber events %v", prices.events)
+	}
+}
+
+func TestBusSubscriberPanic(t *testing.T) {
+	bus := NewBus()
+	bus.Subscribe(SubscriberFunc(func(event Event) {
+		panic("boom")
+	}))
+	r := &recorder{}
+	bus.Subscribe(r)
+	bus.Publish("warnMsg", "x")
+	if len(r.topics()) != 1 {
+		t.Errorf("panic in one subscriber should not block others")
+	}
+}
+
+func TestTypedTopic(t *testing.T) {
+	type tick struct {
+		Code  string
+		Price float64
+	}
+	topic := NewTopic[tick]("stock_price")
+	bus := NewBus()
+	var got []tick
+	topic.Subscribe(bus, func(payload tick) {
+		got = append(got, payload)
+	})
+	topic.Publish(bus, tick{Code: "sh600000", Price: 10})
+	bus.Publish(topic.Name(), "not a tick")
+	if len(got) != 1 || got[0].Code != "sh600000" {
+		t.Errorf("unexpected typed payloads %v", got)
+	}
+
+	// 未设置总线时发布不报错
+	topic.Publish(FromContext(context.Background()), tick{})
+}
+
+func TestStreamTopic(t *testing.T) {
+	topic := NewStreamTopic[map[string]any]("newChatStream")
+	bus := NewBus()
+	r := &recorder{}
+	bus.Subscribe(r, topic.Name())
+	topic.Publish(bus, map[string]any{"content": "a"})
+	topic.Done(bus)
+	if len(r.events) != 2 || r.events[1].Payload != StreamDone {
+		t.Errorf("stream should end with DONE %v", r.events)
+	}
+}
+
+func TestWithBus(t *testing.T) {
+	bus := NewBus()
+	ctx := WithBus(context.Background(), bus)
+	if FromContext(ctx) != bus {
+		t.Errorf("bus should be carried by context")
+	}
+	if FromContext(nil) != nil {
+		t.Errorf("nil context should have no bus")
+	}
+}
+
+func TestAsyncSubscriber(t *testing.T) {
+	r := &recorder{}
+	async := Async(r, 16)
+	bus := NewBus()
+	cancel := bus.Subscribe(async)
+	for i := 0; i < 10; i++ {
+		bus.Publish("tick", i)
+	}
+	cancel()
+	async.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for len(r.topics()) < 10 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) != 10 {
+		t.Fatalf("expected 10 events, got %d", len(r.events))
+	}
+	for i, event := range r.events {
+		if event.Payload != i {
+			t.Errorf("async subscriber should keep order, got %v at %d", event.Payload, i)
+		}
+	}
+}
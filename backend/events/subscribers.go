@@ -0,0 +1,80 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"lumos-stock/backend/logger"
+	"sync"
+
+	"github.com/duke-git/lancet/v2/strutil"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// @Author spark
+// @Date 2025/9/27 10:20
+// @Desc 订阅者适配:Wails前端、日志、异步队列
+// -----------------------------------------------------------------------------------
+
+// WailsSubscriber 转发到 Wails 前端,ctx 为 Wails 启动时传入的上下文
+type WailsSubscriber struct {
+	ctx context.Context
+}
+
+func NewWailsSubscriber(ctx context.Context) *WailsSubscriber {
+	return &WailsSubscriber{ctx: ctx}
+}
+
+func (w *WailsSubscriber) Handle(event Event) {
+	runtime.EventsEmit(w.ctx, event.Topic, event.Payload)
+}
+
+// LogSubscriber 记录事件日志,载荷过长时截断
+type LogSubscriber struct {
+	maxLength int
+}
+
+func NewLogSubscriber(maxLength int) *LogSubscriber {
+	return &LogSubscriber{maxLength: maxLength}
+}
+
+func (l *LogSubscriber) Handle(event Event) {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		logger.SugaredLogger.Infof("event %s: %v", event.Topic, event.Payload)
+		return
+	}
+	text := string(payload)
+	if l.maxLength > 0 && len([]rune(text)) > l.maxLength {
+		text = strutil.Substring(text, 0, uint(l.maxLength)) + "..."
+	}
+	logger.SugaredLogger.Infof("event %s: %s", event.Topic, text)
+}
+
+// AsyncSubscriber 通过队列异步调用订阅者,保持事件顺序,队列满时发布方等待
+type AsyncSubscriber struct {
+	subscriber Subscriber
+	queue      chan Event
+	closeOnce  sync.Once
+}
+
+// Async 包装耗时或可能阻塞的订阅者,如 Wails 前端和外部推送
+func Async(subscriber Subscriber, buffer int) *AsyncSubscriber {
+	a := &AsyncSubscriber{subscriber: subscriber, queue: make(chan Event, buffer)}
+	go func() {
+		for event := range a.queue {
+			dispatch(a.subscriber, event)
+		}
+	}()
+	return a
+}
+
+func (a *AsyncSubscriber) Handle(event Event) {
+	a.queue <- event
+}
+
+// Close 停止队列,取消订阅后调用
+func (a *AsyncSubscriber) Close() {
+	a.closeOnce.Do(func() {
+		close(a.queue)
+	})
+}
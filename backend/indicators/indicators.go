@@ -0,0 +1,378 @@
+package indicators
+
+import (
+	"lumos-stock/backend/data"
+	"math"
+
+	"github.com/duke-git/lancet/v2/convertor"
+	"github.com/duke-git/lancet/v2/mathutil"
+)
+
+// @Author spark
+// @Date 2025/9/12 9:30
+// @Desc 技术指标计算:MA/EMA、MACD、KDJ、RSI、BOLL、ATR、OBV、VWAP
+// 计算结果与通达信/同花顺常用公式保持一致。MA/BOLL/ATR/VWAP 等窗口指标数据不足的周期返回 NaN;
+// EMA/MACD/KDJ/RSI 为递推指标,从第一根K线起输出,前期数值受起点影响,需要预留足够的K线预热
+// -----------------------------------------------------------------------------------
+
+// Bar K线
+type Bar struct {
+	Day    string
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// FromKLine 转换K线数据,无法解析的数值按0处理
+func FromKLine(K []data.KLineData) []Bar {
+	bars := make([]Bar, 0, len(K))
+	for _, k := range K {
+		open, _ := convertor.ToFloat(k.Open)
+		high, _ := convertor.ToFloat(k.High)
+		low, _ := convertor.ToFloat(k.Low)
+		closePrice, _ := convertor.ToFloat(k.Close)
+		volume, _ := convertor.ToFloat(k.Volume)
+		bars = append(bars, Bar{Day: k.Day, Open: open, High: high, Low: low, Close: closePrice, Volume: volume})
+	}
+	return bars
+}
+
+func closes(bars []Bar) []float64 {
+	res := make([]float64, len(bars))
+	for i, bar := range bars {
+		res[i] = bar.Close
+	}
+	return res
+}
+
+func nanSlice(n int) []float64 {
+	res := make([]float64, n)
+	for i := range res {
+		res[i] = math.NaN()
+	}
+	return res
+}
+
+// MA 简单移动平均
+func MA(values []float64, n int) []float64 {
+	res := nanSlice(len(values))
+	if n <= 0 {
+		return res
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= n {
+			sum -= values[i-n]
+		}
+		if i >= n-1 {
+			res[i] = sum / float64(n)
+		}
+	}
+	return res
+}
+
+// EMA 指数移动平均,以第一个值作为初始值
+func EMA(values []float64, n int) []float64 {
+	res := nanSlice(len(values))
+	if n <= 0 || len(values) == 0 {
+		return res
+	}
+	alpha := 2.0 / float64(n+1)
+	res[0] = values[0]
+	for i := 1; i < len(values); i++ {
+		res[i] = alpha*values[i] + (1-alpha)*res[i-1]
+	}
+	return res
+}
+
+// sma 通达信 SMA(X,N,M) 加权移动平均
+func sma(values []float64, n, m int, init float64) []float64 {
+	res := make([]float64, len(values))
+	prev := init
+	for i, v := range values {
+		if math.IsNaN(v) {
+			res[i] = math.NaN()
+			continue
+		}
+		prev = (float64(m)*v + float64(n-m)*prev) / float64(n)
+		res[i] = prev
+	}
+	return res
+}
+
+// MACD 返回 DIF、DEA 以及 MACD 柱(2*(DIF-DEA))
+func MACD(values []float64, fast, slow, signal int) (dif, dea, hist []float64) {
+	emaFast := EMA(values, fast)
+	emaSlow := EMA(values, slow)
+	dif = make([]float64, len(values))
+	for i := range values {
+		dif[i] = emaFast[i] - emaSlow[i]
+	}
+	dea = EMA(dif, signal)
+	hist = make([]float64, len(values))
+	for i := range values {
+		hist[i] = 2 * (dif[i] - dea[i])
+	}
+	return dif, dea, hist
+}
+
+// KDJ 随机指标,K、D 初始值为50
+func KDJ(bars []Bar, n, m1, m2 int) (k, d, j []float64) {
+	rsv := nanSlice(len(bars))
+	for i := range bars {
+		if i < n-1 {
+			continue
+		}
+		high, low := bars[i].High, bars[i].Low
+		for x := i - n + 1; x <= i; x++ {
+			high = math.Max(high, bars[x].High)
+			low = math.Min(low, bars[x].Low)
+		}
+		if high == low {
+			rsv[i] = 50
+		} else {
+			rsv[i] = (bars[i].Close - low) / (high - low) * 100
+		}
+	}
+	k = sma(rsv, m1, 1, 50)
+	d = sma(k, m2, 1, 50)
+	j = make([]float64, len(bars))
+	for i := range bars {
+		j[i] = 3*k[i] - 2*d[i]
+	}
+	return k, d, j
+}
+
+// RSI 相对强弱指标(Wilder平滑)
+func RSI(values []float64, n int) []float64 {
+	res := nanSlice(len(values))
+	if len(values) < 2 {
+		return res
+	}
+	up := nanSlice(len(values))
+	all := nanSlice(len(values))
+	for i := 1; i < len(values); i++ {
+		diff := values[i] - values[i-1]
+		up[i] = math.Max(diff, 0)
+		all[i] = math.Abs(diff)
+	}
+	upAvg := sma(up, n, 1, 0)
+	allAvg := sma(all, n, 1, 0)
+	for i := n; i < len(values); i++ {
+		if allAvg[i] == 0 {
+			res[i] = 50
+			continue
+		}
+		res[i] = upAvg[i] / allAvg[i] * 100
+	}
+	return res
+}
+
+// BOLL 布林线,返回中轨、上轨、下轨
+func BOLL(values []float64, n int, k float64) (mid, upper, lower []float64) {
+	mid = MA(values, n)
+	upper = nanSlice(len(values))
+	lower = nanSlice(len(values))
+	for i := n - 1; i < len(values); i++ {
+		variance := 0.0
+		for x := i - n + 1; x <= i; x++ {
+			variance += math.Pow(values[x]-mid[i], 2)
+		}
+		std := math.Sqrt(variance / float64(n))
+		upper[i] = mid[i] + k*std
+		lower[i] = mid[i] - k*std
+	}
+	return mid, upper, lower
+}
+
+// ATR 平均真实波幅(Wilder平滑)
+func ATR(bars []Bar, n int) []float64 {
+	tr := nanSlice(len(bars))
+	for i, bar := range bars {
+		if i == 0 {
+			tr[i] = bar.High - bar.Low
+			continue
+		}
+		prevClose := bars[i-1].Close
+		tr[i] = math.Max(bar.High-bar.Low, math.Max(math.Abs(bar.High-prevClose), math.Abs(bar.Low-prevClose)))
+	}
+	res := nanSlice(len(bars))
+	if len(bars) < n || n <= 0 {
+		return res
+	}
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		sum += tr[i]
+	}
+	res[n-1] = sum / float64(n)
+	for i := n; i < len(bars); i++ {
+		res[i] = (res[i-1]*float64(n-1) + tr[i]) / float64(n)
+	}
+	return res
+}
+
+// OBV 能量潮,自第一根K线起累计,数值取决于起点,只用于看走势和背离
+func OBV(bars []Bar) []float64 {
+	res := make([]float64, len(bars))
+	for i := 1; i < len(bars); i++ {
+		switch {
+		case bars[i].Close > bars[i-1].Close:
+			res[i] = res[i-1] + bars[i].Volume
+		case bars[i].Close < bars[i-1].Close:
+			res[i] = res[i-1] - bars[i].Volume
+		default:
+			res[i] = res[i-1]
+		}
+	}
+	return res
+}
+
+// VWAP 最近 n 根K线的成交量加权平均价,按典型价格 (H+L+C)/3 计算,不足 n 根时返回 NaN
+func VWAP(bars []Bar, n int) []float64 {
+	res := nanSlice(len(bars))
+	if n <= 0 {
+		return res
+	}
+	pv, volume := 0.0, 0.0
+	for i, bar := range bars {
+		pv += (bar.High + bar.Low + bar.Close) / 3 * bar.Volume
+		volume += bar.Volume
+		if i >= n {
+			old := bars[i-n]
+			pv -= (old.High + old.Low + old.Close) / 3 * old.Volume
+			volume -= old.Volume
+		}
+		if i >= n-1 && volume > 0 {
+			res[i] = pv / volume
+		}
+	}
+	return res
+}
+
+// Row 单根K线的指标值,数据不足时为 nil
+type Row struct {
+	Day    string   `json:"day" md:"日期"`
+	Close  float64  `json:"close" md:"收盘价"`
+	MA5    *float64 `json:"ma5" md:"MA5"`
+	MA10   *float64 `json:"ma10" md:"MA10"`
+	MA20   *float64 `json:"ma20" md:"MA20"`
+	MA60   *float64 `json:"ma60" md:"MA60"`
+	EMA12  *float64 `json:"ema12" md:"EMA12"`
+	EMA26  *float64 `json:"ema26" md:"EMA26"`
+	DIF    *float64 `json:"dif" md:"DIF"`
+	DEA    *float64 `json:"dea" md:"DEA"`
+	MACD   *float64 `json:"macd" md:"MACD"`
+	K      *float64 `json:"k" md:"K"`
+	D      *float64 `json:"d" md:"D"`
+	J      *float64 `json:"j" md:"J"`
+	RSI6   *float64 `json:"rsi6" md:"RSI6"`
+	RSI12  *float64 `json:"rsi12" md:"RSI12"`
+	RSI24  *float64 `json:"rsi24" md:"RSI24"`
+	BOLL   *float64 `json:"boll" md:"BOLL中轨"`
+	UB     *float64 `json:"ub" md:"BOLL上轨"`
+	LB     *float64 `json:"lb" md:"BOLL下轨"`
+	ATR    *float64 `json:"atr" md:"ATR14"`
+	OBV    *float64 `json:"obv" md:"OBV"`
+	VWAP   *float64 `json:"vwap" md:"VWAP20"`
+	Volume float64  `json:"volume" md:"-"`
+}
+
+// Result 指标计算结果
+type Result struct {
+	Rows    []Row    `json:"rows"`
+	Signals []string `json:"signals"`
+}
+
+func value(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	r := mathutil.RoundToFloat(v, 3)
+	return &r
+}
+
+// Calculate 计算全部指标并给出最新一根K线的信号
+func Calculate(K []data.KLineData) *Result {
+	bars := FromKLine(K)
+	c := closes(bars)
+	ma5, ma10, ma20, ma60 := MA(c, 5), MA(c, 10), MA(c, 20), MA(c, 60)
+	ema12, ema26 := EMA(c, 12), EMA(c, 26)
+	dif, dea, hist := MACD(c, 12, 26, 9)
+	k, d, j := KDJ(bars, 9, 3, 3)
+	rsi6, rsi12, rsi24 := RSI(c, 6), RSI(c, 12), RSI(c, 24)
+	mid, upper, lower := BOLL(c, 20, 2)
+	atr := ATR(bars, 14)
+	obv := OBV(bars)
+	vwap := VWAP(bars, 20)
+
+	rows := make([]Row, len(bars))
+	for i, bar := range bars {
+		rows[i] = Row{
+			Day:    bar.Day,
+			Close:  bar.Close,
+			Volume: bar.Volume,
+			MA5:    value(ma5[i]),
+			MA10:   value(ma10[i]),
+			MA20:   value(ma20[i]),
+			MA60:   value(ma60[i]),
+			EMA12:  value(ema12[i]),
+			EMA26:  value(ema26[i]),
+			DIF:    value(dif[i]),
+			DEA:    value(dea[i]),
+			MACD:   value(hist[i]),
+			K:      value(k[i]),
+			D:      value(d[i]),
+			J:      value(j[i]),
+			RSI6:   value(rsi6[i]),
+			RSI12:  value(rsi12[i]),
+			RSI24:  value(rsi24[i]),
+			BOLL:   value(mid[i]),
+			UB:     value(upper[i]),
+			LB:     value(lower[i]),
+			ATR:    value(atr[i]),
+			OBV:    value(obv[i]),
+			VWAP:   value(vwap[i]),
+		}
+	}
+	return &Result{Rows: rows, Signals: Signals(rows)}
+}
+
+// Latest 最近 n 根K线的指标
+func (r *Result) Latest(n int) []Row {
+	if n <= 0 || n >= len(r.Rows) {
+		return r.Rows
+	}
+	return r.Rows[len(r.Rows)-n:]
+}
+
+// warmUpBars 计算 MA60 等长周期指标需要的额外K线数量
+const warmUpBars = 120
+
+// GetStockIndicators 读取本地日K线计算指标,返回最近 days 根K线的指标值
+// OBV 以返回区间第一根K线为0,同一区间的数值不受预热K线数量影响
+func GetStockIndicators(stockCode string, days int64) *Result {
+	if days <= 0 {
+		days = 30
+	}
+	K := data.NewKLineStoreApi().GetKLine(stockCode, data.KLinePeriodDay, days+warmUpBars)
+	res := Calculate(*K)
+	res.Rows = res.Latest(int(days))
+	rebaseOBV(res.Rows)
+	return res
+}
+
+// rebaseOBV OBV 以第一行为基准归零
+func rebaseOBV(rows []Row) {
+	if len(rows) == 0 || rows[0].OBV == nil {
+		return
+	}
+	base := *rows[0].OBV
+	for i := range rows {
+		if rows[i].OBV != nil {
+			rows[i].OBV = value(*rows[i].OBV - base)
+		}
+	}
+}
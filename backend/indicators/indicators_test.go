@@ -0,0 +1,92 @@
+package indicators
+
+import (
+	"lumos-stock/backend/data"
+	"lumos-stock/backend/util"
+	"math"
+	"testing"
+
+	"github.com/duke-git/lancet/v2/convertor"
+)
+
+// @Author spark
+// @Date 2025/9/12 14:20
+// @Desc
+//-----------------------------------------------------------------------------------
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestMA(t *testing.T) {
+	res := MA([]float64{1, 2, 3, 4, 5}, 3)
+	if !math.IsNaN(res[1]) || !almostEqual(res[2], 2) || !almostEqual(res[4], 4) {
+		t.Errorf("unexpected ma %v", res)
+	}
+	ema := EMA([]float64{1, 2, 3}, 3)
+	if !almostEqual(ema[1], 1.5) || !almostEqual(ema[2], 2.25) {
+		t.Errorf("unexpected ema %v", ema)
+	}
+}
+
+func TestRSIAndBOLL(t *testing.T) {
+	up := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	rsi := RSI(up, 6)
+	if !almostEqual(rsi[7], 100) {
+		t.Errorf("rising prices should have rsi 100, got %v", rsi[7])
+	}
+	mid, upper, lower := BOLL([]float64{2, 2, 2, 2}, 4, 2)
+	if !almostEqual(mid[3], 2) || !almostEqual(upper[3], 2) || !almostEqual(lower[3], 2) {
+		t.Errorf("flat prices should have zero width bands")
+	}
+}
+
+func TestOBVAndVWAP(t *testing.T) {
+	bars := []Bar{
+		{Close: 10, High: 10, Low: 10, Volume: 100},
+		{Close: 11, High: 11, Low: 11, Volume: 200},
+		{Close: 10.5, High: 10.5, Low: 10.5, Volume: 50},
+	}
+	obv := OBV(bars)
+	if obv[1] != 200 || obv[2] != 150 {
+		t.Errorf("unexpected obv %v", obv)
+	}
+	vwap := VWAP(bars, 2)
+	want := (11*200 + 10.5*50) / 250.0
+	if !math.IsNaN(vwap[0]) || !almostEqual(vwap[2], want) {
+		t.Errorf("unexpected vwap %v want %v", vwap, want)
+	}
+	rows := []Row{{OBV: value(obv[1])}, {OBV: value(obv[2])}}
+	rebaseOBV(rows)
+	if *rows[0].OBV != 0 || *rows[1].OBV != -50 {
+		t.Errorf("unexpected rebased obv %v %v", *rows[0].OBV, *rows[1].OBV)
+	}
+	atr := ATR(bars, 2)
+	if !almostEqual(atr[1], 0.5) {
+		t.Errorf("unexpected atr %v", atr)
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	K := make([]data.KLineData, 0, 80)
+	for i := 0; i < 80; i++ {
+		price := 10 + math.Sin(float64(i)/5)
+		K = append(K, data.KLineData{
+			Day:    convertor.ToString(i),
+			Open:   convertor.ToString(price),
+			High:   convertor.ToString(price + 0.2),
+			Low:    convertor.ToString(price - 0.2),
+			Close:  convertor.ToString(price),
+			Volume: "1000",
+		})
+	}
+	res := Calculate(K)
+	if len(res.Rows) != 80 {
+		t.Fatalf("unexpected rows %d", len(res.Rows))
+	}
+	if res.Rows[0].MA5 != nil || res.Rows[79].MA60 == nil {
+		t.Error("warm up values should be nil")
+	}
+	t.Log(res.Signals)
+	t.Log(util.MarkdownTable(res.Latest(5)))
+}
@@ -0,0 +1,105 @@
+package indicators
+
+import "fmt"
+
+// @Author spark
+// @Date 2025/9/12 11:02
+// @Desc 根据最新指标值生成技术信号描述
+// -----------------------------------------------------------------------------------
+
+// Signals 最新一根K线的技术信号
+func Signals(rows []Row) []string {
+	signals := make([]string, 0)
+	if len(rows) < 2 {
+		return signals
+	}
+	last, prev := rows[len(rows)-1], rows[len(rows)-2]
+
+	if ok(last.MA5, last.MA10, last.MA20) {
+		switch {
+		case *last.MA5 > *last.MA10 && *last.MA10 > *last.MA20:
+			signals = append(signals, "均线多头排列(MA5>MA10>MA20)")
+		case *last.MA5 < *last.MA10 && *last.MA10 < *last.MA20:
+			signals = append(signals, "均线空头排列(MA5<MA10<MA20)")
+		}
+	}
+	if ok(last.MA5, last.MA10, prev.MA5, prev.MA10) {
+		if *prev.MA5 <= *prev.MA10 && *last.MA5 > *last.MA10 {
+			signals = append(signals, "MA5上穿MA10")
+		}
+		if *prev.MA5 >= *prev.MA10 && *last.MA5 < *last.MA10 {
+			signals = append(signals, "MA5下穿MA10")
+		}
+	}
+
+	if ok(last.DIF, last.DEA, prev.DIF, prev.DEA) {
+		if *prev.DIF <= *prev.DEA && *last.DIF > *last.DEA {
+			signals = append(signals, fmt.Sprintf("MACD金叉(DIF %.3f 上穿 DEA %.3f)", *last.DIF, *last.DEA))
+		}
+		if *prev.DIF >= *prev.DEA && *last.DIF < *last.DEA {
+			signals = append(signals, fmt.Sprintf("MACD死叉(DIF %.3f 下穿 DEA %.3f)", *last.DIF, *last.DEA))
+		}
+		if *last.DIF > 0 && *last.DEA > 0 {
+			signals = append(signals, "MACD位于零轴上方")
+		} else if *last.DIF < 0 && *last.DEA < 0 {
+			signals = append(signals, "MACD位于零轴下方")
+		}
+	}
+
+	if ok(last.K, last.D, last.J, prev.K, prev.D) {
+		if *prev.K <= *prev.D && *last.K > *last.D {
+			signals = append(signals, "KDJ金叉")
+		}
+		if *prev.K >= *prev.D && *last.K < *last.D {
+			signals = append(signals, "KDJ死叉")
+		}
+		if *last.J > 100 {
+			signals = append(signals, fmt.Sprintf("KDJ超买(J=%.2f)", *last.J))
+		} else if *last.J < 0 {
+			signals = append(signals, fmt.Sprintf("KDJ超卖(J=%.2f)", *last.J))
+		}
+	}
+
+	if ok(last.RSI6) {
+		if *last.RSI6 >= 80 {
+			signals = append(signals, fmt.Sprintf("RSI6超买(%.2f)", *last.RSI6))
+		} else if *last.RSI6 <= 20 {
+			signals = append(signals, fmt.Sprintf("RSI6超卖(%.2f)", *last.RSI6))
+		}
+	}
+
+	if ok(last.UB, last.LB, last.BOLL) {
+		switch {
+		case last.Close > *last.UB:
+			signals = append(signals, "收盘价突破布林上轨")
+		case last.Close < *last.LB:
+			signals = append(signals, "收盘价跌破布林下轨")
+		case last.Close > *last.BOLL:
+			signals = append(signals, "收盘价位于布林中轨上方")
+		default:
+			signals = append(signals, "收盘价位于布林中轨下方")
+		}
+	}
+
+	if ok(last.VWAP) {
+		if last.Close > *last.VWAP {
+			signals = append(signals, fmt.Sprintf("收盘价高于VWAP20(%.3f)", *last.VWAP))
+		} else {
+			signals = append(signals, fmt.Sprintf("收盘价低于VWAP20(%.3f)", *last.VWAP))
+		}
+	}
+
+	if ok(last.ATR) && last.Close > 0 {
+		signals = append(signals, fmt.Sprintf("ATR14=%.3f,占收盘价%.2f%%", *last.ATR, *last.ATR/last.Close*100))
+	}
+	return signals
+}
+
+func ok(values ...*float64) bool {
+	for _, v := range values {
+		if v == nil {
+			return false
+		}
+	}
+	return true
+}
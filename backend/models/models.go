@@ -216,6 +216,8 @@ type PromptTemplate struct {
 	Name      string `json:"name"`
 	Content   string `json:"content"`
 	Type      string `json:"type"`
+	//AI分析使用的上下文来源,逗号分隔,为空时使用全部来源
+	ContextSources string `json:"contextSources"`
 }
 
 func (p PromptTemplate) TableName() string {
@@ -223,10 +225,11 @@ func (p PromptTemplate) TableName() string {
 }
 
 type Prompt struct {
-	ID      int    `json:"ID"`
-	Name    string `json:"name"`
-	Content string `json:"content"`
-	Type    string `json:"type"`
+	ID             int    `json:"ID"`
+	Name           string `json:"name"`
+	Content        string `json:"content"`
+	Type           string `json:"type"`
+	ContextSources string `json:"contextSources"`
 }
 
 type Telegraph struct {
@@ -234,7 +237,7 @@ type Telegraph struct {
 	Time            string          `json:"time"`
 	DataTime        *time.Time      `json:"dataTime" gorm:"index"`
 	Title           string          `json:"title" gorm:"index"`
-	Content         string          `json:"content" gorm:"index"`
+	Content         string          `json:"content" gorm:"index"` //入库去重按内容查询,全文检索使用 telegraph_fts
 	SubjectTags     []string        `json:"subjects" gorm:"-:all"`
 	StocksTags      []string        `json:"stocks" gorm:"-:all"`
 	IsRed           bool            `json:"isRed" gorm:"index"`
@@ -242,6 +245,9 @@ type Telegraph struct {
 	Source          string          `json:"source" gorm:"index"`
 	TelegraphTags   []TelegraphTags `json:"tags" gorm:"-:migration;foreignKey:TelegraphId"`
 	SentimentResult string          `json:"sentimentResult" gorm:"index"`
+	SimHash         int64           `json:"-" gorm:"index"`
+	ClusterId       uint            `json:"clusterId" gorm:"index"` //重复资讯指向首条报道,首条报道为 0
+	AltSources      []string        `json:"altSources" gorm:"-:all"`
 }
 type TelegraphTags struct {
 	gorm.Model
@@ -0,0 +1,280 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"lumos-stock/backend/logger"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// @Author spark
+// @Date 2025/9/28 9:30
+// @Desc 定时任务调度:统一管理 cron,按名称注册任务,记录每个任务的运行状态并持久化,支持暂停、恢复和立即执行
+// -----------------------------------------------------------------------------------
+
+// specParser 与 cron.WithSeconds 一致:秒 分 时 日 月 周,支持 @every 等描述符
+var specParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+var (
+	ErrJobNotFound = errors.New("任务不存在")
+	ErrJobRunning  = errors.New("任务正在运行")
+)
+
+// ScheduledJob 任务定义及最近一次运行情况
+type ScheduledJob struct {
+	gorm.Model
+	Name         string     `json:"name" gorm:"uniqueIndex"`
+	Spec         string     `json:"spec"`
+	Description  string     `json:"description"`
+	Paused       bool       `json:"paused"`
+	LastRunAt    *time.Time `json:"lastRunAt"`
+	LastDuration int64      `json:"lastDuration"` //毫秒
+	LastError    string     `json:"lastError"`
+	RunCount     int64      `json:"runCount"`
+	ErrorCount   int64      `json:"errorCount"`
+}
+
+func (ScheduledJob) TableName() string {
+	return "scheduled_job"
+}
+
+// JobStatus 任务状态
+type JobStatus struct {
+	ScheduledJob
+	Running   bool       `json:"running"`
+	NextRunAt *time.Time `json:"nextRunAt"`
+}
+
+type job struct {
+	def      ScheduledJob
+	fn       func() error
+	schedule cron.Schedule
+	entryID  cron.EntryID
+	running  bool
+}
+
+// Scheduler 任务调度器,任务注册表并发安全
+type Scheduler struct {
+	mu    sync.Mutex
+	cron  *cron.Cron
+	store Store
+	jobs  map[string]*job
+}
+
+// New 创建调度器,store 为 nil 时不持久化
+func New(store Store) *Scheduler {
+	return &Scheduler{
+		cron:  cron.New(cron.WithSeconds()),
+		store: store,
+		jobs:  make(map[string]*job),
+	}
+}
+
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop 停止调度,等待运行中的任务结束
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+// Register 注册任务,同名任务已存在时替换执行周期和执行函数,保留暂停状态和运行记录
+func (s *Scheduler) Register(name, spec, description string, fn func() error) error {
+	schedule, err := specParser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("任务 %s 执行周期 %s 错误:%w", name, spec, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if ok {
+		s.cron.Remove(j.entryID)
+		j.entryID = 0
+	} else {
+		j = &job{def: ScheduledJob{Name: name}}
+		if s.store != nil {
+			if saved, found := s.store.Load(name); found {
+				j.def = saved
+			}
+		}
+		s.jobs[name] = j
+	}
+	j.def.Spec = spec
+	j.def.Description = description
+	j.fn = fn
+	j.schedule = schedule
+	s.registerLocked(j)
+	s.save(j)
+	return nil
+}
+
+// registerLocked 未暂停时按当前执行周期加入 cron,调用方需持有 s.mu
+func (s *Scheduler) registerLocked(j *job) {
+	if j.def.Paused {
+		return
+	}
+	name := j.def.Name
+	j.entryID = s.cron.Schedule(j.schedule, cron.FuncJob(func() { s.run(name) }))
+}
+
+// Remove 移除任务,持久化的定义一并删除
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return
+	}
+	s.cron.Remove(j.entryID)
+	delete(s.jobs, name)
+	if s.store != nil {
+		if err := s.store.Delete(name); err != nil {
+			logger.SugaredLogger.Errorf("删除任务 %s 失败:%s", name, err.Error())
+		}
+	}
+}
+
+// Pause 暂停任务,不影响正在执行的本次运行
+func (s *Scheduler) Pause(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	s.cron.Remove(j.entryID)
+	j.entryID = 0
+	j.def.Paused = true
+	s.save(j)
+	return nil
+}
+
+// Resume 恢复已暂停的任务
+func (s *Scheduler) Resume(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if !j.def.Paused {
+		return nil
+	}
+	j.def.Paused = false
+	s.registerLocked(j)
+	s.save(j)
+	return nil
+}
+
+// RunNow 立即执行一次,不影响定时计划
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	running := ok && j.running
+	s.mu.Unlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+	if running {
+		return ErrJobRunning
+	}
+	go s.run(name)
+	return nil
+}
+
+// List 所有任务状态,按名称排序
+func (s *Scheduler) List() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		res = append(res, s.status(j))
+	}
+	sort.Slice(res, func(i, k int) bool {
+		return res[i].Name < res[k].Name
+	})
+	return res
+}
+
+// Get 单个任务状态
+func (s *Scheduler) Get(name string) (JobStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return s.status(j), true
+}
+
+func (s *Scheduler) status(j *job) JobStatus {
+	status := JobStatus{ScheduledJob: j.def, Running: j.running}
+	if !j.def.Paused {
+		next := j.schedule.Next(time.Now())
+		status.NextRunAt = &next
+	}
+	return status
+}
+
+// run 执行任务并记录运行情况,上一次运行未结束时跳过本次
+func (s *Scheduler) run(name string) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	if !ok || j.running {
+		s.mu.Unlock()
+		if ok {
+			logger.SugaredLogger.Infof("任务 %s 上次运行未结束,跳过", name)
+		}
+		return
+	}
+	j.running = true
+	fn := j.fn
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := call(fn)
+	duration := time.Since(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.running = false
+	j.def.LastRunAt = &start
+	j.def.LastDuration = duration.Milliseconds()
+	j.def.RunCount++
+	j.def.LastError = ""
+	if err != nil {
+		j.def.LastError = err.Error()
+		j.def.ErrorCount++
+		logger.SugaredLogger.Errorf("任务 %s 运行失败:%s", name, err.Error())
+	}
+	if s.jobs[name] == j {
+		s.save(j)
+	}
+}
+
+func call(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.SugaredLogger.Errorf("panic: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func (s *Scheduler) save(j *job) {
+	if s.store == nil {
+		return
+	}
+	if err := s.store.Save(&j.def); err != nil {
+		logger.SugaredLogger.Errorf("保存任务 %s 失败:%s", j.def.Name, err.Error())
+	}
+}
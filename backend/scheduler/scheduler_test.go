@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/28 14:10
+// @Desc
+//-----------------------------------------------------------------------------------
+
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]ScheduledJob
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{jobs: make(map[string]ScheduledJob)}
+}
+
+func (m *memoryStore) Load(name string) (ScheduledJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[name]
+	return job, ok
+}
+
+func (m *memoryStore) Save(job *ScheduledJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.Name] = *job
+	return nil
+}
+
+func (m *memoryStore) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.jobs, name)
+	return nil
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSchedulerRegister(t *testing.T) {
+	s := New(nil)
+	if err := s.Register("bad", "* * *", "", func() error { return nil }); err == nil {
+		t.Errorf("invalid spec should be rejected")
+	}
+	if err := s.Register("job", "@every 1h", "测试任务", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("job", "0 0 2 * * *", "测试任务", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	jobs := s.List()
+	if len(jobs) != 1 || jobs[0].Spec != "0 0 2 * * *" || jobs[0].NextRunAt == nil {
+		t.Errorf("re-register should replace the spec %+v", jobs)
+	}
+	s.Remove("job")
+	if len(s.List()) != 0 {
+		t.Errorf("job should be removed")
+	}
+}
+
+func TestSchedulerRunNow(t *testing.T) {
+	store := newMemoryStore()
+	s := New(store)
+	calls := 0
+	var mu sync.Mutex
+	s.Register("fail", "@every 1h", "", func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			return errors.New("boom")
+		}
+		panic("crash")
+	})
+
+	if err := s.RunNow("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := s.RunNow("fail"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		job, _ := s.Get("fail")
+		return job.RunCount == 1 && !job.Running
+	})
+	job, _ := s.Get("fail")
+	if job.LastError != "boom" || job.ErrorCount != 1 || job.LastRunAt == nil {
+		t.Errorf("run result should be recorded %+v", job)
+	}
+
+	s.RunNow("fail")
+	waitFor(t, func() bool {
+		job, _ := s.Get("fail")
+		return job.RunCount == 2
+	})
+	if saved, _ := store.Load("fail"); saved.LastError != "panic: crash" || saved.ErrorCount != 2 {
+		t.Errorf("panic should be recorded and persisted %+v", saved)
+	}
+}
+
+func TestSchedulerPauseResume(t *testing.T) {
+	store := newMemoryStore()
+	s := New(store)
+	s.Start()
+	defer s.Stop()
+
+	var mu sync.Mutex
+	runs := 0
+	fn := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		return nil
+	}
+	s.Register("tick", "@every 1s", "", fn)
+	if err := s.Pause("tick"); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := s.Get("tick"); !job.Paused || job.NextRunAt != nil {
+		t.Errorf("paused job should not be scheduled %+v", job)
+	}
+
+	// 暂停状态持久化,重新注册后保持暂停
+	restarted := New(store)
+	restarted.Register("tick", "@every 1s", "", fn)
+	if job, _ := restarted.Get("tick"); !job.Paused {
+		t.Errorf("paused state should survive restart %+v", job)
+	}
+
+	if err := s.Resume("tick"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return runs > 0
+	})
+	if saved, _ := store.Load("tick"); saved.Paused {
+		t.Errorf("resumed state should be persisted")
+	}
+}
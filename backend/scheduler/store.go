@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"lumos-stock/backend/db"
+)
+
+// @Author spark
+// @Date 2025/9/28 10:40
+// @Desc 任务定义持久化
+// -----------------------------------------------------------------------------------
+
+// Store 任务定义存储
+type Store interface {
+	Load(name string) (ScheduledJob, bool)
+	Save(job *ScheduledJob) error
+	Delete(name string) error
+}
+
+// DBStore 保存到 scheduled_job 表
+type DBStore struct {
+}
+
+func NewDBStore() *DBStore {
+	return &DBStore{}
+}
+
+func (d DBStore) Load(name string) (ScheduledJob, bool) {
+	job := ScheduledJob{}
+	db.Dao.Model(&ScheduledJob{}).Where("name = ?", name).First(&job)
+	return job, job.ID > 0
+}
+
+func (d DBStore) Save(job *ScheduledJob) error {
+	return db.Dao.Save(job).Error
+}
+
+func (d DBStore) Delete(name string) error {
+	return db.Dao.Unscoped().Where("name = ?", name).Delete(&ScheduledJob{}).Error
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"lumos-stock/backend/events"
+	"sync"
+	"sync/atomic"
+)
+
+// @Author spark
+// @Date 2025/9/26 9:40
+// @Desc SSE事件广播,无界面模式下订阅事件总线
+// -----------------------------------------------------------------------------------
+
+// Event 推送给SSE订阅者的事件
+type Event struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	Data any    `json:"data"`
+}
+
+// Hub SSE事件广播,订阅者消费过慢时丢弃事件,不阻塞发布方
+type Hub struct {
+	mu          sync.RWMutex
+	nextID      atomic.Uint64
+	subscribers map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[chan Event]struct{})}
+}
+
+// Publish 广播事件
+func (h *Hub) Publish(name string, data any) {
+	event := Event{ID: h.nextID.Add(1), Name: name, Data: data}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe 订阅事件,返回的函数用于取消订阅
+func (h *Hub) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Handle 作为事件总线的订阅者,将总线事件推送给SSE客户端
+func (h *Hub) Handle(event events.Event) {
+	h.Publish(event.Topic, event.Payload)
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/26 11:00
+// @Desc 根据方法签名反射生成 OpenAPI 3.1 描述
+// -----------------------------------------------------------------------------------
+
+var (
+	errorType         = reflect.TypeFor[error]()
+	timeType          = reflect.TypeFor[time.Time]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	invalidSchemaName = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+)
+
+// OpenAPI 生成接口描述,Go 反射拿不到参数名,参数按位置命名为 arg1、arg2...
+func (s *Server) OpenAPI() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]any{}
+	for _, name := range s.Methods() {
+		m := s.methods[name]
+		items := make([]any, len(m.in))
+		for i, t := range m.in {
+			schema := schemaOf(t, schemas)
+			schema["title"] = fmt.Sprintf("arg%d", i+1)
+			items[i] = schema
+		}
+		var outs []reflect.Type
+		for _, out := range m.out {
+			if out != errorType {
+				outs = append(outs, out)
+			}
+		}
+		var result map[string]any
+		switch len(outs) {
+		case 0:
+			result = map[string]any{"type": "null"}
+		case 1:
+			result = schemaOf(outs[0], schemas)
+		default:
+			prefixItems := make([]any, len(outs))
+			for i, out := range outs {
+				prefixItems[i] = schemaOf(out, schemas)
+			}
+			result = map[string]any{"type": "array", "prefixItems": prefixItems}
+		}
+		paths["/api/"+name] = map[string]any{
+			"post": map[string]any{
+				"operationId": name,
+				"tags":        []string{"App"},
+				"requestBody": map[string]any{
+					"required": len(m.in) > 0,
+					"content": map[string]any{
+						"application/json": map[string]any{
+							"schema": map[string]any{
+								"type":        "array",
+								"prefixItems": items,
+								"maxItems":    len(items),
+							},
+						},
+					},
+				},
+				"responses": map[string]any{
+					"200": jsonResponse("成功", result),
+					"400": jsonResponse("参数错误", map[string]any{"$ref": "#/components/schemas/Error"}),
+					"401": jsonResponse("未授权", map[string]any{"$ref": "#/components/schemas/Error"}),
+					"500": jsonResponse("调用失败", map[string]any{"$ref": "#/components/schemas/Error"}),
+				},
+			},
+		}
+	}
+	paths["/api/events"] = map[string]any{
+		"get": map[string]any{
+			"operationId": "events",
+			"tags":        []string{"Events"},
+			"description": "SSE事件流,event 为事件名,data 为事件数据的JSON",
+			"parameters": []any{
+				map[string]any{
+					"name":        "events",
+					"in":          "query",
+					"description": "只接收指定事件,逗号分隔",
+					"schema":      map[string]any{"type": "string"},
+				},
+				map[string]any{
+					"name":        "token",
+					"in":          "query",
+					"description": "无法设置请求头时传递访问令牌",
+					"schema":      map[string]any{"type": "string"},
+				},
+			},
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "事件流",
+					"content":     map[string]any{"text/event-stream": map[string]any{"schema": map[string]any{"type": "string"}}},
+				},
+			},
+		},
+	}
+	schemas["Error"] = map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"error": map[string]any{"type": "string"}},
+	}
+	title := s.options.Title
+	if title == "" {
+		title = "lumos-stock"
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   title,
+			"version": s.options.Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []any{map[string]any{"bearer": []string{}}},
+	}
+}
+
+func jsonResponse(description string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
+
+// schemaOf 类型对应的 JSON Schema,结构体注册到 components 中并以引用返回
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaOf(t.Elem(), schemas)
+		return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+			return map[string]any{}
+		}
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name := invalidSchemaName.ReplaceAllString(path.Base(t.PkgPath())+"."+t.Name(), "_")
+		if _, ok := schemas[name]; !ok {
+			// 先占位,避免自引用的结构体无限递归
+			schemas[name] = map[string]any{}
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := map[string]any{}
+	addStructFields(t, properties, schemas)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// addStructFields 按 encoding/json 的规则收集字段,匿名嵌入的结构体字段提升到外层
+func addStructFields(t reflect.Type, properties map[string]any, schemas map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				addStructFields(fieldType, properties, schemas)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, schemas)
+	}
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lumos-stock/backend/logger"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"github.com/duke-git/lancet/v2/strutil"
+)
+
+// @Author spark
+// @Date 2025/9/26 10:10
+// @Desc 无界面模式的HTTP服务,将绑定对象的导出方法暴露为JSON接口
+// POST /api/{Method}   请求体为参数数组,如 ["sh600000","浦发银行",30],返回值以JSON返回
+// GET  /api/events     SSE事件流,可用 ?events=stock_price,newsPush 过滤
+// GET  /openapi.json   OpenAPI 描述
+// 除 /openapi.json 外均需令牌:Authorization: Bearer <token>,仅 /api/events 在 EventSource 无法设置请求头时可用 ?token=
+// -----------------------------------------------------------------------------------
+
+const (
+	maxRequestBody    = 8 << 20
+	sseHeartbeat      = 15 * time.Second
+	sseSubscribeQueue = 256
+)
+
+// Options 服务配置
+type Options struct {
+	Token   string
+	Title   string
+	Version string
+	// Exclude 不对外暴露的方法,如依赖窗口的文件对话框
+	Exclude []string
+}
+
+type method struct {
+	name  string
+	value reflect.Value
+	in    []reflect.Type
+	out   []reflect.Type
+}
+
+// Server 通过反射调用绑定对象的方法,与 Wails Bind 的规则一致:只暴露导出方法
+type Server struct {
+	options Options
+	hub     *Hub
+	methods map[string]method
+	mux     *http.ServeMux
+}
+
+func New(target any, hub *Hub, options Options) *Server {
+	s := &Server{
+		options: options,
+		hub:     hub,
+		methods: make(map[string]method),
+		mux:     http.NewServeMux(),
+	}
+	value := reflect.ValueOf(target)
+	for i := 0; i < value.NumMethod(); i++ {
+		name := value.Type().Method(i).Name
+		if slice.Contain(options.Exclude, name) {
+			continue
+		}
+		fn := value.Method(i)
+		m := method{name: name, value: fn}
+		for j := 0; j < fn.Type().NumIn(); j++ {
+			m.in = append(m.in, fn.Type().In(j))
+		}
+		for j := 0; j < fn.Type().NumOut(); j++ {
+			m.out = append(m.out, fn.Type().Out(j))
+		}
+		s.methods[name] = m
+	}
+	s.mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	s.mux.HandleFunc("GET /api/events", s.auth(s.handleEvents, true))
+	s.mux.HandleFunc("POST /api/{method}", s.auth(s.handleCall, false))
+	return s
+}
+
+// Methods 已暴露的方法名
+func (s *Server) Methods() []string {
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// auth 校验令牌,allowQuery 为 true 时允许通过 ?token= 传递(仅供无法设置请求头的 EventSource 使用)
+func (s *Server) auth(next http.HandlerFunc, allowQuery bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && allowQuery {
+			token = r.URL.Query().Get("token")
+		}
+		if s.options.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "未授权")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleCall(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.methods[r.PathValue("method")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "方法不存在:"+r.PathValue("method"))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	args, err := m.decodeArgs(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result, err := m.call(args)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// decodeArgs 按参数类型逐个解析参数数组,缺省的参数取零值
+func (m method) decodeArgs(body []byte) ([]reflect.Value, error) {
+	var raws []json.RawMessage
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &raws); err != nil {
+			return nil, fmt.Errorf("请求体应为参数数组:%s", err.Error())
+		}
+	}
+	if len(raws) > len(m.in) {
+		return nil, fmt.Errorf("%s 最多接收 %d 个参数", m.name, len(m.in))
+	}
+	args := make([]reflect.Value, len(m.in))
+	for i, t := range m.in {
+		arg := reflect.New(t)
+		if i < len(raws) {
+			if err := json.Unmarshal(raws[i], arg.Interface()); err != nil {
+				return nil, fmt.Errorf("第 %d 个参数错误:%s", i+1, err.Error())
+			}
+		}
+		args[i] = arg.Elem()
+	}
+	return args, nil
+}
+
+// call 调用方法:无返回值时返回 nil,单个返回值原样返回,多个返回值以数组返回,error 类型的返回值转为错误
+func (m method) call(args []reflect.Value) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.SugaredLogger.Errorf("call %s panic: %v\n%s", m.name, r, debug.Stack())
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	var results []any
+	for _, out := range m.value.Call(args) {
+		if out.Type() == errorType {
+			if !out.IsNil() {
+				return nil, out.Interface().(error)
+			}
+			continue
+		}
+		results = append(results, out.Interface())
+	}
+	switch len(results) {
+	case 0:
+		return nil, nil
+	case 1:
+		return results[0], nil
+	}
+	return results, nil
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "不支持SSE")
+		return
+	}
+	var names []string
+	if events := r.URL.Query().Get("events"); events != "" {
+		names = strutil.SplitAndTrim(events, ",")
+	}
+	events, cancel := s.hub.Subscribe(sseSubscribeQueue)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event := <-events:
+			if len(names) > 0 && !slice.Contain(names, event.Name) {
+				continue
+			}
+			payload, err := json.Marshal(event.Data)
+			if err != nil {
+				logger.SugaredLogger.Errorf("marshal event %s error:%s", event.Name, err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Name, payload)
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.OpenAPI())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.SugaredLogger.Errorf("write response error:%s", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": strings.TrimSpace(msg)})
+}
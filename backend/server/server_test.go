@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"lumos-stock/backend/events"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// @Author spark
+// @Date 2025/9/26 14:20
+// @Desc
+//-----------------------------------------------------------------------------------
+
+type quote struct {
+	Code  string  `json:"code"`
+	Price float64 `json:"price"`
+	Note  *string `json:"note,omitempty"`
+}
+
+type fakeApp struct {
+	bus *events.Bus
+}
+
+func (f *fakeApp) Greet(code string) *quote {
+	return &quote{Code: code, Price: 10.5}
+}
+
+func (f *fakeApp) Add(a, b int, extra *int) int {
+	if extra != nil {
+		return a + b + *extra
+	}
+	return a + b
+}
+
+func (f *fakeApp) Fail() (string, error) {
+	return "", errors.New("boom")
+}
+
+func (f *fakeApp) Stream(n int) {
+	for i := 0; i < n; i++ {
+		chunkTopic.Publish(f.bus, i)
+	}
+	chunkTopic.Done(f.bus)
+}
+
+func (f *fakeApp) OpenURL(url string) {
+	panic("desktop only")
+}
+
+var chunkTopic = events.NewStreamTopic[int]("chunk")
+
+func newTestServer() (*httptest.Server, *fakeApp) {
+	hub := NewHub()
+	app := &fakeApp{bus: events.NewBus()}
+	app.bus.Subscribe(hub)
+	srv := New(app, hub, Options{Token: "secret", Exclude: []string{"OpenURL"}})
+	return httptest.NewServer(srv), app
+}
+
+func call(t *testing.T, ts *httptest.Server, method, body, token string) (int, string) {
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/"+method, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var sb strings.Builder
+	_, _ = bufio.NewReader(resp.Body).WriteTo(&sb)
+	return resp.StatusCode, strings.TrimSpace(sb.String())
+}
+
+func TestServerCall(t *testing.T) {
+	ts, _ := newTestServer()
+	defer ts.Close()
+
+	if status, _ := call(t, ts, "Greet", `["sh600000"]`, ""); status != http.StatusUnauthorized {
+		t.Errorf("missing token should be rejected, got %d", status)
+	}
+	if status, _ := call(t, ts, "Greet", `["sh600000"]`, "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("wrong token should be rejected, got %d", status)
+	}
+	if status, _ := call(t, ts, "Greet?token=secret", `["sh600000"]`, ""); status != http.StatusUnauthorized {
+		t.Errorf("query token should be rejected outside events, got %d", status)
+	}
+	if status, body := call(t, ts, "Greet", `["sh600000"]`, "secret"); status != http.StatusOK || body != `{"code":"sh600000","price":10.5}` {
+		t.Errorf("unexpected Greet response %d %s", status, body)
+	}
+	if _, body := call(t, ts, "Add", `[1,2]`, "secret"); body != "3" {
+		t.Errorf("missing pointer arg should be nil, got %s", body)
+	}
+	if _, body := call(t, ts, "Add", `[1,2,4]`, "secret"); body != "7" {
+		t.Errorf("unexpected Add result %s", body)
+	}
+	if status, _ := call(t, ts, "Add", `[1,"x"]`, "secret"); status != http.StatusBadRequest {
+		t.Errorf("bad arg type should be 400, got %d", status)
+	}
+	if status, body := call(t, ts, "Fail", ``, "secret"); status != http.StatusInternalServerError || !strings.Contains(body, "boom") {
+		t.Errorf("error result should be 500, got %d %s", status, body)
+	}
+	if status, _ := call(t, ts, "OpenURL", `["https://example.com"]`, "secret"); status != http.StatusNotFound {
+		t.Errorf("excluded method should be 404, got %d", status)
+	}
+}
+
+func TestServerEvents(t *testing.T) {
+	ts, _ := newTestServer()
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/events?events=chunk&token=secret", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected events response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		call(t, ts, "Stream", `[2]`, "secret")
+	}()
+
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && len(data) < 3 {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") && line != "event: chunk" {
+			t.Errorf("unexpected event %s", line)
+		}
+		if payload, ok := strings.CutPrefix(line, "data: "); ok {
+			data = append(data, payload)
+		}
+	}
+	if strings.Join(data, ",") != `0,1,"DONE"` {
+		t.Errorf("unexpected event data %v", data)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	ts, _ := newTestServer()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	doc := struct {
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/api/Greet", "/api/Add", "/api/Stream", "/api/events"} {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("missing path %s", path)
+		}
+	}
+	if _, ok := doc.Paths["/api/OpenURL"]; ok {
+		t.Errorf("excluded method should not be described")
+	}
+	schema, ok := doc.Components.Schemas["server.quote"]
+	if !ok || len(schema.Properties) != 3 || schema.Properties["note"] == nil {
+		t.Errorf("unexpected quote schema %+v", doc.Components.Schemas)
+	}
+}
@@ -40,6 +40,8 @@ export function GetAIResponseResult(arg1:string):Promise<models.AIResponseResult
 
 export function GetAiConfigs():Promise<Array<data.AIConfig>>;
 
+export function GetChatContextSources():Promise<Array<data.ChatContextSource>>;
+
 export function GetConfig():Promise<data.SettingConfig>;
 
 export function GetFollowList(arg1:number):Promise<any>;
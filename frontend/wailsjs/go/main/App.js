@@ -74,6 +74,10 @@ export function GetAiConfigs() {
   return window['go']['main']['App']['GetAiConfigs']();
 }
 
+export function GetChatContextSources() {
+  return window['go']['main']['App']['GetChatContextSources']();
+}
+
 export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
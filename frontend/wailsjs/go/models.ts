@@ -1,5 +1,21 @@
 export namespace data {
 	
+	export class ChatContextSource {
+	    name: string;
+	    title: string;
+	    perStock: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChatContextSource(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.title = source["title"];
+	        this.perStock = source["perStock"];
+	    }
+	}
 	export class AIConfig {
 	    ID: number;
 	    // Go type: time
@@ -375,6 +391,8 @@ export namespace data {
 	    httpProxyEnabled: boolean;
 	    enableAgent: boolean;
 	    qgqpBId: string;
+	    baseCurrency: string;
+	    sentimentAiConfigId: number;
 	    aiConfigs: AIConfig[];
 	
 	    static createFrom(source: any = {}) {
@@ -412,6 +430,8 @@ export namespace data {
 	        this.httpProxyEnabled = source["httpProxyEnabled"];
 	        this.enableAgent = source["enableAgent"];
 	        this.qgqpBId = source["qgqpBId"];
+	        this.baseCurrency = source["baseCurrency"];
+	        this.sentimentAiConfigId = source["sentimentAiConfigId"];
 	        this.aiConfigs = this.convertValues(source["aiConfigs"], AIConfig);
 	    }
 	
@@ -712,6 +732,7 @@ export namespace models {
 	    name: string;
 	    content: string;
 	    type: string;
+	    contextSources: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Prompt(source);
@@ -723,6 +744,7 @@ export namespace models {
 	        this.name = source["name"];
 	        this.content = source["content"];
 	        this.type = source["type"];
+	        this.contextSources = source["contextSources"];
 	    }
 	}
 	export class SentimentResult {
@@ -5,10 +5,13 @@ import (
 	"embed"
 	"encoding/json"
 	"fmt"
+	"lumos-stock/backend/calendar"
 	"lumos-stock/backend/data"
 	"lumos-stock/backend/db"
+	"lumos-stock/backend/events"
 	log "lumos-stock/backend/logger"
 	"lumos-stock/backend/models"
+	"lumos-stock/backend/scheduler"
 	"os"
 	"runtime/debug"
 	"strings"
@@ -20,7 +23,6 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/mac"
 	"github.com/wailsapp/wails/v2/pkg/options/windows"
-	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 //go:embed frontend/dist
@@ -67,7 +69,21 @@ func main() {
 
 	checkDir("data")
 	db.Init("")
+	if err := calendar.LoadFile("data/holidays.json"); err != nil {
+		log.SugaredLogger.Errorf("load local trading calendar error:%s", err.Error())
+	}
 	data.InitAnalyzeSentiment()
+	data.InitSentimentLexicon()
+	data.InitNewsSearch()
+	data.InitNewsDedup()
+	data.RegisterBuiltinNewsSources()
+
+	//无界面模式,domReady 随即启动任务和回填,需先同步完成表结构迁移
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		AutoMigrate()
+		runServer(os.Args[2:])
+		return
+	}
 	go AutoMigrate()
 
 	//db.Dao.Model(&data.Group{}).Where("id = ?", 0).FirstOrCreate(&data.Group{
@@ -243,13 +259,29 @@ func AutoMigrate() {
 	db.Dao.AutoMigrate(&models.BKDict{})
 	db.Dao.AutoMigrate(&models.WordAnalyze{})
 	db.Dao.AutoMigrate(&models.SentimentResultAnalyze{})
+	db.Dao.AutoMigrate(&data.AlertRule{})
+	db.Dao.AutoMigrate(&data.AlertHistory{})
+	db.Dao.AutoMigrate(&data.KLineBar{})
+	db.Dao.AutoMigrate(&data.StockTrade{})
+	db.Dao.AutoMigrate(&data.PortfolioSnapshot{})
+	db.Dao.AutoMigrate(&data.NotifyChannel{})
+	db.Dao.AutoMigrate(&data.MoneyFlowDaily{})
+	db.Dao.AutoMigrate(&data.TelegraphStock{})
+	db.Dao.AutoMigrate(&data.NewsSourceConfig{})
+	db.Dao.AutoMigrate(&data.NewsSentimentBucket{})
+	db.Dao.AutoMigrate(&data.NewsSentimentCache{})
+	db.Dao.AutoMigrate(&data.ChatSession{})
+	db.Dao.AutoMigrate(&data.ChatMessage{})
+	db.Dao.AutoMigrate(&scheduler.ScheduledJob{})
 
 	updateMultipleModel()
+	data.NewTradeLedgerApi().MigrateLegacyPosition()
+	data.NewNewsEntityApi().BackfillLinks()
 }
 
 func initStockDataUS(ctx context.Context) {
 	defer func() {
-		go runtime.EventsEmit(ctx, "loadingMsg", "done")
+		data.TopicLoadingMsg.Publish(events.FromContext(ctx), "done")
 	}()
 	var v []models.StockInfoUS
 	err := json.Unmarshal(stocksBinUS, &v)
@@ -275,7 +307,7 @@ func initStockDataUS(ctx context.Context) {
 
 func initStockDataHK(ctx context.Context) {
 	defer func() {
-		go runtime.EventsEmit(ctx, "loadingMsg", "done")
+		data.TopicLoadingMsg.Publish(events.FromContext(ctx), "done")
 	}()
 	var v []models.StockInfoHK
 	err := json.Unmarshal(stocksBinHK, &v)
@@ -311,7 +343,7 @@ func updateBasicInfo() {
 
 func initStockData(ctx context.Context) {
 	defer func() {
-		go runtime.EventsEmit(ctx, "loadingMsg", "done")
+		data.TopicLoadingMsg.Publish(events.FromContext(ctx), "done")
 	}()
 	fields := "ts_code,symbol,name,area,industry,cnspell,market,list_date,act_name,act_ent_type,fullname,exchange,list_status,curr_type,enname,delist_date,is_hs"
 	log.SugaredLogger.Info("init stock data")
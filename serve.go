@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"lumos-stock/backend/events"
+	"lumos-stock/backend/logger"
+	"lumos-stock/backend/server"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/duke-git/lancet/v2/random"
+)
+
+// @Author spark
+// @Date 2025/9/26 15:00
+// @Desc 无界面模式:lumos-stock serve -addr 127.0.0.1:8765 -token xxx
+// 不启动窗口,运行与桌面版相同的定时任务,通过 HTTP 暴露 App 绑定方法,事件通过 SSE 推送
+// -----------------------------------------------------------------------------------
+
+// desktopOnlyMethods 依赖窗口(文件对话框、浏览器)的方法,无界面模式下不暴露
+var desktopOnlyMethods = []string{
+	"ExportConfig",
+	"SaveAsMarkdown",
+	"SaveBacktestAsMarkdown",
+	"SaveImage",
+	"SaveWordFile",
+	"OpenURL",
+	"ExportChatSession",
+	"ExportSentimentLexicon",
+	"ImportSentimentLexicon",
+}
+
+func runServer(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8765", "监听地址")
+	token := flags.String("token", os.Getenv("LUMOS_TOKEN"), "访问令牌,默认读取环境变量 LUMOS_TOKEN,为空时随机生成")
+	_ = flags.Parse(args)
+
+	if *token == "" {
+		*token = random.RandString(32)
+		fmt.Printf("未配置访问令牌,本次运行的令牌:%s\n", *token)
+	}
+
+	hub := server.NewHub()
+	app := NewApp()
+	app.ctx = events.WithBus(context.Background(), app.bus)
+	app.bus.Subscribe(hub)
+	app.domReady(app.ctx)
+
+	httpServer := &http.Server{
+		Addr: *addr,
+		Handler: server.New(app, hub, server.Options{
+			Token:   *token,
+			Title:   "lumos-stock",
+			Version: Version,
+			Exclude: desktopOnlyMethods,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.SugaredLogger.Infof("serve mode listening on %s, openapi: http://%s/openapi.json", *addr, *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.SugaredLogger.Fatal(err)
+	}
+	app.scheduler.Stop()
+	app.shutdown(app.ctx)
+}
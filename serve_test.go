@@ -0,0 +1,82 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// @Author spark
+// @Date 2025/10/19 10:00
+// @Desc
+// -----------------------------------------------------------------------------------
+
+// TestDesktopOnlyMethods 调用 runtime.*Dialog 的绑定方法在无界面模式下会导致进程退出,必须加入 desktopOnlyMethods
+func TestDesktopOnlyMethods(t *testing.T) {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	dialogs := map[string]bool{}
+	calls := map[string][]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, entry.Name(), nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Body == nil || len(fn.Recv.List) == 0 || len(fn.Recv.List[0].Names) == 0 {
+				continue
+			}
+			star, ok := fn.Recv.List[0].Type.(*ast.StarExpr)
+			if !ok {
+				continue
+			}
+			if ident, ok := star.X.(*ast.Ident); !ok || ident.Name != "App" {
+				continue
+			}
+			recv := fn.Recv.List[0].Names[0].Name
+			ast.Inspect(fn.Body, func(node ast.Node) bool {
+				selector, ok := node.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if ident, ok := selector.X.(*ast.Ident); ok {
+					if ident.Name == "runtime" && strings.HasSuffix(selector.Sel.Name, "Dialog") {
+						dialogs[fn.Name.Name] = true
+					}
+					if ident.Name == recv {
+						calls[fn.Name.Name] = append(calls[fn.Name.Name], selector.Sel.Name)
+					}
+				}
+				return true
+			})
+		}
+	}
+	//间接调用对话框的方法
+	for changed := true; changed; {
+		changed = false
+		for name, callees := range calls {
+			if !dialogs[name] && slices.ContainsFunc(callees, func(callee string) bool { return dialogs[callee] }) {
+				dialogs[name], changed = true, true
+			}
+		}
+	}
+	if len(dialogs) == 0 {
+		t.Fatalf("no dialog methods found")
+	}
+	for name := range dialogs {
+		if ast.IsExported(name) && !slices.Contains(desktopOnlyMethods, name) {
+			t.Errorf("%s opens a dialog and must be listed in desktopOnlyMethods", name)
+		}
+	}
+}